@@ -7,12 +7,15 @@ import (
 
 // App struct
 type App struct {
-	ctx context.Context
+	ctx       context.Context
+	apiURL    string
+	apiSecret string
 }
 
-// NewApp creates a new App application struct
-func NewApp() *App {
-	return &App{}
+// NewApp creates a new App application struct for the API server at apiURL,
+// which requires apiSecret
+func NewApp(apiURL, apiSecret string) *App {
+	return &App{apiURL: apiURL, apiSecret: apiSecret}
 }
 
 // startup is called when the app starts. The context is saved
@@ -25,3 +28,15 @@ func (a *App) startup(ctx context.Context) {
 func (a *App) Greet(name string) string {
 	return fmt.Sprintf("Hello %s, It's show time!", name)
 }
+
+// APIURL returns the base URL of the embedded API server, whose port
+// changes on every launch
+func (a *App) APIURL() string {
+	return a.apiURL
+}
+
+// APISecret returns the secret the embedded API server requires in the
+// X-Todo-Secret header, which changes on every launch
+func (a *App) APISecret() string {
+	return a.apiSecret
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// config is the headless server's configuration. Settings are applied in
+// increasing precedence: defaults, the TOML config file, TODO_* environment
+// variables, then command-line flags.
+type config struct {
+	DB             string `toml:"db"`
+	Addr           string `toml:"addr"`
+	TLSCert        string `toml:"tls_cert"`
+	TLSKey         string `toml:"tls_key"`
+	LogLevel       string `toml:"log_level"`
+	Scheduler      bool   `toml:"scheduler"`
+	Auth           bool   `toml:"auth"`            // require a login or API token per request
+	Notifications  string `toml:"notifications"`   // path of the notification channel config
+	TrashRetention string `toml:"trash_retention"` // how long deleted items are kept, as a Go duration; 0 keeps them
+}
+
+func defaultConfig() config {
+	return config{
+		DB:             "todo.db",
+		Addr:           ":8081",
+		LogLevel:       "info",
+		Scheduler:      true,
+		Auth:           true,
+		Notifications:  "notifications.json",
+		TrashRetention: "720h",
+	}
+}
+
+// setting ties a config field to its flag and environment variable.
+type setting struct {
+	name    string // flag name; the variable is TODO_ plus it upper-cased, - as _
+	usage   string
+	str     *string
+	boolean *bool
+}
+
+func (st setting) env() string {
+	return "TODO_" + strings.ToUpper(strings.ReplaceAll(st.name, "-", "_"))
+}
+
+func (st setting) set(v string) error {
+	if st.str != nil {
+		*st.str = v
+		return nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return fmt.Errorf("%s: %q is not a boolean", st.name, v)
+	}
+	*st.boolean = b
+	return nil
+}
+
+// loadConfig builds the configuration from args (without the program name),
+// the environment and the config file named by -config or TODO_CONFIG. It
+// also returns the arguments following the flags, such as a subcommand.
+func loadConfig(args []string, getenv func(string) string, stderr io.Writer) (config, []string, error) {
+	cfg := defaultConfig()
+	settings := []setting{
+		{name: "db", usage: "SQLite database path", str: &cfg.DB},
+		{name: "addr", usage: "address to listen on", str: &cfg.Addr},
+		{name: "tls-cert", usage: "TLS certificate file; serves HTTPS together with -tls-key", str: &cfg.TLSCert},
+		{name: "tls-key", usage: "TLS private key file", str: &cfg.TLSKey},
+		{name: "log-level", usage: "debug, info, warn or error", str: &cfg.LogLevel},
+		{name: "scheduler", usage: "deliver reminders and digests", boolean: &cfg.Scheduler},
+		{name: "auth", usage: "require a login or API token and keep each user's todos apart", boolean: &cfg.Auth},
+		{name: "notifications", usage: "notification channel config (JSON)", str: &cfg.Notifications},
+		{name: "trash-retention", usage: "how long deleted items stay in the trash, e.g. 720h; 0 keeps them", str: &cfg.TrashRetention},
+	}
+
+	// Flags are parsed into scratch values first: they override the file and
+	// environment, but the file's path may itself be a flag
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	configPath := fs.String("config", getenv("TODO_CONFIG"), "TOML config file (env TODO_CONFIG)")
+	flags := map[string]*string{}
+	for _, st := range settings {
+		v := new(string)
+		flags[st.name] = v
+		if st.boolean != nil {
+			fs.BoolFunc(st.name, fmt.Sprintf("%s (env %s, default %t)", st.usage, st.env(), *st.boolean), func(s string) error { *v = s; return nil })
+		} else {
+			fs.StringVar(v, st.name, "", fmt.Sprintf("%s (env %s, default %q)", st.usage, st.env(), *st.str))
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return cfg, nil, err
+	}
+
+	if *configPath != "" {
+		md, err := toml.DecodeFile(*configPath, &cfg)
+		if err != nil {
+			return cfg, nil, fmt.Errorf("config %s: %w", *configPath, err)
+		}
+		if unknown := md.Undecoded(); len(unknown) > 0 {
+			return cfg, nil, fmt.Errorf("config %s: unknown setting %q", *configPath, unknown[0].String())
+		}
+	}
+	for _, st := range settings {
+		if v := getenv(st.env()); v != "" {
+			if err := st.set(v); err != nil {
+				return cfg, nil, fmt.Errorf("%s: %w", st.env(), err)
+			}
+		}
+	}
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		for _, st := range settings {
+			if st.name == f.Name && err == nil {
+				err = st.set(*flags[st.name])
+			}
+		}
+	})
+	if err != nil {
+		return cfg, nil, err
+	}
+
+	if (cfg.TLSCert == "") != (cfg.TLSKey == "") {
+		return cfg, nil, errors.New("tls-cert and tls-key must be given together")
+	}
+	if _, err := parseLogLevel(cfg.LogLevel); err != nil {
+		return cfg, nil, err
+	}
+	if _, err := parseRetention(cfg.TrashRetention); err != nil {
+		return cfg, nil, err
+	}
+	return cfg, fs.Args(), nil
+}
+
+func parseLogLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return level, fmt.Errorf("invalid log level %q: use debug, info, warn or error", s)
+	}
+	return level, nil
+}
+
+func parseRetention(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid trash retention %q: use a duration such as 720h, or 0 to keep deleted items", s)
+	}
+	return d, nil
+}
+
+// setupLogging routes all logging, including the log package used across
+// the backend, through a handler that drops messages below level.
+func setupLogging(level slog.Level) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+}
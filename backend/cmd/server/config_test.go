@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "server.toml")
+	os.WriteFile(path, []byte("db = \"/var/lib/todo/todo.db\"\naddr = \":9000\"\nlog_level = \"warn\"\nscheduler = false\n"), 0o644)
+
+	env := map[string]string{"TODO_CONFIG": path, "TODO_ADDR": ":9100"}
+	getenv := func(k string) string { return env[k] }
+
+	cfg, args, err := loadConfig([]string{"-log-level", "debug", "migrate", "status"}, getenv, io.Discard)
+	if err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+	// The file overrides defaults, the environment the file, and flags all
+	if cfg.DB != "/var/lib/todo/todo.db" || cfg.Addr != ":9100" || cfg.LogLevel != "debug" || cfg.Scheduler || cfg.Notifications != "notifications.json" {
+		t.Errorf("Unexpected config: %+v", cfg)
+	}
+	if len(args) != 2 || args[0] != "migrate" {
+		t.Errorf("Expected the subcommand to be left over, got %v", args)
+	}
+
+	cfg, _, err = loadConfig([]string{"-scheduler"}, getenv, io.Discard)
+	if err != nil || !cfg.Scheduler {
+		t.Errorf("Expected -scheduler to turn the scheduler back on, got %+v (%v)", cfg, err)
+	}
+	if !cfg.Auth {
+		t.Error("Expected auth to be on by default")
+	}
+	if d, err := parseRetention(cfg.TrashRetention); err != nil || d != 30*24*time.Hour {
+		t.Errorf("Expected deleted items to be kept 30 days by default, got %v (%v)", d, err)
+	}
+	env["TODO_AUTH"] = "false"
+	if cfg, _, err = loadConfig(nil, getenv, io.Discard); err != nil || cfg.Auth {
+		t.Errorf("Expected TODO_AUTH=false to turn auth off, got %+v (%v)", cfg, err)
+	}
+	delete(env, "TODO_AUTH")
+
+	bad := filepath.Join(dir, "bad.toml")
+	os.WriteFile(bad, []byte("port = 8081\n"), 0o644)
+	for name, args := range map[string][]string{
+		"unknown setting":  {"-config", bad},
+		"missing file":     {"-config", filepath.Join(dir, "missing.toml")},
+		"cert without key": {"-tls-cert", "cert.pem"},
+		"bad log level":    {"-log-level", "loud"},
+		"bad retention":    {"-trash-retention", "30 days"},
+		"bad flag":         {"-port", "1"},
+	} {
+		if _, _, err := loadConfig(args, getenv, io.Discard); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	env["TODO_SCHEDULER"] = "sometimes"
+	if _, _, err := loadConfig(nil, getenv, io.Discard); err == nil {
+		t.Error("Expected a non-boolean TODO_SCHEDULER to be rejected")
+	}
+}
@@ -1,26 +1,115 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
+	"time"
 	"todo/backend/db"
 	"todo/backend/server"
+	"todo/backend/service"
 )
 
+// shutdownTimeout bounds how long shutdown waits for in-flight requests and
+// the scheduler.
+const shutdownTimeout = 30 * time.Second
+
 func main() {
+	cfg, args, err := loadConfig(os.Args[1:], os.Getenv, os.Stderr)
+	if err == flag.ErrHelp {
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	level, _ := parseLogLevel(cfg.LogLevel)
+	setupLogging(level)
+
+	if len(args) > 0 && args[0] == "migrate" {
+		if err := runMigrate(cfg.DB, args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(args) > 0 && args[0] == "users" {
+		if err := runUsers(cfg.DB, args[1:], os.Stdin, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(args) > 0 {
+		log.Fatalf("unknown command %q", args[0])
+	}
+
+	if err := run(cfg); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run serves the API and, unless disabled, the notification scheduler until
+// SIGINT or SIGTERM, then shuts both down gracefully.
+func run(cfg config) error {
 	log.Println("Starting headless server...")
 
 	// Initialize DB
-	if err := db.InitDB("todo.db"); err != nil {
-		log.Fatal(err)
+	conn, err := db.InitDB(cfg.DB)
+	if err != nil {
+		return err
 	}
+	defer conn.Close()
+
+	svc := service.NewSQLite(conn)
 
 	// Start HTTP Server
-	server.StartServer("8081")
+	srv := server.New(svc)
+	if cfg.Auth {
+		srv.RequireAuth()
+		if users, err := svc.GetUsers(); err == nil && len(users) == 0 {
+			log.Println("Auth is on but there are no users yet; create one with: server users add NAME")
+		}
+	} else {
+		log.Println("Auth is off: anyone who can reach the server can read and change every todo")
+	}
+	if err := srv.Listen(cfg.Addr, cfg.TLSCert, cfg.TLSKey); err != nil {
+		return err
+	}
+	log.Printf("Server started on %s", cfg.Addr)
 
-	log.Println("Server started on :8081")
+	// Start Notification Scheduler. There is no desktop to notify, so
+	// reminders go to the server log unless the notification config says
+	// otherwise
+	ctx, stopScheduler := context.WithCancel(context.Background())
+	defer stopScheduler()
+	var scheduler sync.WaitGroup
+	if cfg.Scheduler {
+		notifications, err := service.LoadNotificationConfig(cfg.Notifications)
+		if err != nil {
+			return err
+		}
+		notifier, err := service.NewNotifierRouter(notifications, &service.LogNotifier{})
+		if err != nil {
+			return err
+		}
+		svc.SetNotifier(notifier)
+		scheduler.Add(1)
+		go func() {
+			defer scheduler.Done()
+			svc.RunNotificationScheduler(ctx, service.SchedulerOptions{Digest: notifications.Digest})
+		}()
+	}
+
+	// Purge the trash of items deleted longer ago than the retention
+	if retention, _ := parseRetention(cfg.TrashRetention); retention > 0 {
+		scheduler.Add(1)
+		go func() {
+			defer scheduler.Done()
+			svc.RunTrashPurger(ctx, retention)
+		}()
+	}
 
 	// Wait for interrupt signal
 	c := make(chan os.Signal, 1)
@@ -28,4 +117,22 @@ func main() {
 	<-c
 
 	log.Println("Shutting down...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	stopScheduler()
+	if err := srv.Stop(shutdownCtx); err != nil {
+		log.Println("Error stopping server:", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		scheduler.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-shutdownCtx.Done():
+		log.Println("Scheduler did not stop in time")
+	}
+	return nil
 }
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"todo/backend/db"
+	"todo/backend/service"
+)
+
+const usersUsage = "usage: server users ls|add NAME|passwd NAME|token NAME [LABEL]"
+
+// runUsers implements the "users" subcommand. Passwords are read from the
+// first line of stdin, so they can be piped in by scripts.
+func runUsers(dbPath string, args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(usersUsage)
+	}
+	if args[0] != "ls" && len(args) < 2 {
+		return fmt.Errorf("missing user name\n%s", usersUsage)
+	}
+
+	conn, err := db.InitDB(dbPath)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	svc := service.NewSQLite(conn)
+
+	switch args[0] {
+	case "ls":
+		users, err := svc.GetUsers()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tUSERNAME\tCREATED AT")
+		for _, u := range users {
+			fmt.Fprintf(w, "%d\t%s\t%s\n", u.ID, u.Username, u.CreatedAt.Format("2006-01-02 15:04:05"))
+		}
+		return w.Flush()
+	case "add":
+		password, err := readPassword(stdin, stdout)
+		if err != nil {
+			return err
+		}
+		if _, err := svc.CreateUser(args[1], password); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Created user %s\n", args[1])
+		return nil
+	case "passwd":
+		password, err := readPassword(stdin, stdout)
+		if err != nil {
+			return err
+		}
+		err = svc.SetPassword(args[1], password)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("no user %q", args[1])
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Changed the password of %s\n", args[1])
+		return nil
+	case "token":
+		users, err := svc.GetUsers()
+		if err != nil {
+			return err
+		}
+		label := strings.Join(args[2:], " ")
+		for _, u := range users {
+			if strings.EqualFold(u.Username, args[1]) {
+				token, err := svc.CreateAPIToken(u.ID, label)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintln(stdout, token.Secret)
+				return nil
+			}
+		}
+		return fmt.Errorf("no user %q", args[1])
+	default:
+		return fmt.Errorf("unknown users command %q\n%s", args[0], usersUsage)
+	}
+}
+
+func readPassword(stdin io.Reader, stdout io.Writer) (string, error) {
+	fmt.Fprint(stdout, "Password: ")
+	line, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", errors.New("no password given on stdin")
+	}
+	fmt.Fprintln(stdout)
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"todo/backend/db"
+	"todo/backend/service"
+)
+
+// newTodo is what `todo add` creates.
+type newTodo struct {
+	Title       string
+	Description string
+	Priority    string
+	When        service.Schedule
+	Tags        []string
+	ProjectID   *int
+}
+
+// backend is where the CLI keeps todos: a SQLite file opened directly, or a
+// running server's REST API.
+type backend interface {
+	AddTodo(t newTodo) (int64, error)
+	ListTodos(opts service.TodoListOptions) ([]db.Todo, error)
+	// Batch applies ops to todos in one transaction, all of them or none.
+	Batch(ops []service.BatchOp) error
+	Projects() ([]db.Project, error)
+	AddSubtask(todoID int, title string) (int64, error)
+	Subtasks(todoID int) ([]db.Subtask, error)
+	UpdateSubtask(st db.Subtask) error
+}
+
+// localBackend runs the service against a SQLite file.
+type localBackend struct {
+	svc *service.Service
+}
+
+func (b localBackend) AddTodo(t newTodo) (int64, error) {
+	return b.svc.CreateTodo(t.Title, t.Description, t.Priority, t.When, service.Repeat{}, t.Tags, t.ProjectID)
+}
+
+func (b localBackend) ListTodos(opts service.TodoListOptions) ([]db.Todo, error) {
+	return b.svc.GetTodos(opts)
+}
+
+func (b localBackend) Batch(ops []service.BatchOp) error {
+	_, err := b.svc.Batch(ops, true)
+	var batchErr *service.BatchError
+	if errors.As(err, &batchErr) {
+		return fmt.Errorf("todo %d: %w", ops[batchErr.Index].ID, batchErr.Err)
+	}
+	return err
+}
+
+func (b localBackend) Projects() ([]db.Project, error) {
+	return b.svc.GetProjects()
+}
+
+func (b localBackend) AddSubtask(todoID int, title string) (int64, error) {
+	return b.svc.CreateSubtask(todoID, title)
+}
+
+func (b localBackend) Subtasks(todoID int) ([]db.Subtask, error) {
+	return b.svc.GetSubtasks(todoID)
+}
+
+func (b localBackend) UpdateSubtask(st db.Subtask) error {
+	return b.svc.UpdateSubtask(st.ID, st.Title, st.Completed)
+}
+
+// remoteBackend talks to a server's REST API.
+type remoteBackend struct {
+	base   string // e.g. http://localhost:8081
+	token  string // API token, for servers that require auth
+	client *http.Client
+}
+
+// do sends a JSON request to path and decodes the JSON response into out,
+// when out isn't nil.
+func (b remoteBackend) do(method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		raw, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequest(method, strings.TrimRight(b.base, "/")+path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if b.token != "" {
+		req.Header.Set("Authorization", "Bearer "+b.token)
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(msg, &apiErr) == nil && apiErr.Message != "" {
+			msg = []byte(apiErr.Message)
+		}
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (b remoteBackend) AddTodo(t newTodo) (int64, error) {
+	req := map[string]any{
+		"title":       t.Title,
+		"description": t.Description,
+		"priority":    t.Priority,
+		"timezone":    t.When.TimeZone,
+		"all_day":     t.When.AllDay,
+		"tags":        t.Tags,
+		"project_id":  t.ProjectID,
+	}
+	if t.When.DueDate != nil {
+		req["due_date"] = t.When.DueDate
+	}
+	var res struct {
+		ID int64 `json:"id"`
+	}
+	err := b.do(http.MethodPost, "/api/todos", req, &res)
+	return res.ID, err
+}
+
+func (b remoteBackend) ListTodos(opts service.TodoListOptions) ([]db.Todo, error) {
+	q := url.Values{}
+	for k, v := range map[string]string{"filter": opts.Filter, "sort": opts.Sort, "order": opts.Order} {
+		if v != "" {
+			q.Set(k, v)
+		}
+	}
+	var todos []db.Todo
+	err := b.do(http.MethodGet, "/api/todos?"+q.Encode(), nil, &todos)
+	return todos, err
+}
+
+func (b remoteBackend) Batch(ops []service.BatchOp) error {
+	return b.do(http.MethodPost, "/api/todos/batch", map[string]any{"operations": ops, "atomic": true}, nil)
+}
+
+func (b remoteBackend) Projects() ([]db.Project, error) {
+	var projects []db.Project
+	err := b.do(http.MethodGet, "/api/projects", nil, &projects)
+	return projects, err
+}
+
+func (b remoteBackend) AddSubtask(todoID int, title string) (int64, error) {
+	var res struct {
+		ID int64 `json:"id"`
+	}
+	err := b.do(http.MethodPost, fmt.Sprintf("/api/todos/%d/subtasks", todoID), map[string]string{"title": title}, &res)
+	return res.ID, err
+}
+
+func (b remoteBackend) Subtasks(todoID int) ([]db.Subtask, error) {
+	var subtasks []db.Subtask
+	err := b.do(http.MethodGet, fmt.Sprintf("/api/todos/%d/subtasks", todoID), nil, &subtasks)
+	return subtasks, err
+}
+
+func (b remoteBackend) UpdateSubtask(st db.Subtask) error {
+	return b.do(http.MethodPut, fmt.Sprintf("/api/subtasks/%d", st.ID), map[string]any{"title": st.Title, "completed": st.Completed}, nil)
+}
@@ -0,0 +1,340 @@
+// Command todo manages todos from the terminal, through a running server's
+// REST API or directly on a SQLite file.
+//
+//	todo add "Fix build" -p high --due tomorrow --tag ci --project Infra
+//	todo ls --filter "tag:ci due<=+7d"
+//	todo done 42
+//	todo sub add 42 "write test"
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"todo/backend/db"
+	"todo/backend/service"
+)
+
+const usage = `usage: todo [-server URL [-token TOKEN] | -db PATH] [-o table|json|plain] <command> [args]
+
+commands:
+  add TITLE [-p PRIORITY] [-d DESCRIPTION] [--due WHEN] [--tag TAG]... [--project NAME]
+  ls [--filter EXPR] [--sort FIELD] [--order asc|desc] [--all]
+  done ID...            mark todos completed
+  reopen ID...          mark todos open again
+  rm ID...              delete todos
+  sub add TODO TITLE    add a subtask
+  sub ls TODO           list a todo's subtasks
+  sub done TODO SUB     complete a subtask
+  projects              list projects
+
+WHEN is a date like today, tomorrow 9am, friday, next week, in 3 days,
++2d or 2026-11-01, optionally with a time like 17:00 or 5:30pm.
+
+Todos are read from the server at -server, $TODO_SERVER or
+http://localhost:8081, unless -db or $TODO_DB names a SQLite file to open
+directly. A server that requires auth takes an API token from -token or
+$TODO_TOKEN; create one with "server users token NAME".
+`
+
+func main() {
+	if err := run(os.Args[1:], os.Getenv, os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "todo:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// cli carries what every command needs.
+type cli struct {
+	b      backend
+	out    io.Writer
+	format string
+	now    time.Time
+	zone   string // IANA zone new due dates are in
+}
+
+func run(args []string, getenv func(string) string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("todo", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { fmt.Fprint(stderr, usage) }
+	serverURL := fs.String("server", getenv("TODO_SERVER"), "server URL")
+	dbPath := fs.String("db", getenv("TODO_DB"), "SQLite database path")
+	token := fs.String("token", getenv("TODO_TOKEN"), "API token for the server")
+	format := fs.String("o", "table", "output format: table, json or plain")
+	fs.StringVar(format, "output", "table", "output format: table, json or plain")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != "table" && *format != "json" && *format != "plain" {
+		return fmt.Errorf("unknown output format %q", *format)
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	c := &cli{out: stdout, format: *format, zone: localZone()}
+	loc, _ := time.LoadLocation(c.zone)
+	c.now = time.Now().In(loc)
+
+	// A flag wins over the other's environment variable
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	switch {
+	case set["server"] && set["db"]:
+		return errors.New("give either -server or -db, not both")
+	case *dbPath != "" && !set["server"]:
+		conn, err := db.InitDB(*dbPath)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		c.b = localBackend{svc: service.NewSQLite(conn)}
+	default:
+		if *serverURL == "" {
+			*serverURL = "http://localhost:8081"
+		}
+		c.b = remoteBackend{base: *serverURL, token: *token, client: &http.Client{Timeout: 30 * time.Second}}
+	}
+
+	cmd, rest := fs.Arg(0), fs.Args()[1:]
+	switch cmd {
+	case "add":
+		return c.add(rest)
+	case "ls", "list":
+		return c.list(rest)
+	case "done":
+		return c.setCompleted(rest, true)
+	case "reopen":
+		return c.setCompleted(rest, false)
+	case "rm":
+		return c.remove(rest)
+	case "sub":
+		return c.sub(rest)
+	case "projects":
+		return c.projects()
+	}
+	fs.Usage()
+	return fmt.Errorf("unknown command %q", cmd)
+}
+
+// stringList is a flag that may be repeated.
+type stringList []string
+
+func (l *stringList) String() string     { return strings.Join(*l, ",") }
+func (l *stringList) Set(v string) error { *l = append(*l, v); return nil }
+
+// parseArgs parses flags that may appear before, between or after the
+// positional arguments, which it returns.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
+
+func parseIDs(args []string) ([]int, error) {
+	if len(args) == 0 {
+		return nil, errors.New("missing todo ID")
+	}
+	ids := make([]int, len(args))
+	for i, a := range args {
+		id, err := strconv.Atoi(a)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ID %q", a)
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+func (c *cli) add(args []string) error {
+	fs := newFlagSet("add")
+	var t newTodo
+	var due, project string
+	var tags stringList
+	fs.StringVar(&t.Priority, "p", "", "")
+	fs.StringVar(&t.Priority, "priority", "", "")
+	fs.StringVar(&t.Description, "d", "", "")
+	fs.StringVar(&t.Description, "description", "", "")
+	fs.StringVar(&due, "due", "", "")
+	fs.Var(&tags, "tag", "")
+	fs.StringVar(&project, "project", "", "")
+	words, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	t.Title = strings.Join(words, " ")
+	if t.Title == "" {
+		return errors.New("missing title")
+	}
+	t.Tags = tags
+
+	if due != "" {
+		at, allDay, err := parseWhen(due, c.now)
+		if err != nil {
+			return err
+		}
+		t.When = service.Schedule{DueDate: &at, TimeZone: c.zone, AllDay: allDay}
+	}
+	if project != "" {
+		id, err := c.projectID(project)
+		if err != nil {
+			return err
+		}
+		t.ProjectID = &id
+	}
+
+	id, err := c.b.AddTodo(t)
+	if err != nil {
+		return err
+	}
+	return c.printID(id)
+}
+
+// projectID finds a project by name, ignoring case, or by ID.
+func (c *cli) projectID(nameOrID string) (int, error) {
+	projects, err := c.b.Projects()
+	if err != nil {
+		return 0, err
+	}
+	for _, p := range projects {
+		if strings.EqualFold(p.Name, nameOrID) || strconv.Itoa(p.ID) == nameOrID {
+			return p.ID, nil
+		}
+	}
+	return 0, fmt.Errorf("no project %q", nameOrID)
+}
+
+func (c *cli) list(args []string) error {
+	fs := newFlagSet("ls")
+	var opts service.TodoListOptions
+	all := fs.Bool("all", false, "")
+	fs.StringVar(&opts.Filter, "filter", "", "")
+	fs.StringVar(&opts.Sort, "sort", "", "")
+	fs.StringVar(&opts.Order, "order", "", "")
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return fmt.Errorf("unexpected argument %q", rest[0])
+	}
+	if opts.Filter == "" && !*all {
+		opts.Filter = "is:active"
+	}
+
+	todos, err := c.b.ListTodos(opts)
+	if err != nil {
+		return err
+	}
+	projects, err := c.b.Projects()
+	if err != nil {
+		return err
+	}
+	return c.printTodos(todos, projects)
+}
+
+func (c *cli) setCompleted(args []string, completed bool) error {
+	if completed {
+		return c.batch(args, service.BatchComplete)
+	}
+	return c.batch(args, service.BatchReopen)
+}
+
+func (c *cli) remove(args []string) error {
+	return c.batch(args, service.BatchDelete)
+}
+
+// batch applies action to the todos whose IDs are in args: to all of them,
+// or to none if one fails.
+func (c *cli) batch(args []string, action service.BatchAction) error {
+	ids, err := parseIDs(args)
+	if err != nil {
+		return err
+	}
+	ops := make([]service.BatchOp, len(ids))
+	for i, id := range ids {
+		ops[i] = service.BatchOp{Action: action, ID: id}
+	}
+	return c.b.Batch(ops)
+}
+
+func (c *cli) sub(args []string) error {
+	if len(args) < 2 {
+		return errors.New("usage: todo sub add TODO TITLE | sub ls TODO | sub done TODO SUB")
+	}
+	todoID, err := strconv.Atoi(args[1])
+	if err != nil {
+		return fmt.Errorf("invalid todo ID %q", args[1])
+	}
+
+	switch args[0] {
+	case "add":
+		title := strings.Join(args[2:], " ")
+		if title == "" {
+			return errors.New("missing title")
+		}
+		id, err := c.b.AddSubtask(todoID, title)
+		if err != nil {
+			return err
+		}
+		return c.printID(id)
+	case "ls", "list":
+		subtasks, err := c.b.Subtasks(todoID)
+		if err != nil {
+			return err
+		}
+		return c.printSubtasks(subtasks)
+	case "done":
+		if len(args) != 3 {
+			return errors.New("usage: todo sub done TODO SUB")
+		}
+		subID, err := strconv.Atoi(args[2])
+		if err != nil {
+			return fmt.Errorf("invalid subtask ID %q", args[2])
+		}
+		subtasks, err := c.b.Subtasks(todoID)
+		if err != nil {
+			return err
+		}
+		for _, st := range subtasks {
+			if st.ID == subID {
+				st.Completed = true
+				return c.b.UpdateSubtask(st)
+			}
+		}
+		return fmt.Errorf("todo %d has no subtask %d", todoID, subID)
+	}
+	return fmt.Errorf("unknown sub command %q", args[0])
+}
+
+func (c *cli) projects() error {
+	projects, err := c.b.Projects()
+	if err != nil {
+		return err
+	}
+	return c.printProjects(projects)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"todo/backend/db"
+	"todo/backend/server"
+	"todo/backend/service"
+)
+
+func TestParseWhen(t *testing.T) {
+	t.Parallel()
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	now := time.Date(2026, 10, 14, 15, 30, 0, 0, berlin) // a Wednesday
+	day := func(d int) time.Time { return time.Date(2026, 10, d, 0, 0, 0, 0, berlin) }
+	at := func(d, h, m int) time.Time { return time.Date(2026, 10, d, h, m, 0, 0, berlin) }
+
+	tests := []struct {
+		in     string
+		want   time.Time
+		allDay bool
+	}{
+		{"today", day(14), true},
+		{"Tomorrow", day(15), true},
+		{"tomorrow 9am", at(15, 9, 0), false},
+		{"tomorrow at 5:30pm", at(15, 17, 30), false},
+		{"tonight", at(14, 20, 0), false},
+		{"17:00", at(14, 17, 0), false},
+		{"noon", at(14, 12, 0), false},
+		{"friday", day(16), true},
+		{"wednesday", day(21), true},
+		{"next mon", day(19), true},
+		{"next week", day(21), true},
+		{"in 3 days", day(17), true},
+		{"in 2 weeks 08:15", at(28, 8, 15), false},
+		{"+2d", day(16), true},
+		{"2026-11-01", time.Date(2026, 11, 1, 0, 0, 0, 0, berlin), true},
+		{"2026-11-01T09:00:00Z", time.Date(2026, 11, 1, 9, 0, 0, 0, time.UTC), false},
+	}
+	for _, tt := range tests {
+		got, allDay, err := parseWhen(tt.in, now)
+		if err != nil || !got.Equal(tt.want) || allDay != tt.allDay {
+			t.Errorf("parseWhen(%q) = %v, %v, %v; want %v, %v", tt.in, got, allDay, err, tt.want, tt.allDay)
+		}
+	}
+	for _, bad := range []string{"", "someday", "in many days", "13pm", "25:00", "-5:00", "9:-5", "+9am", "tomorrow 9"} {
+		if got, _, err := parseWhen(bad, now); err == nil {
+			t.Errorf("parseWhen(%q) = %v, want an error", bad, got)
+		}
+	}
+}
+
+// testBackends runs fn against a local database and against a server, each
+// fresh, so both backends are held to the same behavior.
+// testBackends runs fn against each backend. todo runs a command that must
+// succeed and returns its output; todoErr runs one and returns its error.
+func testBackends(t *testing.T, fn func(t *testing.T, todo func(args ...string) string, todoErr func(args ...string) error)) {
+	dir := t.TempDir()
+	setups := map[string]func() []string{
+		"local": func() []string { return []string{"-db", filepath.Join(dir, "local.db")} },
+		"remote": func() []string {
+			conn, err := db.InitDB(filepath.Join(dir, "remote.db"))
+			if err != nil {
+				t.Fatalf("InitDB failed: %v", err)
+			}
+			t.Cleanup(func() { conn.Close() })
+			svc := service.NewSQLite(conn)
+			id, err := svc.CreateUser("alice", "correct horse")
+			if err != nil {
+				t.Fatalf("CreateUser failed: %v", err)
+			}
+			token, err := svc.CreateAPIToken(int(id), "cli")
+			if err != nil {
+				t.Fatalf("CreateAPIToken failed: %v", err)
+			}
+			srv := server.New(svc)
+			srv.RequireAuth()
+			ts := httptest.NewServer(srv.Handler())
+			t.Cleanup(ts.Close)
+			return []string{"-server", ts.URL, "-token", token.Secret}
+		},
+	}
+	for name, setup := range setups {
+		t.Run(name, func(t *testing.T) {
+			global := setup()
+			todoErr := func(args ...string) error {
+				return run(append(append([]string{}, global...), args...), func(string) string { return "" }, io.Discard, io.Discard)
+			}
+			fn(t, func(args ...string) string {
+				var out, errOut bytes.Buffer
+				if err := run(append(append([]string{}, global...), args...), func(string) string { return "" }, &out, &errOut); err != nil {
+					t.Fatalf("todo %s: %v\n%s", strings.Join(args, " "), err, errOut.String())
+				}
+				return out.String()
+			}, todoErr)
+		})
+	}
+}
+
+func TestCommands(t *testing.T) {
+	testBackends(t, func(t *testing.T, todo func(args ...string) string, todoErr func(args ...string) error) {
+		if got := todo("-o", "plain", "add", "Fix build", "-p", "high", "--due", "2026-11-02", "--tag", "ci", "--tag", "urgent"); got != "1\n" {
+			t.Fatalf("add printed %q", got)
+		}
+		todo("add", "Write", "release", "notes", "--due", "2026-11-03 14:00")
+
+		var todos []db.Todo
+		json.Unmarshal([]byte(todo("-o", "json", "ls")), &todos)
+		if len(todos) != 2 {
+			t.Fatalf("Expected 2 todos, got %+v", todos)
+		}
+		var fix db.Todo
+		for _, td := range todos {
+			if td.ID == 1 {
+				fix = td
+			}
+		}
+		if fix.Title != "Fix build" || fix.Priority != "high" || !fix.AllDay || len(fix.Tags) != 2 {
+			t.Errorf("Unexpected todo: %+v", fix)
+		}
+
+		table := todo("ls", "--sort", "due", "--order", "asc")
+		lines := strings.Split(strings.TrimSpace(table), "\n")
+		if len(lines) != 3 || !strings.HasPrefix(lines[0], "ID") || !strings.Contains(lines[1], "2026-11-02") || !strings.Contains(lines[1], "#ci #urgent") || !strings.Contains(lines[2], "Write release notes") {
+			t.Errorf("Unexpected table:\n%s", table)
+		}
+
+		todo("sub", "add", "1", "write", "test")
+		todo("sub", "done", "1", "1")
+		if got := todo("-o", "plain", "sub", "ls", "1"); got != "1\tx\twrite test\n" {
+			t.Errorf("sub ls printed %q", got)
+		}
+
+		todo("done", "1")
+		if got := todo("-o", "plain", "ls", "--filter", "is:completed"); !strings.HasPrefix(got, "1\tx\thigh\t") {
+			t.Errorf("Expected the completed todo, got %q", got)
+		}
+		if got := strings.Count(todo("-o", "plain", "ls"), "\n"); got != 1 {
+			t.Errorf("Expected ls to hide completed todos, got %d lines", got)
+		}
+		if err := todoErr("rm", "2", "99"); err == nil {
+			t.Error("Expected rm of a missing todo to fail")
+		}
+		if got := strings.Count(todo("-o", "plain", "ls", "--all"), "\n"); got != 2 {
+			t.Errorf("Expected a failed rm to delete nothing, got %d todos", got)
+		}
+		todo("rm", "2")
+		if got := todo("-o", "plain", "ls", "--all"); strings.Count(got, "\n") != 1 {
+			t.Errorf("Expected one todo left, got %q", got)
+		}
+	})
+}
+
+func TestCommandErrors(t *testing.T) {
+	t.Parallel()
+	dbPath := filepath.Join(t.TempDir(), "todo.db")
+	for _, args := range [][]string{
+		{"-db", dbPath, "add"},
+		{"-db", dbPath, "add", "x", "--due", "someday"},
+		{"-db", dbPath, "add", "x", "--project", "Nope"},
+		{"-db", dbPath, "done", "abc"},
+		{"-db", dbPath, "frob"},
+		{"-db", dbPath, "-o", "yaml", "ls"},
+		{"-db", dbPath, "-server", "http://localhost:1", "ls"},
+	} {
+		var out bytes.Buffer
+		if err := run(args, func(string) string { return "" }, &out, &out); err == nil {
+			t.Errorf("todo %v: expected an error", args)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"todo/backend/db"
+)
+
+// Output formats: table is aligned with a header, for people; plain is one
+// tab-separated line per item without a header, for scripts; json is the
+// API's representation.
+
+func (c *cli) printJSON(v any) error {
+	enc := json.NewEncoder(c.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// printRows writes rows as a table, or as plain tab-separated lines.
+func (c *cli) printRows(header []string, rows [][]string) error {
+	var w io.Writer = c.out
+	var tw *tabwriter.Writer
+	if c.format == "table" {
+		tw = tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+		w = tw
+		fmt.Fprintln(w, strings.Join(header, "\t"))
+	}
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	if tw != nil {
+		return tw.Flush()
+	}
+	return nil
+}
+
+func (c *cli) printID(id int64) error {
+	switch c.format {
+	case "json":
+		return c.printJSON(map[string]int64{"id": id})
+	case "plain":
+		_, err := fmt.Fprintln(c.out, id)
+		return err
+	}
+	_, err := fmt.Fprintf(c.out, "Created %d\n", id)
+	return err
+}
+
+func check(done bool) string {
+	if done {
+		return "x"
+	}
+	return " "
+}
+
+// due shows a todo's due date in its own timezone.
+func due(t *db.Todo) string {
+	switch {
+	case t.DueDate == nil:
+		return ""
+	case t.AllDay:
+		return t.DueDate.In(t.Location()).Format("2006-01-02")
+	}
+	return t.DueDate.In(t.Location()).Format("2006-01-02 15:04")
+}
+
+func (c *cli) printTodos(todos []db.Todo, projects []db.Project) error {
+	if c.format == "json" {
+		if todos == nil {
+			todos = []db.Todo{}
+		}
+		return c.printJSON(todos)
+	}
+	names := make(map[int]string, len(projects))
+	for _, p := range projects {
+		names[p.ID] = p.Name
+	}
+	rows := make([][]string, len(todos))
+	for i := range todos {
+		t := &todos[i]
+		project := ""
+		if t.ProjectID != nil {
+			project = names[*t.ProjectID]
+		}
+		tags := make([]string, len(t.Tags))
+		for j, tag := range t.Tags {
+			tags[j] = "#" + tag
+		}
+		rows[i] = []string{fmt.Sprint(t.ID), check(t.Completed), t.Priority, due(t), t.Title, project, strings.Join(tags, " ")}
+	}
+	return c.printRows([]string{"ID", "DONE", "PRIORITY", "DUE", "TITLE", "PROJECT", "TAGS"}, rows)
+}
+
+func (c *cli) printSubtasks(subtasks []db.Subtask) error {
+	if c.format == "json" {
+		if subtasks == nil {
+			subtasks = []db.Subtask{}
+		}
+		return c.printJSON(subtasks)
+	}
+	rows := make([][]string, len(subtasks))
+	for i, st := range subtasks {
+		rows[i] = []string{fmt.Sprint(st.ID), check(st.Completed), st.Title}
+	}
+	return c.printRows([]string{"ID", "DONE", "TITLE"}, rows)
+}
+
+func (c *cli) printProjects(projects []db.Project) error {
+	if c.format == "json" {
+		if projects == nil {
+			projects = []db.Project{}
+		}
+		return c.printJSON(projects)
+	}
+	rows := make([][]string, len(projects))
+	for i, p := range projects {
+		rows[i] = []string{fmt.Sprint(p.ID), p.Name, p.Description}
+	}
+	return c.printRows([]string{"ID", "NAME", "DESCRIPTION"}, rows)
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"todo/backend/filter"
+)
+
+// parseWhen reads a due date written the way people say it, relative to
+// now:
+//
+//	today, tomorrow, tonight, yesterday
+//	monday … sunday, next monday      the next such day after today
+//	next week, next month             a week or month from today
+//	in 3 days, in 2 weeks, in 1 month
+//	+3d, -1w, 2026-11-01              as in filter dates
+//
+// or an RFC 3339 timestamp. Dates may be followed by a time of day, with
+// an optional "at" before it: 9am, 5:30pm, 17:00, noon, midnight. A time on
+// its own means today. allDay is set when no time was given; the result is
+// then midnight in now's zone.
+func parseWhen(s string, now time.Time) (due time.Time, allDay bool, err error) {
+	if t, err := time.Parse(time.RFC3339, strings.TrimSpace(s)); err == nil {
+		return t, false, nil
+	}
+	words := strings.Fields(strings.ToLower(s))
+	if len(words) == 0 {
+		return due, false, fmt.Errorf("empty date")
+	}
+
+	// A trailing time of day
+	hour, minute, hasTime := 0, 0, false
+	if h, m, ok := parseClock(words[len(words)-1]); ok {
+		hour, minute, hasTime = h, m, true
+		words = words[:len(words)-1]
+		if n := len(words); n > 0 && words[n-1] == "at" {
+			words = words[:n-1]
+		}
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	day, err := parseDay(words, today, now)
+	if err != nil {
+		return due, false, fmt.Errorf("can't read date %q: %w", s, err)
+	}
+	if strings.Join(words, " ") == "tonight" && !hasTime {
+		hour, hasTime = 20, true
+	}
+	if !hasTime {
+		return day, true, nil
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, day.Location()), false, nil
+}
+
+// parseDay resolves the date part of parseWhen's input to midnight.
+func parseDay(words []string, today, now time.Time) (time.Time, error) {
+	phrase := strings.Join(words, " ")
+	switch phrase {
+	case "", "today", "tonight":
+		return today, nil
+	case "next week":
+		return today.AddDate(0, 0, 7), nil
+	case "next month":
+		return today.AddDate(0, 1, 0), nil
+	}
+
+	if wd, ok := weekdays[strings.TrimPrefix(phrase, "next ")]; ok {
+		days := (int(wd) - int(today.Weekday()) + 7) % 7
+		if days == 0 {
+			days = 7
+		}
+		return today.AddDate(0, 0, days), nil
+	}
+
+	if len(words) == 3 && words[0] == "in" {
+		n, err := strconv.Atoi(words[1])
+		if err != nil || n < 0 {
+			return today, fmt.Errorf("%q is not a count", words[1])
+		}
+		switch strings.TrimSuffix(words[2], "s") {
+		case "day":
+			return today.AddDate(0, 0, n), nil
+		case "week":
+			return today.AddDate(0, 0, 7*n), nil
+		case "month":
+			return today.AddDate(0, n, 0), nil
+		}
+		return today, fmt.Errorf("unknown unit %q", words[2])
+	}
+
+	if len(words) == 1 {
+		start, end, err := filter.ResolveDate(words[0], now)
+		if err == nil && !start.Equal(end) {
+			return start, nil
+		}
+	}
+	return today, fmt.Errorf("unrecognized")
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday,
+	"thursday": time.Thursday, "friday": time.Friday, "saturday": time.Saturday,
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// parseClock reads a time of day: 9am, 9:30pm, 17:00, noon or midnight.
+func parseClock(s string) (hour, minute int, ok bool) {
+	switch s {
+	case "noon":
+		return 12, 0, true
+	case "midnight":
+		return 0, 0, true
+	}
+	suffix := ""
+	if strings.HasSuffix(s, "am") || strings.HasSuffix(s, "pm") {
+		suffix, s = s[len(s)-2:], s[:len(s)-2]
+	}
+	h, m, found := strings.Cut(s, ":")
+	if !found && suffix == "" {
+		return 0, 0, false // a bare number isn't a time
+	}
+	if !isDigits(h) || found && !isDigits(m) {
+		return 0, 0, false // Atoi would take signs, as in -5:00
+	}
+	hour, err := strconv.Atoi(h)
+	if err != nil {
+		return 0, 0, false
+	}
+	if found {
+		if minute, err = strconv.Atoi(m); err != nil || len(m) != 2 || minute > 59 {
+			return 0, 0, false
+		}
+	}
+	switch suffix {
+	case "":
+		return hour, minute, hour <= 23
+	case "am", "pm":
+		if hour < 1 || hour > 12 {
+			return 0, 0, false
+		}
+		hour %= 12
+		if suffix == "pm" {
+			hour += 12
+		}
+	}
+	return hour, minute, true
+}
+
+// isDigits reports whether s is a non-empty run of ASCII digits.
+func isDigits(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
+
+// localZone returns the IANA name of the local timezone, or "" if it can't
+// be determined, in which case dates are treated as UTC.
+func localZone() string {
+	if tz := os.Getenv("TZ"); tz != "" {
+		if _, err := time.LoadLocation(tz); err == nil {
+			return tz
+		}
+	}
+	if target, err := filepath.EvalSymlinks("/etc/localtime"); err == nil {
+		if _, name, ok := strings.Cut(target, "zoneinfo/"); ok {
+			if _, err := time.LoadLocation(name); err == nil {
+				return name
+			}
+		}
+	}
+	return ""
+}
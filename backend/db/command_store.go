@@ -0,0 +1,252 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// ErrCommandConflict is returned when undoing or redoing a command whose
+// rows have been changed since by something else.
+var ErrCommandConflict = errors.New("rows of the command have changed since")
+
+// commandTables are the tables whose changes are recorded in commands, by
+// the triggers of migration 0012.
+var commandTables = []string{"projects", "todos", "subtasks", "reminders", "series"}
+
+// CommandStore is the SQLite implementation of service.CommandRepository.
+// Commands belong to a session rather than an owner, so it sees all of
+// them.
+type CommandStore struct {
+	q Querier
+}
+
+func NewCommandStore(q Querier) *CommandStore {
+	return &CommandStore{q: q}
+}
+
+const commandColumns = "id, session_id, name, status, created_at"
+
+func scanCommand(row rowScanner) (Command, error) {
+	var c Command
+	err := row.Scan(&c.ID, &c.SessionID, &c.Name, &c.Status, &c.CreatedAt)
+	return c, err
+}
+
+// Begin starts recording a command of sessionID: until End, every change to
+// the recorded tables made in the same transaction is part of it. With
+// resume, the ID of a command that is still the session's latest, it
+// carries on recording that one instead.
+func (s *CommandStore) Begin(sessionID int, name string, resume int) (int, error) {
+	if resume != 0 {
+		err := execOne(s.q, `UPDATE command_log SET status = 'recording'
+			WHERE id = ?1 AND status = 'done' AND id = (SELECT MAX(id) FROM command_log WHERE session_id = ?2)`, resume, sessionID)
+		if err == nil {
+			return resume, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return 0, err
+		}
+	}
+	res, err := s.q.Exec("INSERT INTO command_log (session_id, name) VALUES (?, ?)", sessionID, name)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+// End stops recording command id and makes it the next one to undo. A
+// command that changed nothing is dropped. The session's undone commands
+// can't be redone after it, and only its keep latest commands are kept.
+// The commands of sessions that have ended are forgotten along the way.
+func (s *CommandStore) End(id, keep int) error {
+	var sessionID, changes int
+	err := s.q.QueryRow("SELECT session_id, (SELECT COUNT(*) FROM command_changes WHERE command_id = ?1) FROM command_log WHERE id = ?1", id).Scan(&sessionID, &changes)
+	if err != nil {
+		return err
+	}
+	if changes == 0 {
+		_, err := s.q.Exec("DELETE FROM command_log WHERE id = ?", id)
+		return err
+	}
+	if _, err := s.q.Exec("UPDATE command_log SET status = 'done' WHERE id = ?", id); err != nil {
+		return err
+	}
+
+	stale := `SELECT id FROM command_log WHERE (session_id = ?1 AND (status = 'undone' OR (status = 'done' AND id NOT IN (
+			SELECT id FROM command_log WHERE session_id = ?1 AND status = 'done' ORDER BY id DESC LIMIT ?2
+		)))) OR (session_id <> 0 AND session_id NOT IN (SELECT id FROM tokens))`
+	if _, err := s.q.Exec("DELETE FROM command_changes WHERE command_id IN ("+stale+")", sessionID, keep); err != nil {
+		return err
+	}
+	_, err = s.q.Exec("DELETE FROM command_log WHERE id IN ("+stale+")", sessionID, keep)
+	return err
+}
+
+// Undo reverts the session's latest command that is done and marks it
+// undone. It returns sql.ErrNoRows if there is none, and
+// ErrCommandConflict, leaving the rows as they are, if something else has
+// changed them since.
+func (s *CommandStore) Undo(sessionID int) (*Command, error) {
+	return s.replay(sessionID, CommandDone, CommandUndone)
+}
+
+// Redo makes again the command the session undid last and marks it done.
+// It returns sql.ErrNoRows if there is none, and ErrCommandConflict if the
+// rows it changes have been changed since.
+func (s *CommandStore) Redo(sessionID int) (*Command, error) {
+	return s.replay(sessionID, CommandUndone, CommandDone)
+}
+
+// replay applies the changes of the session's next command with status
+// from, backwards when undoing, and gives it status to. While it runs the
+// command is 'replaying', which tells the history who made the changes.
+func (s *CommandStore) replay(sessionID int, from, to string) (*Command, error) {
+	undo := from == CommandDone
+	order := "id"
+	if undo {
+		order = "id DESC"
+	}
+	c, err := scanCommand(s.q.QueryRow("SELECT "+commandColumns+" FROM command_log WHERE session_id = ? AND status = ? ORDER BY "+order+" LIMIT 1", sessionID, from))
+	if err != nil {
+		return nil, err
+	}
+	changes, err := s.changes(c.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := execOne(s.q, "UPDATE command_log SET status = ? WHERE id = ?", CommandReplaying, c.ID); err != nil {
+		return nil, err
+	}
+	if undo {
+		slices.Reverse(changes)
+	}
+	for _, ch := range changes {
+		before, after := ch.before, ch.after
+		if undo {
+			before, after = after, before
+		}
+		if err := s.apply(ch.table, ch.rowID, before, after); err != nil {
+			return nil, err
+		}
+	}
+	c.Status = to
+	return &c, execOne(s.q, "UPDATE command_log SET status = ? WHERE id = ?", c.Status, c.ID)
+}
+
+// commandChange is one row changed by a command, as JSON objects of its
+// columns before and after. A row the command inserted has no before, and
+// one it deleted no after.
+type commandChange struct {
+	table         string
+	rowID         int
+	before, after sql.NullString
+}
+
+// changes returns the changes of command id in the order they were made.
+func (s *CommandStore) changes(id int) ([]commandChange, error) {
+	rows, err := s.q.Query("SELECT table_name, row_id, before, after FROM command_changes WHERE command_id = ? ORDER BY id", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []commandChange
+	for rows.Next() {
+		var ch commandChange
+		if err := rows.Scan(&ch.table, &ch.rowID, &ch.before, &ch.after); err != nil {
+			return nil, err
+		}
+		changes = append(changes, ch)
+	}
+	return changes, rows.Err()
+}
+
+// apply sets row rowID of table from the image from to the image to,
+// inserting or deleting it as needed. It returns ErrCommandConflict if
+// the row isn't as from describes it.
+func (s *CommandStore) apply(table string, rowID int, from, to sql.NullString) error {
+	if !slices.Contains(commandTables, table) {
+		return fmt.Errorf("command log: unknown table %q", table)
+	}
+	image := from
+	if !image.Valid {
+		image = to
+	}
+	columns, err := imageColumns(image.String)
+	if err != nil {
+		return err
+	}
+
+	pairs := make([]string, len(columns))
+	values := make([]string, len(columns))
+	for i, c := range columns {
+		pairs[i] = "'" + c + "', " + c
+		values[i] = "json_extract(?1, '$." + c + "')"
+	}
+	var current sql.NullString
+	err = s.q.QueryRow("SELECT json_object("+strings.Join(pairs, ", ")+") FROM "+table+" WHERE id = ?", rowID).Scan(&current)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	same, err := sameImage(current, from)
+	if err != nil {
+		return err
+	}
+	if !same {
+		return fmt.Errorf("%w: %s %d", ErrCommandConflict, table, rowID)
+	}
+
+	switch {
+	case !to.Valid:
+		_, err = s.q.Exec("DELETE FROM "+table+" WHERE id = ?", rowID)
+	case current.Valid:
+		set := make([]string, len(columns))
+		for i, c := range columns {
+			set[i] = c + " = " + values[i]
+		}
+		_, err = s.q.Exec("UPDATE "+table+" SET "+strings.Join(set, ", ")+" WHERE id = ?2", to.String, rowID)
+	default:
+		_, err = s.q.Exec("INSERT INTO "+table+" ("+strings.Join(columns, ", ")+") VALUES ("+strings.Join(values, ", ")+")", to.String)
+	}
+	return err
+}
+
+// imageColumns returns the columns of a row image in a stable order. They
+// were written by the triggers, but are checked before going into SQL.
+func imageColumns(image string) ([]string, error) {
+	var row map[string]any
+	if err := json.Unmarshal([]byte(image), &row); err != nil {
+		return nil, err
+	}
+	columns := make([]string, 0, len(row))
+	for c := range row {
+		if c == "" || strings.Trim(c, "abcdefghijklmnopqrstuvwxyz_") != "" {
+			return nil, fmt.Errorf("command log: bad column %q", c)
+		}
+		columns = append(columns, c)
+	}
+	slices.Sort(columns)
+	return columns, nil
+}
+
+// sameImage reports whether two row images hold the same values, NULL
+// standing for a row that doesn't exist.
+func sameImage(a, b sql.NullString) (bool, error) {
+	if !a.Valid || !b.Valid {
+		return a.Valid == b.Valid, nil
+	}
+	var x, y map[string]any
+	if err := json.Unmarshal([]byte(a.String), &x); err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal([]byte(b.String), &y); err != nil {
+		return false, err
+	}
+	return reflect.DeepEqual(x, y), nil
+}
@@ -2,63 +2,45 @@ package db
 
 import (
 	"database/sql"
+	"fmt"
+	"strings"
+	"sync/atomic"
 
 	_ "modernc.org/sqlite"
 )
 
-var DB *sql.DB
+var memoryDBSeq atomic.Int64
 
-func InitDB(dbPath string) error {
-	var err error
-	DB, err = sql.Open("sqlite", dbPath)
-	if err != nil {
-		return err
-	}
-
-	createTableSQL := `CREATE TABLE IF NOT EXISTS todos (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		title TEXT NOT NULL,
-		completed BOOLEAN DEFAULT FALSE,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);`
+// connPragmas are set on every connection: foreign keys, so ON DELETE
+// clauses take effect, and a busy timeout, so the scheduler, the trash
+// purger and requests wait for each other's writes instead of failing with
+// SQLITE_BUSY.
+const connPragmas = "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
 
-	_, err = DB.Exec(createTableSQL)
-	if err != nil {
-		return err
+// Open opens the SQLite database at dbPath without touching its schema.
+func Open(dbPath string) (*sql.DB, error) {
+	if dbPath == ":memory:" {
+		// Every connection to ":memory:" gets its own empty database, so give
+		// the pool a private shared-cache database all its connections can see.
+		dbPath = fmt.Sprintf("file:memdb%d?mode=memory&cache=shared", memoryDBSeq.Add(1))
 	}
-
-	createProjectsTableSQL := `CREATE TABLE IF NOT EXISTS projects (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT NOT NULL,
-		description TEXT DEFAULT '',
-		color TEXT DEFAULT '#64748B',
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);`
-	if _, err := DB.Exec(createProjectsTableSQL); err != nil {
-		return err
+	sep := "?"
+	if strings.Contains(dbPath, "?") {
+		sep = "&"
 	}
+	return sql.Open("sqlite", dbPath+sep+connPragmas)
+}
 
-	createSubtasksTableSQL := `CREATE TABLE IF NOT EXISTS subtasks (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		todo_id INTEGER NOT NULL,
-		title TEXT NOT NULL,
-		completed BOOLEAN DEFAULT FALSE,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY(todo_id) REFERENCES todos(id) ON DELETE CASCADE
-	);`
-	if _, err := DB.Exec(createSubtasksTableSQL); err != nil {
-		return err
+// InitDB opens the database and applies any pending migrations. It refuses to
+// start with ErrSchemaTooNew when the database was migrated by a newer binary.
+func InitDB(dbPath string) (*sql.DB, error) {
+	conn, err := Open(dbPath)
+	if err != nil {
+		return nil, err
 	}
-
-	// Migrations: Try to add new columns if they don't exist
-	// We ignore errors here because "duplicate column name" is expected if run multiple times
-	DB.Exec(`ALTER TABLE todos ADD COLUMN priority TEXT DEFAULT 'medium'`)
-	DB.Exec(`ALTER TABLE todos ADD COLUMN due_date DATETIME`)
-	DB.Exec(`ALTER TABLE todos ADD COLUMN remind_at DATETIME`)
-	DB.Exec(`ALTER TABLE todos ADD COLUMN repeat TEXT DEFAULT ''`)
-	DB.Exec(`ALTER TABLE todos ADD COLUMN description TEXT DEFAULT ''`)
-	DB.Exec(`ALTER TABLE todos ADD COLUMN tags TEXT DEFAULT '[]'`)
-	DB.Exec(`ALTER TABLE todos ADD COLUMN project_id INTEGER REFERENCES projects(id) ON DELETE SET NULL`)
-
-	return nil
+	if _, err := MigrateUp(conn, 0); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
 }
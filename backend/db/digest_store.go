@@ -0,0 +1,64 @@
+package db
+
+import "time"
+
+// DigestStore is the SQLite implementation of service.DigestRepository.
+type DigestStore struct {
+	q     Querier
+	owner owner
+}
+
+// NewDigestStore returns a store of the digests sent to ownerID. Owner 0
+// stands for the digest of everyone's todos, which goes out while there are
+// no users.
+func NewDigestStore(q Querier, ownerID int) *DigestStore {
+	return &DigestStore{q: q, owner: owner(ownerID)}
+}
+
+// Claim takes the owner's digest for date for delivery until leaseUntil,
+// recording the attempt. It returns nil if the digest was sent or given up
+// on, another scheduler holds it, or its retry isn't due at now. A claim
+// that lapses makes the digest due again, like that of a notification.
+func (s *DigestStore) Claim(date string, now, leaseUntil time.Time) (*Digest, error) {
+	res, err := s.q.Exec("INSERT OR IGNORE INTO digests (user_id, date, status, attempts, next_attempt_at) VALUES (?, ?, ?, 1, ?)", int(s.owner), date, NotificationSending, leaseUntil.UTC())
+	if err != nil {
+		return nil, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		res, err = s.q.Exec(`UPDATE digests SET status = ?, attempts = attempts + 1, next_attempt_at = ?
+			WHERE user_id = ? AND date = ? AND status IN (?, ?) AND next_attempt_at <= ?`, NotificationSending, leaseUntil.UTC(), int(s.owner), date, NotificationSending, NotificationFailed, now.UTC())
+		if err != nil {
+			return nil, err
+		}
+		if n, err = res.RowsAffected(); err != nil || n == 0 {
+			return nil, err
+		}
+	}
+
+	var d Digest
+	var delivered string
+	err = s.q.QueryRow("SELECT user_id, date, status, attempts, last_error, delivered, next_attempt_at, sent_at FROM digests WHERE user_id = ? AND date = ?", int(s.owner), date).
+		Scan(&d.UserID, &d.Date, &d.Status, &d.Attempts, &d.LastError, &delivered, &d.NextAttemptAt, &d.SentAt)
+	if err != nil {
+		return nil, err
+	}
+	d.Delivered = decodeChannels(delivered)
+	return &d, nil
+}
+
+// MarkSent records the delivery of a claimed digest.
+func (s *DigestStore) MarkSent(date string, at time.Time) error {
+	_, err := s.q.Exec("UPDATE digests SET status = ?, sent_at = ?, next_attempt_at = NULL WHERE user_id = ? AND date = ?", NotificationSent, at.UTC(), int(s.owner), date)
+	return err
+}
+
+// MarkFailed records a failed delivery of a claimed digest and the channels
+// it did reach, which a retry skips. A nil retryAt gives up on it.
+func (s *DigestStore) MarkFailed(date, deliveryErr string, delivered []int, retryAt *time.Time) error {
+	_, err := s.q.Exec("UPDATE digests SET status = ?, last_error = ?, delivered = ?, next_attempt_at = ? WHERE user_id = ? AND date = ?", NotificationFailed, deliveryErr, encodeChannels(delivered), utc(retryAt), int(s.owner), date)
+	return err
+}
@@ -0,0 +1,83 @@
+package db
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// EventStore is the SQLite implementation of service.EventRepository. The
+// events themselves are written by triggers on todos, subtasks and
+// projects.
+type EventStore struct {
+	q     Querier
+	owner owner
+}
+
+// NewEventStore returns a store of the history of ownerID's todos, or of
+// every user's when ownerID is 0.
+func NewEventStore(q Querier, ownerID int) *EventStore {
+	return &EventStore{q: q, owner: owner(ownerID)}
+}
+
+// ListByTodo returns the history of a todo and its subtasks, oldest first.
+// Todos in the trash have one too. It returns sql.ErrNoRows if there is no
+// such todo.
+func (s *EventStore) ListByTodo(todoID int) ([]Event, error) {
+	var exists int
+	err := s.q.QueryRow("SELECT 1 FROM todos WHERE id = ? AND "+s.owner.owns("owner_id"), todoID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.q.Query(`SELECT e.id, e.kind, e.ref_id, e.todo_id, e.user_id, COALESCE(u.username, ''), e.action, e.field, e.old_value, e.new_value, e.created_at
+		FROM events e LEFT JOIN users u ON u.id = e.user_id
+		WHERE e.todo_id = ?
+		ORDER BY e.id`, todoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []Event{}
+	for rows.Next() {
+		var e Event
+		var before, after any
+		if err := rows.Scan(&e.ID, &e.Kind, &e.RefID, &e.TodoID, &e.UserID, &e.Username, &e.Action, &e.Field, &before, &after, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.Old, e.New = eventValue(e.Field, before), eventValue(e.Field, after)
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// eventValue turns a column value recorded by the triggers back into what
+// the API shows for the field.
+func eventValue(field string, v any) any {
+	switch v := v.(type) {
+	case int64:
+		switch field {
+		case "completed", "all_day", "repeat_catch_up":
+			return v != 0
+		}
+		return v
+	case string:
+		switch field {
+		case "tags":
+			var tags []string
+			if json.Unmarshal([]byte(v), &tags) == nil {
+				return tags
+			}
+		case "due_date", "remind_at", "deleted_at":
+			for _, layout := range []string{"2006-01-02 15:04:05.999999999 -0700 MST", time.DateTime} {
+				if t, err := time.Parse(layout, v); err == nil {
+					return t.UTC()
+				}
+			}
+		}
+		return v
+	case []byte:
+		return string(v)
+	}
+	return v
+}
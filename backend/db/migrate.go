@@ -0,0 +1,391 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFS embed.FS
+
+var migrationFileRe = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// ErrSchemaTooNew is returned when the database has migrations applied that
+// this binary does not know about, i.e. it was written by a newer release.
+var ErrSchemaTooNew = errors.New("database schema is newer than this binary")
+
+// Migration is a single numbered schema change embedded in the binary.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus reports whether a known migration has been applied.
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt *time.Time
+}
+
+// Migrations returns the embedded migrations ordered by version.
+func Migrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFS, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, e := range entries {
+		m := migrationFileRe.FindStringSubmatch(e.Name())
+		if m == nil {
+			return nil, fmt.Errorf("unexpected migration file name %q", e.Name())
+		}
+		version, _ := strconv.Atoi(m[1])
+		body, err := migrationFS.ReadFile(path.Join("migrations", e.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(body)
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %04d_%s has no up script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// MigrateUp applies up to steps pending migrations (all of them when steps <= 0)
+// and returns how many were applied. Each migration runs in its own transaction.
+func MigrateUp(conn *sql.DB, steps int) (count int, err error) {
+	err = withSchemaConn(conn, func(c schemaConn) error {
+		count, err = migrateUp(c, steps)
+		return err
+	})
+	return count, err
+}
+
+func migrateUp(conn schemaConn, steps int) (int, error) {
+	migrations, applied, err := prepareMigrations(conn)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, m := range migrations {
+		if steps > 0 && count >= steps {
+			break
+		}
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		err := inTx(conn, func(tx *sql.Tx) error {
+			if _, err := tx.Exec(m.Up); err != nil {
+				return err
+			}
+			_, err := tx.Exec("INSERT INTO schema_migrations (version, name) VALUES (?, ?)", m.Version, m.Name)
+			return err
+		})
+		if err != nil {
+			return count, fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// MigrateDown reverts the most recently applied migrations, steps at a time
+// (one when steps <= 0), and returns how many were reverted.
+func MigrateDown(conn *sql.DB, steps int) (count int, err error) {
+	if steps <= 0 {
+		steps = 1
+	}
+	err = withSchemaConn(conn, func(c schemaConn) error {
+		count, err = migrateDown(c, steps)
+		return err
+	})
+	return count, err
+}
+
+func migrateDown(conn schemaConn, steps int) (int, error) {
+	migrations, applied, err := prepareMigrations(conn)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := len(migrations) - 1; i >= 0 && count < steps; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		if m.Down == "" {
+			return count, fmt.Errorf("migration %04d_%s has no down script", m.Version, m.Name)
+		}
+		err := inTx(conn, func(tx *sql.Tx) error {
+			if _, err := tx.Exec(m.Down); err != nil {
+				return err
+			}
+			_, err := tx.Exec("DELETE FROM schema_migrations WHERE version = ?", m.Version)
+			return err
+		})
+		if err != nil {
+			return count, fmt.Errorf("revert %04d_%s: %w", m.Version, m.Name, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Status lists every known migration along with whether it has been applied.
+// It only reads: a database without the bookkeeping table, new or from
+// before migrations were versioned, has every migration pending.
+func Status(conn *sql.DB) ([]MigrationStatus, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	applied := map[int]time.Time{}
+	var n int
+	if err := conn.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'").Scan(&n); err != nil {
+		return nil, err
+	}
+	if n > 0 {
+		if applied, err = appliedMigrations(conn); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkNotNewer(migrations, applied); err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		s := MigrationStatus{Migration: m}
+		if at, ok := applied[m.Version]; ok {
+			s.Applied = true
+			s.AppliedAt = &at
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+// prepareMigrations makes sure the bookkeeping table exists, adopts databases
+// created before migrations were versioned, and refuses to continue when the
+// database is ahead of the embedded migrations.
+func prepareMigrations(conn schemaConn) ([]Migration, map[int]time.Time, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if _, err := conn.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		return nil, nil, err
+	}
+
+	applied, err := appliedMigrations(conn)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(applied) == 0 && len(migrations) > 0 && migrations[0].Version == 1 {
+		adopted, err := adoptLegacySchema(conn, migrations[0])
+		if err != nil {
+			return nil, nil, fmt.Errorf("adopt legacy schema: %w", err)
+		}
+		if adopted {
+			if applied, err = appliedMigrations(conn); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	if err := checkNotNewer(migrations, applied); err != nil {
+		return nil, nil, err
+	}
+	return migrations, applied, nil
+}
+
+// checkNotNewer returns ErrSchemaTooNew if a migration beyond the embedded
+// ones has been applied.
+func checkNotNewer(migrations []Migration, applied map[int]time.Time) error {
+	latest := 0
+	if len(migrations) > 0 {
+		latest = migrations[len(migrations)-1].Version
+	}
+	for version := range applied {
+		if version > latest {
+			return fmt.Errorf("%w: database is at version %d, binary knows up to %d", ErrSchemaTooNew, version, latest)
+		}
+	}
+	return nil
+}
+
+func appliedMigrations(conn Querier) (map[int]time.Time, error) {
+	rows, err := conn.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+// legacyTodoColumns are the columns older releases added to todos with
+// unchecked ALTER TABLE statements; any of them may be missing.
+var legacyTodoColumns = []struct{ name, ddl string }{
+	{"priority", `ALTER TABLE todos ADD COLUMN priority TEXT DEFAULT 'medium'`},
+	{"due_date", `ALTER TABLE todos ADD COLUMN due_date DATETIME`},
+	{"remind_at", `ALTER TABLE todos ADD COLUMN remind_at DATETIME`},
+	{"repeat", `ALTER TABLE todos ADD COLUMN repeat TEXT DEFAULT ''`},
+	{"description", `ALTER TABLE todos ADD COLUMN description TEXT DEFAULT ''`},
+	{"tags", `ALTER TABLE todos ADD COLUMN tags TEXT DEFAULT '[]'`},
+	{"project_id", `ALTER TABLE todos ADD COLUMN project_id INTEGER REFERENCES projects(id) ON DELETE SET NULL`},
+}
+
+// adoptLegacySchema brings a database created by the pre-migration InitDB up
+// to the shape of the initial migration and records it as applied.
+// It reports false when the database has no legacy tables.
+func adoptLegacySchema(conn schemaConn, initial Migration) (bool, error) {
+	var n int
+	if err := conn.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'todos'").Scan(&n); err != nil {
+		return false, err
+	}
+	if n == 0 {
+		return false, nil
+	}
+
+	err := inTx(conn, func(tx *sql.Tx) error {
+		existing, err := tableColumns(tx, "todos")
+		if err != nil {
+			return err
+		}
+		for _, col := range legacyTodoColumns {
+			if existing[col.name] {
+				continue
+			}
+			if _, err := tx.Exec(col.ddl); err != nil {
+				return err
+			}
+		}
+		// The initial migration only uses CREATE TABLE IF NOT EXISTS, so
+		// replaying it creates whatever tables the legacy database lacked.
+		if _, err := tx.Exec(initial.Up); err != nil {
+			return err
+		}
+		_, err = tx.Exec("INSERT INTO schema_migrations (version, name) VALUES (?, ?)", initial.Version, initial.Name)
+		return err
+	})
+	return err == nil, err
+}
+
+func tableColumns(tx *sql.Tx, table string) (map[string]bool, error) {
+	rows, err := tx.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		cols[name] = true
+	}
+	return cols, rows.Err()
+}
+
+func inTx(conn schemaConn, fn func(tx *sql.Tx) error) error {
+	tx, err := conn.c.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// schemaConn is the single connection of the pool migrations run on. It has
+// foreign keys off, as SQLite recommends for schema changes: they can't be
+// switched inside a transaction, and with them on, dropping a table to
+// rebuild it would cascade into the rows that reference it.
+type schemaConn struct{ c *sql.Conn }
+
+func (s schemaConn) Exec(query string, args ...any) (sql.Result, error) {
+	return s.c.ExecContext(context.Background(), query, args...)
+}
+
+func (s schemaConn) Query(query string, args ...any) (*sql.Rows, error) {
+	return s.c.QueryContext(context.Background(), query, args...)
+}
+
+func (s schemaConn) QueryRow(query string, args ...any) *sql.Row {
+	return s.c.QueryRowContext(context.Background(), query, args...)
+}
+
+// withSchemaConn runs fn on a connection taken from conn with foreign keys
+// off, and turns them back on before returning it to the pool.
+func withSchemaConn(conn *sql.DB, fn func(c schemaConn) error) error {
+	ctx := context.Background()
+	c, err := conn.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if _, err := c.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+		return err
+	}
+	defer func() {
+		if _, err := c.ExecContext(ctx, "PRAGMA foreign_keys = ON"); err != nil {
+			// Discard the connection rather than let it go unchecked
+			c.Raw(func(any) error { return driver.ErrBadConn })
+		}
+	}()
+	return fn(schemaConn{c})
+}
@@ -0,0 +1,200 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	conn, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open in-memory database: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestMigrateUpFreshDatabase(t *testing.T) {
+	conn := openTestDB(t)
+
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatalf("Migrations failed: %v", err)
+	}
+
+	n, err := MigrateUp(conn, 0)
+	if err != nil {
+		t.Fatalf("MigrateUp failed: %v", err)
+	}
+	if n != len(migrations) {
+		t.Errorf("Expected %d migrations applied, got %d", len(migrations), n)
+	}
+
+	// Running again is a no-op
+	n, err = MigrateUp(conn, 0)
+	if err != nil {
+		t.Fatalf("Second MigrateUp failed: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("Expected no pending migrations, got %d applied", n)
+	}
+
+	if _, err := conn.Exec("INSERT INTO todos (title, priority, tags) VALUES ('x', 'high', '[]')"); err != nil {
+		t.Errorf("Schema is missing expected todo columns: %v", err)
+	}
+}
+
+func TestMigrateDownAndStatus(t *testing.T) {
+	conn := openTestDB(t)
+	if _, err := MigrateUp(conn, 0); err != nil {
+		t.Fatalf("MigrateUp failed: %v", err)
+	}
+
+	migrations, _ := Migrations()
+	n, err := MigrateDown(conn, len(migrations))
+	if err != nil {
+		t.Fatalf("MigrateDown failed: %v", err)
+	}
+	if n != len(migrations) {
+		t.Errorf("Expected %d migrations reverted, got %d", len(migrations), n)
+	}
+
+	statuses, err := Status(conn)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	for _, s := range statuses {
+		if s.Applied {
+			t.Errorf("Migration %d should be pending after down", s.Version)
+		}
+	}
+
+	if _, err := conn.Exec("SELECT 1 FROM todos"); err == nil {
+		t.Error("Expected todos table to be dropped")
+	}
+}
+
+func TestForeignKeys(t *testing.T) {
+	conn := openTestDB(t)
+	if _, err := MigrateUp(conn, 0); err != nil {
+		t.Fatalf("MigrateUp failed: %v", err)
+	}
+
+	// Every connection of the pool has them, not just the one migrated on
+	ctx := context.Background()
+	held, _ := conn.Conn(ctx)
+	defer held.Close()
+	fresh, err := conn.Conn(ctx)
+	if err != nil {
+		t.Fatalf("Conn failed: %v", err)
+	}
+	var foreignKeys, timeout int
+	fresh.QueryRowContext(ctx, "PRAGMA foreign_keys").Scan(&foreignKeys)
+	fresh.QueryRowContext(ctx, "PRAGMA busy_timeout").Scan(&timeout)
+	fresh.Close()
+	if foreignKeys != 1 || timeout != 5000 {
+		t.Errorf("Expected foreign keys on and a busy timeout of 5000ms, got %d and %d", foreignKeys, timeout)
+	}
+
+	setup := `INSERT INTO projects (id, name) VALUES (1, 'Work');
+	INSERT INTO todos (id, title, project_id) VALUES (1, 'Ship', 1), (2, 'Plan', 1);
+	INSERT INTO subtasks (todo_id, title) VALUES (1, 'Test'), (1, 'Tag');
+	INSERT INTO reminders (todo_id, before_due_minutes) VALUES (1, 30);`
+	if _, err := conn.Exec(setup); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	if _, err := conn.Exec("DELETE FROM todos WHERE id = 1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	var orphans int
+	conn.QueryRow("SELECT (SELECT COUNT(*) FROM subtasks) + (SELECT COUNT(*) FROM reminders)").Scan(&orphans)
+	if orphans != 0 {
+		t.Errorf("Expected deleting a todo to cascade to its subtasks and reminders, %d left", orphans)
+	}
+
+	if _, err := conn.Exec("DELETE FROM projects WHERE id = 1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	var projectID *int
+	if err := conn.QueryRow("SELECT project_id FROM todos WHERE id = 2").Scan(&projectID); err != nil || projectID != nil {
+		t.Errorf("Expected deleting a project to clear its todos' project, got %v (%v)", projectID, err)
+	}
+
+	if _, err := conn.Exec("INSERT INTO subtasks (todo_id, title) VALUES (99, 'Nobody')"); err == nil {
+		t.Error("Expected a subtask of a missing todo to be refused")
+	}
+}
+
+func TestMigrateAdoptsLegacySchema(t *testing.T) {
+	conn := openTestDB(t)
+
+	// Shape of a database created by the old InitDB before any ALTER TABLE ran
+	legacy := `CREATE TABLE todos (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		title TEXT NOT NULL,
+		completed BOOLEAN DEFAULT FALSE,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	ALTER TABLE todos ADD COLUMN priority TEXT DEFAULT 'medium';
+	ALTER TABLE todos ADD COLUMN repeat TEXT DEFAULT '';
+	INSERT INTO todos (title) VALUES ('legacy');
+	INSERT INTO todos (title, repeat) VALUES ('water plants', 'weekly');`
+	if _, err := conn.Exec(legacy); err != nil {
+		t.Fatalf("Failed to create legacy schema: %v", err)
+	}
+
+	// Status only reads, so it neither adopts the tables nor records anything
+	statuses, err := Status(conn)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	for _, s := range statuses {
+		if s.Applied {
+			t.Errorf("Migration %d should be pending before adoption", s.Version)
+		}
+	}
+	var tables, cols int
+	conn.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name IN ('schema_migrations', 'subtasks')").Scan(&tables)
+	conn.QueryRow("SELECT COUNT(*) FROM pragma_table_info('todos')").Scan(&cols)
+	if tables != 0 || cols != 6 {
+		t.Errorf("Expected Status to leave the database untouched, got %d new tables and %d todo columns", tables, cols)
+	}
+
+	if _, err := MigrateUp(conn, 0); err != nil {
+		t.Fatalf("MigrateUp on legacy database failed: %v", err)
+	}
+
+	var title, tags string
+	if err := conn.QueryRow("SELECT title, tags FROM todos WHERE id = 1").Scan(&title, &tags); err != nil {
+		t.Fatalf("Legacy row not readable after adoption: %v", err)
+	}
+	if title != "legacy" || tags != "[]" {
+		t.Errorf("Unexpected legacy row after adoption: %q %q", title, tags)
+	}
+	if _, err := conn.Exec("SELECT 1 FROM subtasks"); err != nil {
+		t.Errorf("Expected subtasks table to be created: %v", err)
+	}
+
+	// Repeating todos are backfilled into a series of their own
+	var seriesRepeat string
+	err = conn.QueryRow("SELECT s.repeat FROM todos t JOIN series s ON s.id = t.series_id WHERE t.title = 'water plants'").Scan(&seriesRepeat)
+	if err != nil || seriesRepeat != "weekly" {
+		t.Errorf("Expected repeating todo to get a series, got %q (%v)", seriesRepeat, err)
+	}
+}
+
+func TestMigrateRefusesNewerSchema(t *testing.T) {
+	conn := openTestDB(t)
+	if _, err := MigrateUp(conn, 0); err != nil {
+		t.Fatalf("MigrateUp failed: %v", err)
+	}
+	if _, err := conn.Exec("INSERT INTO schema_migrations (version, name) VALUES (9999, 'future')"); err != nil {
+		t.Fatalf("Failed to insert future migration: %v", err)
+	}
+
+	if _, err := MigrateUp(conn, 0); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("Expected ErrSchemaTooNew, got %v", err)
+	}
+}
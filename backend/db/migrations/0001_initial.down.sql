@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS subtasks;
+DROP TABLE IF EXISTS todos;
+DROP TABLE IF EXISTS projects;
@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS projects (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	description TEXT DEFAULT '',
+	color TEXT DEFAULT '#64748B',
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS todos (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	title TEXT NOT NULL,
+	completed BOOLEAN DEFAULT FALSE,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	priority TEXT DEFAULT 'medium',
+	due_date DATETIME,
+	remind_at DATETIME,
+	repeat TEXT DEFAULT '',
+	description TEXT DEFAULT '',
+	tags TEXT DEFAULT '[]',
+	project_id INTEGER REFERENCES projects(id) ON DELETE SET NULL
+);
+
+CREATE TABLE IF NOT EXISTS subtasks (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	todo_id INTEGER NOT NULL,
+	title TEXT NOT NULL,
+	completed BOOLEAN DEFAULT FALSE,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY(todo_id) REFERENCES todos(id) ON DELETE CASCADE
+);
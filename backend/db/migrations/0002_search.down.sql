@@ -0,0 +1,10 @@
+DROP TRIGGER IF EXISTS projects_search_delete;
+DROP TRIGGER IF EXISTS projects_search_update;
+DROP TRIGGER IF EXISTS projects_search_insert;
+DROP TRIGGER IF EXISTS subtasks_search_delete;
+DROP TRIGGER IF EXISTS subtasks_search_update;
+DROP TRIGGER IF EXISTS subtasks_search_insert;
+DROP TRIGGER IF EXISTS todos_search_delete;
+DROP TRIGGER IF EXISTS todos_search_update;
+DROP TRIGGER IF EXISTS todos_search_insert;
+DROP TABLE IF EXISTS search_index;
@@ -0,0 +1,72 @@
+-- Full-text index over todos, subtasks and projects.
+-- Rows are keyed by rowid = id * 4 + kind (1 = todo, 2 = subtask, 3 = project)
+-- so the triggers below can update a single entry without scanning the index.
+CREATE VIRTUAL TABLE search_index USING fts5(
+	kind UNINDEXED,
+	ref_id UNINDEXED,
+	todo_id UNINDEXED,
+	title,
+	body,
+	tags,
+	tokenize = 'unicode61 remove_diacritics 2'
+);
+
+CREATE TRIGGER todos_search_insert AFTER INSERT ON todos BEGIN
+	INSERT INTO search_index (rowid, kind, ref_id, todo_id, title, body, tags)
+	VALUES (new.id * 4 + 1, 'todo', new.id, new.id, new.title, COALESCE(new.description, ''),
+		COALESCE((SELECT group_concat(value, ' ') FROM json_each(new.tags)), ''));
+END;
+
+CREATE TRIGGER todos_search_update AFTER UPDATE OF title, description, tags ON todos BEGIN
+	DELETE FROM search_index WHERE rowid = old.id * 4 + 1;
+	INSERT INTO search_index (rowid, kind, ref_id, todo_id, title, body, tags)
+	VALUES (new.id * 4 + 1, 'todo', new.id, new.id, new.title, COALESCE(new.description, ''),
+		COALESCE((SELECT group_concat(value, ' ') FROM json_each(new.tags)), ''));
+END;
+
+CREATE TRIGGER todos_search_delete AFTER DELETE ON todos BEGIN
+	DELETE FROM search_index WHERE rowid = old.id * 4 + 1;
+	DELETE FROM search_index WHERE rowid IN (SELECT id * 4 + 2 FROM subtasks WHERE todo_id = old.id);
+END;
+
+CREATE TRIGGER subtasks_search_insert AFTER INSERT ON subtasks BEGIN
+	INSERT INTO search_index (rowid, kind, ref_id, todo_id, title, body, tags)
+	VALUES (new.id * 4 + 2, 'subtask', new.id, new.todo_id, new.title, '', '');
+END;
+
+CREATE TRIGGER subtasks_search_update AFTER UPDATE OF title ON subtasks BEGIN
+	DELETE FROM search_index WHERE rowid = old.id * 4 + 2;
+	INSERT INTO search_index (rowid, kind, ref_id, todo_id, title, body, tags)
+	VALUES (new.id * 4 + 2, 'subtask', new.id, new.todo_id, new.title, '', '');
+END;
+
+CREATE TRIGGER subtasks_search_delete AFTER DELETE ON subtasks BEGIN
+	DELETE FROM search_index WHERE rowid = old.id * 4 + 2;
+END;
+
+CREATE TRIGGER projects_search_insert AFTER INSERT ON projects BEGIN
+	INSERT INTO search_index (rowid, kind, ref_id, todo_id, title, body, tags)
+	VALUES (new.id * 4 + 3, 'project', new.id, NULL, new.name, COALESCE(new.description, ''), '');
+END;
+
+CREATE TRIGGER projects_search_update AFTER UPDATE OF name, description ON projects BEGIN
+	DELETE FROM search_index WHERE rowid = old.id * 4 + 3;
+	INSERT INTO search_index (rowid, kind, ref_id, todo_id, title, body, tags)
+	VALUES (new.id * 4 + 3, 'project', new.id, NULL, new.name, COALESCE(new.description, ''), '');
+END;
+
+CREATE TRIGGER projects_search_delete AFTER DELETE ON projects BEGIN
+	DELETE FROM search_index WHERE rowid = old.id * 4 + 3;
+END;
+
+-- Index whatever already exists.
+INSERT INTO search_index (rowid, kind, ref_id, todo_id, title, body, tags)
+SELECT id * 4 + 1, 'todo', id, id, title, COALESCE(description, ''),
+	COALESCE((SELECT group_concat(value, ' ') FROM json_each(todos.tags)), '')
+FROM todos;
+
+INSERT INTO search_index (rowid, kind, ref_id, todo_id, title, body, tags)
+SELECT id * 4 + 2, 'subtask', id, todo_id, title, '', '' FROM subtasks;
+
+INSERT INTO search_index (rowid, kind, ref_id, todo_id, title, body, tags)
+SELECT id * 4 + 3, 'project', id, NULL, name, COALESCE(description, ''), '' FROM projects;
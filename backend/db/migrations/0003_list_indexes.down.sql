@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_todos_due_date;
+DROP INDEX IF EXISTS idx_todos_created_at;
+DROP INDEX IF EXISTS idx_subtasks_todo_id;
@@ -0,0 +1,4 @@
+-- Support batched subtask loading and keyset pagination of todos.
+CREATE INDEX IF NOT EXISTS idx_subtasks_todo_id ON subtasks (todo_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_todos_created_at ON todos (created_at, id);
+CREATE INDEX IF NOT EXISTS idx_todos_due_date ON todos (due_date, id);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_todos_series;
+ALTER TABLE todos DROP COLUMN series_index;
+ALTER TABLE todos DROP COLUMN series_id;
+DROP TABLE IF EXISTS series;
@@ -0,0 +1,25 @@
+-- Repeating todos are occurrences of a series. The series holds the template
+-- future occurrences are materialized from.
+CREATE TABLE IF NOT EXISTS series (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	title TEXT NOT NULL,
+	description TEXT DEFAULT '',
+	priority TEXT DEFAULT 'medium',
+	repeat TEXT DEFAULT '',
+	tags TEXT DEFAULT '[]',
+	project_id INTEGER REFERENCES projects(id) ON DELETE SET NULL,
+	copy_subtasks BOOLEAN DEFAULT FALSE,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE todos ADD COLUMN series_id INTEGER REFERENCES series(id) ON DELETE SET NULL;
+ALTER TABLE todos ADD COLUMN series_index INTEGER DEFAULT 0;
+
+-- At most one todo per position in a series; this is what makes
+-- materializing the next occurrence idempotent.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_todos_series ON todos (series_id, series_index) WHERE series_id IS NOT NULL;
+
+-- Every existing repeating todo starts its own series.
+INSERT INTO series (id, title, description, priority, repeat, tags, project_id)
+SELECT id, title, description, priority, repeat, tags, project_id FROM todos WHERE repeat != '';
+UPDATE todos SET series_id = id WHERE repeat != '';
@@ -0,0 +1,4 @@
+ALTER TABLE series DROP COLUMN repeat_catch_up;
+ALTER TABLE series DROP COLUMN repeat_anchor;
+ALTER TABLE todos DROP COLUMN repeat_catch_up;
+ALTER TABLE todos DROP COLUMN repeat_anchor;
@@ -0,0 +1,6 @@
+-- What the next occurrence of a repeating todo is scheduled from: its due
+-- date ('due') or the moment it was completed ('completion').
+ALTER TABLE todos ADD COLUMN repeat_anchor TEXT DEFAULT 'due';
+ALTER TABLE todos ADD COLUMN repeat_catch_up BOOLEAN DEFAULT FALSE;
+ALTER TABLE series ADD COLUMN repeat_anchor TEXT DEFAULT 'due';
+ALTER TABLE series ADD COLUMN repeat_catch_up BOOLEAN DEFAULT FALSE;
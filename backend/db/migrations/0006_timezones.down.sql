@@ -0,0 +1,2 @@
+ALTER TABLE todos DROP COLUMN all_day;
+ALTER TABLE todos DROP COLUMN timezone;
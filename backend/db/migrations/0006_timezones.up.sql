@@ -0,0 +1,4 @@
+-- The IANA zone a todo's dates are interpreted in ('' means UTC), and whether
+-- its due date is a whole calendar day rather than an instant.
+ALTER TABLE todos ADD COLUMN timezone TEXT DEFAULT '';
+ALTER TABLE todos ADD COLUMN all_day BOOLEAN DEFAULT FALSE;
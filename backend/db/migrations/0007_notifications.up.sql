@@ -0,0 +1,16 @@
+-- Delivery state of each reminder. A row is created once per todo and
+-- reminder time, which is what keeps a reminder from firing twice.
+CREATE TABLE IF NOT EXISTS notifications (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	todo_id INTEGER NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+	scheduled_for DATETIME NOT NULL,
+	status TEXT NOT NULL DEFAULT 'scheduled', -- scheduled, sent, failed, snoozed, expired
+	attempts INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT DEFAULT '',
+	next_attempt_at DATETIME,
+	sent_at DATETIME,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (todo_id, scheduled_for)
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_pending ON notifications (status, next_attempt_at);
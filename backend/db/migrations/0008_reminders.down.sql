@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_reminders_todo;
+DROP INDEX IF EXISTS idx_reminders_fire_at;
+DROP TABLE IF EXISTS reminders;
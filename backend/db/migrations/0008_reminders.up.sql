@@ -0,0 +1,14 @@
+-- Reminders beyond a todo's own remind_at. Each is either absolute
+-- (remind_at) or relative to the todo's due date (before_due_minutes);
+-- fire_at is when it goes off, kept up to date as the due date moves.
+CREATE TABLE IF NOT EXISTS reminders (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	todo_id INTEGER NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+	remind_at DATETIME,
+	before_due_minutes INTEGER,
+	fire_at DATETIME,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_reminders_fire_at ON reminders (fire_at);
+CREATE INDEX IF NOT EXISTS idx_reminders_todo ON reminders (todo_id);
@@ -0,0 +1,6 @@
+-- One row per day a daily digest has gone out, so it is sent once even if
+-- the app restarts or several schedulers run.
+CREATE TABLE IF NOT EXISTS digests (
+	date TEXT PRIMARY KEY, -- YYYY-MM-DD in the digest's timezone
+	sent_at DATETIME NOT NULL
+);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_projects_owner;
+DROP INDEX IF EXISTS idx_todos_owner;
+ALTER TABLE projects DROP COLUMN owner_id;
+ALTER TABLE todos DROP COLUMN owner_id;
+DROP TABLE IF EXISTS tokens;
+DROP TABLE IF EXISTS users;
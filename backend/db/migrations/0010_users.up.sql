@@ -0,0 +1,31 @@
+-- Accounts for the HTTP API. Passwords are stored as bcrypt hashes.
+CREATE TABLE IF NOT EXISTS users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	username TEXT NOT NULL UNIQUE COLLATE NOCASE,
+	password_hash TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Bearer tokens: sessions from logging in ('session', which expire) and
+-- personal API tokens for scripts ('api'). Only a SHA-256 hash of each
+-- token is kept.
+CREATE TABLE IF NOT EXISTS tokens (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	kind TEXT NOT NULL,
+	name TEXT NOT NULL DEFAULT '',
+	hash TEXT NOT NULL UNIQUE,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	expires_at DATETIME,
+	last_used_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_tokens_user ON tokens(user_id, kind);
+
+-- The user a todo or project belongs to. Rows from before there were users
+-- have none and are handed to the first user created.
+ALTER TABLE todos ADD COLUMN owner_id INTEGER;
+ALTER TABLE projects ADD COLUMN owner_id INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_todos_owner ON todos(owner_id);
+CREATE INDEX IF NOT EXISTS idx_projects_owner ON projects(owner_id);
@@ -0,0 +1,18 @@
+-- Without the column the trash would come back to life, so empty it first.
+DELETE FROM subtasks WHERE deleted_at IS NOT NULL OR todo_id IN (SELECT id FROM todos WHERE deleted_at IS NOT NULL);
+DELETE FROM reminders WHERE todo_id IN (SELECT id FROM todos WHERE deleted_at IS NOT NULL);
+DELETE FROM notifications WHERE todo_id IN (SELECT id FROM todos WHERE deleted_at IS NOT NULL);
+DELETE FROM todos WHERE deleted_at IS NOT NULL;
+UPDATE todos SET project_id = NULL WHERE project_id IN (SELECT id FROM projects WHERE deleted_at IS NOT NULL);
+UPDATE series SET project_id = NULL WHERE project_id IN (SELECT id FROM projects WHERE deleted_at IS NOT NULL);
+DELETE FROM projects WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_todos_series;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_todos_series ON todos (series_id, series_index) WHERE series_id IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_subtasks_deleted_at;
+DROP INDEX IF EXISTS idx_todos_deleted_at;
+DROP INDEX IF EXISTS idx_projects_deleted_at;
+ALTER TABLE subtasks DROP COLUMN deleted_at;
+ALTER TABLE todos DROP COLUMN deleted_at;
+ALTER TABLE projects DROP COLUMN deleted_at;
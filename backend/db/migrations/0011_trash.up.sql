@@ -0,0 +1,15 @@
+-- Deleting a todo, project or subtask moves it to the trash: deleted_at is
+-- when that happened, NULL for live rows. Trashed rows are purged for good
+-- once they are older than the retention.
+ALTER TABLE projects ADD COLUMN deleted_at DATETIME;
+ALTER TABLE todos ADD COLUMN deleted_at DATETIME;
+ALTER TABLE subtasks ADD COLUMN deleted_at DATETIME;
+
+CREATE INDEX IF NOT EXISTS idx_projects_deleted_at ON projects(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_todos_deleted_at ON todos(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_subtasks_deleted_at ON subtasks(deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- A trashed occurrence gives up its place in the series, so the next one
+-- can still be materialized.
+DROP INDEX IF EXISTS idx_todos_series;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_todos_series ON todos (series_id, series_index) WHERE series_id IS NOT NULL AND deleted_at IS NULL;
@@ -0,0 +1,17 @@
+DROP TRIGGER IF EXISTS series_command_delete;
+DROP TRIGGER IF EXISTS series_command_update;
+DROP TRIGGER IF EXISTS series_command_insert;
+DROP TRIGGER IF EXISTS reminders_command_delete;
+DROP TRIGGER IF EXISTS reminders_command_update;
+DROP TRIGGER IF EXISTS reminders_command_insert;
+DROP TRIGGER IF EXISTS subtasks_command_delete;
+DROP TRIGGER IF EXISTS subtasks_command_update;
+DROP TRIGGER IF EXISTS subtasks_command_insert;
+DROP TRIGGER IF EXISTS todos_command_delete;
+DROP TRIGGER IF EXISTS todos_command_update;
+DROP TRIGGER IF EXISTS todos_command_insert;
+DROP TRIGGER IF EXISTS projects_command_delete;
+DROP TRIGGER IF EXISTS projects_command_update;
+DROP TRIGGER IF EXISTS projects_command_insert;
+DROP TABLE IF EXISTS command_changes;
+DROP TABLE IF EXISTS command_log;
@@ -0,0 +1,161 @@
+-- Undo and redo. Each change made through the API is a command; while it
+-- runs, its row in command_log is 'recording' and the triggers below copy
+-- every row it inserts, updates or deletes into command_changes, as JSON
+-- images of the row before and after (NULL for a row that didn't exist).
+-- A command is only ever 'recording' inside its own transaction.
+CREATE TABLE IF NOT EXISTS command_log (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	session_id INTEGER NOT NULL, -- the token that made it, 0 without sign-in
+	name TEXT NOT NULL,
+	status TEXT NOT NULL DEFAULT 'recording', -- recording, done, undone
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_command_log_session ON command_log (session_id, status, id);
+CREATE INDEX IF NOT EXISTS idx_command_log_recording ON command_log (status) WHERE status = 'recording';
+
+CREATE TABLE IF NOT EXISTS command_changes (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	command_id INTEGER NOT NULL REFERENCES command_log(id) ON DELETE CASCADE,
+	table_name TEXT NOT NULL,
+	row_id INTEGER NOT NULL,
+	before TEXT,
+	after TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_command_changes_command ON command_changes (command_id, id);
+
+CREATE TRIGGER projects_command_insert AFTER INSERT ON projects
+WHEN EXISTS (SELECT 1 FROM command_log WHERE status = 'recording') BEGIN
+	INSERT INTO command_changes (command_id, table_name, row_id, before, after)
+	SELECT id, 'projects', new.id,
+		NULL,
+		json_object('id', new.id, 'name', new.name, 'description', new.description, 'color', new.color, 'created_at', new.created_at, 'owner_id', new.owner_id, 'deleted_at', new.deleted_at)
+	FROM command_log WHERE status = 'recording';
+END;
+
+CREATE TRIGGER projects_command_update AFTER UPDATE ON projects
+WHEN EXISTS (SELECT 1 FROM command_log WHERE status = 'recording') BEGIN
+	INSERT INTO command_changes (command_id, table_name, row_id, before, after)
+	SELECT id, 'projects', new.id,
+		json_object('id', old.id, 'name', old.name, 'description', old.description, 'color', old.color, 'created_at', old.created_at, 'owner_id', old.owner_id, 'deleted_at', old.deleted_at),
+		json_object('id', new.id, 'name', new.name, 'description', new.description, 'color', new.color, 'created_at', new.created_at, 'owner_id', new.owner_id, 'deleted_at', new.deleted_at)
+	FROM command_log WHERE status = 'recording';
+END;
+
+CREATE TRIGGER projects_command_delete AFTER DELETE ON projects
+WHEN EXISTS (SELECT 1 FROM command_log WHERE status = 'recording') BEGIN
+	INSERT INTO command_changes (command_id, table_name, row_id, before, after)
+	SELECT id, 'projects', old.id,
+		json_object('id', old.id, 'name', old.name, 'description', old.description, 'color', old.color, 'created_at', old.created_at, 'owner_id', old.owner_id, 'deleted_at', old.deleted_at),
+		NULL
+	FROM command_log WHERE status = 'recording';
+END;
+
+CREATE TRIGGER todos_command_insert AFTER INSERT ON todos
+WHEN EXISTS (SELECT 1 FROM command_log WHERE status = 'recording') BEGIN
+	INSERT INTO command_changes (command_id, table_name, row_id, before, after)
+	SELECT id, 'todos', new.id,
+		NULL,
+		json_object('id', new.id, 'title', new.title, 'completed', new.completed, 'created_at', new.created_at, 'priority', new.priority, 'due_date', new.due_date, 'remind_at', new.remind_at, 'repeat', new.repeat, 'description', new.description, 'tags', new.tags, 'project_id', new.project_id, 'series_id', new.series_id, 'series_index', new.series_index, 'repeat_anchor', new.repeat_anchor, 'repeat_catch_up', new.repeat_catch_up, 'timezone', new.timezone, 'all_day', new.all_day, 'owner_id', new.owner_id, 'deleted_at', new.deleted_at)
+	FROM command_log WHERE status = 'recording';
+END;
+
+CREATE TRIGGER todos_command_update AFTER UPDATE ON todos
+WHEN EXISTS (SELECT 1 FROM command_log WHERE status = 'recording') BEGIN
+	INSERT INTO command_changes (command_id, table_name, row_id, before, after)
+	SELECT id, 'todos', new.id,
+		json_object('id', old.id, 'title', old.title, 'completed', old.completed, 'created_at', old.created_at, 'priority', old.priority, 'due_date', old.due_date, 'remind_at', old.remind_at, 'repeat', old.repeat, 'description', old.description, 'tags', old.tags, 'project_id', old.project_id, 'series_id', old.series_id, 'series_index', old.series_index, 'repeat_anchor', old.repeat_anchor, 'repeat_catch_up', old.repeat_catch_up, 'timezone', old.timezone, 'all_day', old.all_day, 'owner_id', old.owner_id, 'deleted_at', old.deleted_at),
+		json_object('id', new.id, 'title', new.title, 'completed', new.completed, 'created_at', new.created_at, 'priority', new.priority, 'due_date', new.due_date, 'remind_at', new.remind_at, 'repeat', new.repeat, 'description', new.description, 'tags', new.tags, 'project_id', new.project_id, 'series_id', new.series_id, 'series_index', new.series_index, 'repeat_anchor', new.repeat_anchor, 'repeat_catch_up', new.repeat_catch_up, 'timezone', new.timezone, 'all_day', new.all_day, 'owner_id', new.owner_id, 'deleted_at', new.deleted_at)
+	FROM command_log WHERE status = 'recording';
+END;
+
+CREATE TRIGGER todos_command_delete AFTER DELETE ON todos
+WHEN EXISTS (SELECT 1 FROM command_log WHERE status = 'recording') BEGIN
+	INSERT INTO command_changes (command_id, table_name, row_id, before, after)
+	SELECT id, 'todos', old.id,
+		json_object('id', old.id, 'title', old.title, 'completed', old.completed, 'created_at', old.created_at, 'priority', old.priority, 'due_date', old.due_date, 'remind_at', old.remind_at, 'repeat', old.repeat, 'description', old.description, 'tags', old.tags, 'project_id', old.project_id, 'series_id', old.series_id, 'series_index', old.series_index, 'repeat_anchor', old.repeat_anchor, 'repeat_catch_up', old.repeat_catch_up, 'timezone', old.timezone, 'all_day', old.all_day, 'owner_id', old.owner_id, 'deleted_at', old.deleted_at),
+		NULL
+	FROM command_log WHERE status = 'recording';
+END;
+
+CREATE TRIGGER subtasks_command_insert AFTER INSERT ON subtasks
+WHEN EXISTS (SELECT 1 FROM command_log WHERE status = 'recording') BEGIN
+	INSERT INTO command_changes (command_id, table_name, row_id, before, after)
+	SELECT id, 'subtasks', new.id,
+		NULL,
+		json_object('id', new.id, 'todo_id', new.todo_id, 'title', new.title, 'completed', new.completed, 'created_at', new.created_at, 'deleted_at', new.deleted_at)
+	FROM command_log WHERE status = 'recording';
+END;
+
+CREATE TRIGGER subtasks_command_update AFTER UPDATE ON subtasks
+WHEN EXISTS (SELECT 1 FROM command_log WHERE status = 'recording') BEGIN
+	INSERT INTO command_changes (command_id, table_name, row_id, before, after)
+	SELECT id, 'subtasks', new.id,
+		json_object('id', old.id, 'todo_id', old.todo_id, 'title', old.title, 'completed', old.completed, 'created_at', old.created_at, 'deleted_at', old.deleted_at),
+		json_object('id', new.id, 'todo_id', new.todo_id, 'title', new.title, 'completed', new.completed, 'created_at', new.created_at, 'deleted_at', new.deleted_at)
+	FROM command_log WHERE status = 'recording';
+END;
+
+CREATE TRIGGER subtasks_command_delete AFTER DELETE ON subtasks
+WHEN EXISTS (SELECT 1 FROM command_log WHERE status = 'recording') BEGIN
+	INSERT INTO command_changes (command_id, table_name, row_id, before, after)
+	SELECT id, 'subtasks', old.id,
+		json_object('id', old.id, 'todo_id', old.todo_id, 'title', old.title, 'completed', old.completed, 'created_at', old.created_at, 'deleted_at', old.deleted_at),
+		NULL
+	FROM command_log WHERE status = 'recording';
+END;
+
+CREATE TRIGGER reminders_command_insert AFTER INSERT ON reminders
+WHEN EXISTS (SELECT 1 FROM command_log WHERE status = 'recording') BEGIN
+	INSERT INTO command_changes (command_id, table_name, row_id, before, after)
+	SELECT id, 'reminders', new.id,
+		NULL,
+		json_object('id', new.id, 'todo_id', new.todo_id, 'remind_at', new.remind_at, 'before_due_minutes', new.before_due_minutes, 'fire_at', new.fire_at, 'created_at', new.created_at)
+	FROM command_log WHERE status = 'recording';
+END;
+
+CREATE TRIGGER reminders_command_update AFTER UPDATE ON reminders
+WHEN EXISTS (SELECT 1 FROM command_log WHERE status = 'recording') BEGIN
+	INSERT INTO command_changes (command_id, table_name, row_id, before, after)
+	SELECT id, 'reminders', new.id,
+		json_object('id', old.id, 'todo_id', old.todo_id, 'remind_at', old.remind_at, 'before_due_minutes', old.before_due_minutes, 'fire_at', old.fire_at, 'created_at', old.created_at),
+		json_object('id', new.id, 'todo_id', new.todo_id, 'remind_at', new.remind_at, 'before_due_minutes', new.before_due_minutes, 'fire_at', new.fire_at, 'created_at', new.created_at)
+	FROM command_log WHERE status = 'recording';
+END;
+
+CREATE TRIGGER reminders_command_delete AFTER DELETE ON reminders
+WHEN EXISTS (SELECT 1 FROM command_log WHERE status = 'recording') BEGIN
+	INSERT INTO command_changes (command_id, table_name, row_id, before, after)
+	SELECT id, 'reminders', old.id,
+		json_object('id', old.id, 'todo_id', old.todo_id, 'remind_at', old.remind_at, 'before_due_minutes', old.before_due_minutes, 'fire_at', old.fire_at, 'created_at', old.created_at),
+		NULL
+	FROM command_log WHERE status = 'recording';
+END;
+
+CREATE TRIGGER series_command_insert AFTER INSERT ON series
+WHEN EXISTS (SELECT 1 FROM command_log WHERE status = 'recording') BEGIN
+	INSERT INTO command_changes (command_id, table_name, row_id, before, after)
+	SELECT id, 'series', new.id,
+		NULL,
+		json_object('id', new.id, 'title', new.title, 'description', new.description, 'priority', new.priority, 'repeat', new.repeat, 'tags', new.tags, 'project_id', new.project_id, 'copy_subtasks', new.copy_subtasks, 'created_at', new.created_at, 'repeat_anchor', new.repeat_anchor, 'repeat_catch_up', new.repeat_catch_up)
+	FROM command_log WHERE status = 'recording';
+END;
+
+CREATE TRIGGER series_command_update AFTER UPDATE ON series
+WHEN EXISTS (SELECT 1 FROM command_log WHERE status = 'recording') BEGIN
+	INSERT INTO command_changes (command_id, table_name, row_id, before, after)
+	SELECT id, 'series', new.id,
+		json_object('id', old.id, 'title', old.title, 'description', old.description, 'priority', old.priority, 'repeat', old.repeat, 'tags', old.tags, 'project_id', old.project_id, 'copy_subtasks', old.copy_subtasks, 'created_at', old.created_at, 'repeat_anchor', old.repeat_anchor, 'repeat_catch_up', old.repeat_catch_up),
+		json_object('id', new.id, 'title', new.title, 'description', new.description, 'priority', new.priority, 'repeat', new.repeat, 'tags', new.tags, 'project_id', new.project_id, 'copy_subtasks', new.copy_subtasks, 'created_at', new.created_at, 'repeat_anchor', new.repeat_anchor, 'repeat_catch_up', new.repeat_catch_up)
+	FROM command_log WHERE status = 'recording';
+END;
+
+CREATE TRIGGER series_command_delete AFTER DELETE ON series
+WHEN EXISTS (SELECT 1 FROM command_log WHERE status = 'recording') BEGIN
+	INSERT INTO command_changes (command_id, table_name, row_id, before, after)
+	SELECT id, 'series', old.id,
+		json_object('id', old.id, 'title', old.title, 'description', old.description, 'priority', old.priority, 'repeat', old.repeat, 'tags', old.tags, 'project_id', old.project_id, 'copy_subtasks', old.copy_subtasks, 'created_at', old.created_at, 'repeat_anchor', old.repeat_anchor, 'repeat_catch_up', old.repeat_catch_up),
+		NULL
+	FROM command_log WHERE status = 'recording';
+END;
@@ -0,0 +1,107 @@
+DROP TRIGGER IF EXISTS projects_command_insert;
+DROP TRIGGER IF EXISTS projects_command_update;
+DROP TRIGGER IF EXISTS projects_command_delete;
+DROP TRIGGER IF EXISTS todos_command_insert;
+DROP TRIGGER IF EXISTS todos_command_update;
+DROP TRIGGER IF EXISTS todos_command_delete;
+DROP TRIGGER IF EXISTS subtasks_command_insert;
+DROP TRIGGER IF EXISTS subtasks_command_update;
+DROP TRIGGER IF EXISTS subtasks_command_delete;
+DROP TRIGGER IF EXISTS todos_events_insert;
+DROP TRIGGER IF EXISTS todos_events_update;
+DROP TRIGGER IF EXISTS todos_events_delete;
+DROP TRIGGER IF EXISTS subtasks_events_insert;
+DROP TRIGGER IF EXISTS subtasks_events_update;
+DROP TRIGGER IF EXISTS subtasks_events_delete;
+DROP TRIGGER IF EXISTS projects_events_insert;
+DROP TRIGGER IF EXISTS projects_events_update;
+DROP TRIGGER IF EXISTS projects_events_delete;
+DROP INDEX IF EXISTS idx_command_log_active;
+DROP TABLE IF EXISTS events;
+DROP INDEX IF EXISTS idx_todos_completed_at;
+ALTER TABLE projects DROP COLUMN updated_at;
+ALTER TABLE subtasks DROP COLUMN completed_at;
+ALTER TABLE subtasks DROP COLUMN updated_at;
+ALTER TABLE todos DROP COLUMN completed_at;
+ALTER TABLE todos DROP COLUMN updated_at;
+
+CREATE TRIGGER projects_command_insert AFTER INSERT ON projects
+WHEN EXISTS (SELECT 1 FROM command_log WHERE status = 'recording') BEGIN
+	INSERT INTO command_changes (command_id, table_name, row_id, before, after)
+	SELECT id, 'projects', new.id,
+		NULL,
+		json_object('id', new.id, 'name', new.name, 'description', new.description, 'color', new.color, 'created_at', new.created_at, 'owner_id', new.owner_id, 'deleted_at', new.deleted_at)
+	FROM command_log WHERE status = 'recording';
+END;
+
+CREATE TRIGGER projects_command_update AFTER UPDATE ON projects
+WHEN EXISTS (SELECT 1 FROM command_log WHERE status = 'recording') BEGIN
+	INSERT INTO command_changes (command_id, table_name, row_id, before, after)
+	SELECT id, 'projects', new.id,
+		json_object('id', old.id, 'name', old.name, 'description', old.description, 'color', old.color, 'created_at', old.created_at, 'owner_id', old.owner_id, 'deleted_at', old.deleted_at),
+		json_object('id', new.id, 'name', new.name, 'description', new.description, 'color', new.color, 'created_at', new.created_at, 'owner_id', new.owner_id, 'deleted_at', new.deleted_at)
+	FROM command_log WHERE status = 'recording';
+END;
+
+CREATE TRIGGER projects_command_delete AFTER DELETE ON projects
+WHEN EXISTS (SELECT 1 FROM command_log WHERE status = 'recording') BEGIN
+	INSERT INTO command_changes (command_id, table_name, row_id, before, after)
+	SELECT id, 'projects', old.id,
+		json_object('id', old.id, 'name', old.name, 'description', old.description, 'color', old.color, 'created_at', old.created_at, 'owner_id', old.owner_id, 'deleted_at', old.deleted_at),
+		NULL
+	FROM command_log WHERE status = 'recording';
+END;
+
+CREATE TRIGGER todos_command_insert AFTER INSERT ON todos
+WHEN EXISTS (SELECT 1 FROM command_log WHERE status = 'recording') BEGIN
+	INSERT INTO command_changes (command_id, table_name, row_id, before, after)
+	SELECT id, 'todos', new.id,
+		NULL,
+		json_object('id', new.id, 'title', new.title, 'completed', new.completed, 'created_at', new.created_at, 'priority', new.priority, 'due_date', new.due_date, 'remind_at', new.remind_at, 'repeat', new.repeat, 'description', new.description, 'tags', new.tags, 'project_id', new.project_id, 'series_id', new.series_id, 'series_index', new.series_index, 'repeat_anchor', new.repeat_anchor, 'repeat_catch_up', new.repeat_catch_up, 'timezone', new.timezone, 'all_day', new.all_day, 'owner_id', new.owner_id, 'deleted_at', new.deleted_at)
+	FROM command_log WHERE status = 'recording';
+END;
+
+CREATE TRIGGER todos_command_update AFTER UPDATE ON todos
+WHEN EXISTS (SELECT 1 FROM command_log WHERE status = 'recording') BEGIN
+	INSERT INTO command_changes (command_id, table_name, row_id, before, after)
+	SELECT id, 'todos', new.id,
+		json_object('id', old.id, 'title', old.title, 'completed', old.completed, 'created_at', old.created_at, 'priority', old.priority, 'due_date', old.due_date, 'remind_at', old.remind_at, 'repeat', old.repeat, 'description', old.description, 'tags', old.tags, 'project_id', old.project_id, 'series_id', old.series_id, 'series_index', old.series_index, 'repeat_anchor', old.repeat_anchor, 'repeat_catch_up', old.repeat_catch_up, 'timezone', old.timezone, 'all_day', old.all_day, 'owner_id', old.owner_id, 'deleted_at', old.deleted_at),
+		json_object('id', new.id, 'title', new.title, 'completed', new.completed, 'created_at', new.created_at, 'priority', new.priority, 'due_date', new.due_date, 'remind_at', new.remind_at, 'repeat', new.repeat, 'description', new.description, 'tags', new.tags, 'project_id', new.project_id, 'series_id', new.series_id, 'series_index', new.series_index, 'repeat_anchor', new.repeat_anchor, 'repeat_catch_up', new.repeat_catch_up, 'timezone', new.timezone, 'all_day', new.all_day, 'owner_id', new.owner_id, 'deleted_at', new.deleted_at)
+	FROM command_log WHERE status = 'recording';
+END;
+
+CREATE TRIGGER todos_command_delete AFTER DELETE ON todos
+WHEN EXISTS (SELECT 1 FROM command_log WHERE status = 'recording') BEGIN
+	INSERT INTO command_changes (command_id, table_name, row_id, before, after)
+	SELECT id, 'todos', old.id,
+		json_object('id', old.id, 'title', old.title, 'completed', old.completed, 'created_at', old.created_at, 'priority', old.priority, 'due_date', old.due_date, 'remind_at', old.remind_at, 'repeat', old.repeat, 'description', old.description, 'tags', old.tags, 'project_id', old.project_id, 'series_id', old.series_id, 'series_index', old.series_index, 'repeat_anchor', old.repeat_anchor, 'repeat_catch_up', old.repeat_catch_up, 'timezone', old.timezone, 'all_day', old.all_day, 'owner_id', old.owner_id, 'deleted_at', old.deleted_at),
+		NULL
+	FROM command_log WHERE status = 'recording';
+END;
+
+CREATE TRIGGER subtasks_command_insert AFTER INSERT ON subtasks
+WHEN EXISTS (SELECT 1 FROM command_log WHERE status = 'recording') BEGIN
+	INSERT INTO command_changes (command_id, table_name, row_id, before, after)
+	SELECT id, 'subtasks', new.id,
+		NULL,
+		json_object('id', new.id, 'todo_id', new.todo_id, 'title', new.title, 'completed', new.completed, 'created_at', new.created_at, 'deleted_at', new.deleted_at)
+	FROM command_log WHERE status = 'recording';
+END;
+
+CREATE TRIGGER subtasks_command_update AFTER UPDATE ON subtasks
+WHEN EXISTS (SELECT 1 FROM command_log WHERE status = 'recording') BEGIN
+	INSERT INTO command_changes (command_id, table_name, row_id, before, after)
+	SELECT id, 'subtasks', new.id,
+		json_object('id', old.id, 'todo_id', old.todo_id, 'title', old.title, 'completed', old.completed, 'created_at', old.created_at, 'deleted_at', old.deleted_at),
+		json_object('id', new.id, 'todo_id', new.todo_id, 'title', new.title, 'completed', new.completed, 'created_at', new.created_at, 'deleted_at', new.deleted_at)
+	FROM command_log WHERE status = 'recording';
+END;
+
+CREATE TRIGGER subtasks_command_delete AFTER DELETE ON subtasks
+WHEN EXISTS (SELECT 1 FROM command_log WHERE status = 'recording') BEGIN
+	INSERT INTO command_changes (command_id, table_name, row_id, before, after)
+	SELECT id, 'subtasks', old.id,
+		json_object('id', old.id, 'todo_id', old.todo_id, 'title', old.title, 'completed', old.completed, 'created_at', old.created_at, 'deleted_at', old.deleted_at),
+		NULL
+	FROM command_log WHERE status = 'recording';
+END;
@@ -0,0 +1,198 @@
+-- When todos, subtasks and projects were last changed, and when todos and
+-- subtasks were completed. Both are set by the service; rows from before
+-- count as last changed when they were created, and completed ones as
+-- completed at an unknown time.
+ALTER TABLE todos ADD COLUMN updated_at DATETIME;
+ALTER TABLE todos ADD COLUMN completed_at DATETIME;
+ALTER TABLE subtasks ADD COLUMN updated_at DATETIME;
+ALTER TABLE subtasks ADD COLUMN completed_at DATETIME;
+ALTER TABLE projects ADD COLUMN updated_at DATETIME;
+
+UPDATE todos SET updated_at = created_at;
+UPDATE subtasks SET updated_at = created_at;
+UPDATE projects SET updated_at = created_at;
+
+CREATE INDEX IF NOT EXISTS idx_todos_completed_at ON todos(completed_at) WHERE completed_at IS NOT NULL;
+
+-- The history of todos, subtasks and projects: one row when one is created
+-- and one per field that changes, with its old and new value as stored.
+-- The user is the one whose token made the change through the API, NULL for
+-- changes made locally or without sign-in. A row's history goes with it
+-- when it is deleted for good.
+CREATE TABLE IF NOT EXISTS events (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	kind TEXT NOT NULL, -- todo, subtask or project
+	ref_id INTEGER NOT NULL, -- of the todo, subtask or project
+	todo_id INTEGER, -- the todo, or the subtask's; NULL for projects
+	user_id INTEGER,
+	action TEXT NOT NULL, -- created or updated
+	field TEXT NOT NULL DEFAULT '', -- what an update changed
+	old_value,
+	new_value,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_events_todo ON events (todo_id, id);
+CREATE INDEX IF NOT EXISTS idx_events_ref ON events (kind, ref_id, id);
+
+-- Commands being undone or redone are 'replaying'; the user who does it is
+-- looked up through them as through the one recording.
+CREATE INDEX IF NOT EXISTS idx_command_log_active ON command_log (status) WHERE status IN ('recording', 'replaying');
+
+CREATE TRIGGER todos_events_insert AFTER INSERT ON todos BEGIN
+	INSERT INTO events (kind, ref_id, todo_id, user_id, action)
+	VALUES ('todo', new.id, new.id, (SELECT t.user_id FROM command_log c JOIN tokens t ON t.id = c.session_id WHERE c.status IN ('recording', 'replaying')), 'created');
+END;
+
+CREATE TRIGGER todos_events_update AFTER UPDATE ON todos BEGIN
+	INSERT INTO events (kind, ref_id, todo_id, user_id, action, field, old_value, new_value)
+	SELECT 'todo', new.id, new.id, (SELECT t.user_id FROM command_log c JOIN tokens t ON t.id = c.session_id WHERE c.status IN ('recording', 'replaying')), 'updated', field, old_value, new_value FROM (
+		SELECT 'title' AS field, old.title AS old_value, new.title AS new_value
+		UNION ALL SELECT 'description', old.description, new.description
+		UNION ALL SELECT 'completed', old.completed, new.completed
+		UNION ALL SELECT 'priority', old.priority, new.priority
+		UNION ALL SELECT 'due_date', old.due_date, new.due_date
+		UNION ALL SELECT 'remind_at', old.remind_at, new.remind_at
+		UNION ALL SELECT 'timezone', old.timezone, new.timezone
+		UNION ALL SELECT 'all_day', old.all_day, new.all_day
+		UNION ALL SELECT 'repeat', old.repeat, new.repeat
+		UNION ALL SELECT 'repeat_anchor', old.repeat_anchor, new.repeat_anchor
+		UNION ALL SELECT 'repeat_catch_up', old.repeat_catch_up, new.repeat_catch_up
+		UNION ALL SELECT 'tags', old.tags, new.tags
+		UNION ALL SELECT 'project_id', old.project_id, new.project_id
+		UNION ALL SELECT 'deleted_at', old.deleted_at, new.deleted_at
+	) WHERE old_value IS NOT new_value;
+END;
+
+CREATE TRIGGER todos_events_delete AFTER DELETE ON todos BEGIN
+	DELETE FROM events WHERE todo_id = old.id;
+END;
+
+CREATE TRIGGER subtasks_events_insert AFTER INSERT ON subtasks BEGIN
+	INSERT INTO events (kind, ref_id, todo_id, user_id, action)
+	VALUES ('subtask', new.id, new.todo_id, (SELECT t.user_id FROM command_log c JOIN tokens t ON t.id = c.session_id WHERE c.status IN ('recording', 'replaying')), 'created');
+END;
+
+CREATE TRIGGER subtasks_events_update AFTER UPDATE ON subtasks BEGIN
+	INSERT INTO events (kind, ref_id, todo_id, user_id, action, field, old_value, new_value)
+	SELECT 'subtask', new.id, new.todo_id, (SELECT t.user_id FROM command_log c JOIN tokens t ON t.id = c.session_id WHERE c.status IN ('recording', 'replaying')), 'updated', field, old_value, new_value FROM (
+		SELECT 'title' AS field, old.title AS old_value, new.title AS new_value
+		UNION ALL SELECT 'completed', old.completed, new.completed
+		UNION ALL SELECT 'deleted_at', old.deleted_at, new.deleted_at
+	) WHERE old_value IS NOT new_value;
+END;
+
+CREATE TRIGGER subtasks_events_delete AFTER DELETE ON subtasks BEGIN
+	DELETE FROM events WHERE kind = 'subtask' AND ref_id = old.id;
+END;
+
+CREATE TRIGGER projects_events_insert AFTER INSERT ON projects BEGIN
+	INSERT INTO events (kind, ref_id, todo_id, user_id, action)
+	VALUES ('project', new.id, NULL, (SELECT t.user_id FROM command_log c JOIN tokens t ON t.id = c.session_id WHERE c.status IN ('recording', 'replaying')), 'created');
+END;
+
+CREATE TRIGGER projects_events_update AFTER UPDATE ON projects BEGIN
+	INSERT INTO events (kind, ref_id, todo_id, user_id, action, field, old_value, new_value)
+	SELECT 'project', new.id, NULL, (SELECT t.user_id FROM command_log c JOIN tokens t ON t.id = c.session_id WHERE c.status IN ('recording', 'replaying')), 'updated', field, old_value, new_value FROM (
+		SELECT 'name' AS field, old.name AS old_value, new.name AS new_value
+		UNION ALL SELECT 'description', old.description, new.description
+		UNION ALL SELECT 'color', old.color, new.color
+		UNION ALL SELECT 'deleted_at', old.deleted_at, new.deleted_at
+	) WHERE old_value IS NOT new_value;
+END;
+
+CREATE TRIGGER projects_events_delete AFTER DELETE ON projects BEGIN
+	DELETE FROM events WHERE kind = 'project' AND ref_id = old.id;
+END;
+
+-- Undo has to restore the new columns too.
+DROP TRIGGER IF EXISTS projects_command_insert;
+DROP TRIGGER IF EXISTS projects_command_update;
+DROP TRIGGER IF EXISTS projects_command_delete;
+DROP TRIGGER IF EXISTS todos_command_insert;
+DROP TRIGGER IF EXISTS todos_command_update;
+DROP TRIGGER IF EXISTS todos_command_delete;
+DROP TRIGGER IF EXISTS subtasks_command_insert;
+DROP TRIGGER IF EXISTS subtasks_command_update;
+DROP TRIGGER IF EXISTS subtasks_command_delete;
+
+CREATE TRIGGER projects_command_insert AFTER INSERT ON projects
+WHEN EXISTS (SELECT 1 FROM command_log WHERE status = 'recording') BEGIN
+	INSERT INTO command_changes (command_id, table_name, row_id, before, after)
+	SELECT id, 'projects', new.id,
+		NULL,
+		json_object('id', new.id, 'name', new.name, 'description', new.description, 'color', new.color, 'created_at', new.created_at, 'owner_id', new.owner_id, 'deleted_at', new.deleted_at, 'updated_at', new.updated_at)
+	FROM command_log WHERE status = 'recording';
+END;
+
+CREATE TRIGGER projects_command_update AFTER UPDATE ON projects
+WHEN EXISTS (SELECT 1 FROM command_log WHERE status = 'recording') BEGIN
+	INSERT INTO command_changes (command_id, table_name, row_id, before, after)
+	SELECT id, 'projects', new.id,
+		json_object('id', old.id, 'name', old.name, 'description', old.description, 'color', old.color, 'created_at', old.created_at, 'owner_id', old.owner_id, 'deleted_at', old.deleted_at, 'updated_at', old.updated_at),
+		json_object('id', new.id, 'name', new.name, 'description', new.description, 'color', new.color, 'created_at', new.created_at, 'owner_id', new.owner_id, 'deleted_at', new.deleted_at, 'updated_at', new.updated_at)
+	FROM command_log WHERE status = 'recording';
+END;
+
+CREATE TRIGGER projects_command_delete AFTER DELETE ON projects
+WHEN EXISTS (SELECT 1 FROM command_log WHERE status = 'recording') BEGIN
+	INSERT INTO command_changes (command_id, table_name, row_id, before, after)
+	SELECT id, 'projects', old.id,
+		json_object('id', old.id, 'name', old.name, 'description', old.description, 'color', old.color, 'created_at', old.created_at, 'owner_id', old.owner_id, 'deleted_at', old.deleted_at, 'updated_at', old.updated_at),
+		NULL
+	FROM command_log WHERE status = 'recording';
+END;
+
+CREATE TRIGGER todos_command_insert AFTER INSERT ON todos
+WHEN EXISTS (SELECT 1 FROM command_log WHERE status = 'recording') BEGIN
+	INSERT INTO command_changes (command_id, table_name, row_id, before, after)
+	SELECT id, 'todos', new.id,
+		NULL,
+		json_object('id', new.id, 'title', new.title, 'completed', new.completed, 'created_at', new.created_at, 'priority', new.priority, 'due_date', new.due_date, 'remind_at', new.remind_at, 'repeat', new.repeat, 'description', new.description, 'tags', new.tags, 'project_id', new.project_id, 'series_id', new.series_id, 'series_index', new.series_index, 'repeat_anchor', new.repeat_anchor, 'repeat_catch_up', new.repeat_catch_up, 'timezone', new.timezone, 'all_day', new.all_day, 'owner_id', new.owner_id, 'deleted_at', new.deleted_at, 'updated_at', new.updated_at, 'completed_at', new.completed_at)
+	FROM command_log WHERE status = 'recording';
+END;
+
+CREATE TRIGGER todos_command_update AFTER UPDATE ON todos
+WHEN EXISTS (SELECT 1 FROM command_log WHERE status = 'recording') BEGIN
+	INSERT INTO command_changes (command_id, table_name, row_id, before, after)
+	SELECT id, 'todos', new.id,
+		json_object('id', old.id, 'title', old.title, 'completed', old.completed, 'created_at', old.created_at, 'priority', old.priority, 'due_date', old.due_date, 'remind_at', old.remind_at, 'repeat', old.repeat, 'description', old.description, 'tags', old.tags, 'project_id', old.project_id, 'series_id', old.series_id, 'series_index', old.series_index, 'repeat_anchor', old.repeat_anchor, 'repeat_catch_up', old.repeat_catch_up, 'timezone', old.timezone, 'all_day', old.all_day, 'owner_id', old.owner_id, 'deleted_at', old.deleted_at, 'updated_at', old.updated_at, 'completed_at', old.completed_at),
+		json_object('id', new.id, 'title', new.title, 'completed', new.completed, 'created_at', new.created_at, 'priority', new.priority, 'due_date', new.due_date, 'remind_at', new.remind_at, 'repeat', new.repeat, 'description', new.description, 'tags', new.tags, 'project_id', new.project_id, 'series_id', new.series_id, 'series_index', new.series_index, 'repeat_anchor', new.repeat_anchor, 'repeat_catch_up', new.repeat_catch_up, 'timezone', new.timezone, 'all_day', new.all_day, 'owner_id', new.owner_id, 'deleted_at', new.deleted_at, 'updated_at', new.updated_at, 'completed_at', new.completed_at)
+	FROM command_log WHERE status = 'recording';
+END;
+
+CREATE TRIGGER todos_command_delete AFTER DELETE ON todos
+WHEN EXISTS (SELECT 1 FROM command_log WHERE status = 'recording') BEGIN
+	INSERT INTO command_changes (command_id, table_name, row_id, before, after)
+	SELECT id, 'todos', old.id,
+		json_object('id', old.id, 'title', old.title, 'completed', old.completed, 'created_at', old.created_at, 'priority', old.priority, 'due_date', old.due_date, 'remind_at', old.remind_at, 'repeat', old.repeat, 'description', old.description, 'tags', old.tags, 'project_id', old.project_id, 'series_id', old.series_id, 'series_index', old.series_index, 'repeat_anchor', old.repeat_anchor, 'repeat_catch_up', old.repeat_catch_up, 'timezone', old.timezone, 'all_day', old.all_day, 'owner_id', old.owner_id, 'deleted_at', old.deleted_at, 'updated_at', old.updated_at, 'completed_at', old.completed_at),
+		NULL
+	FROM command_log WHERE status = 'recording';
+END;
+
+CREATE TRIGGER subtasks_command_insert AFTER INSERT ON subtasks
+WHEN EXISTS (SELECT 1 FROM command_log WHERE status = 'recording') BEGIN
+	INSERT INTO command_changes (command_id, table_name, row_id, before, after)
+	SELECT id, 'subtasks', new.id,
+		NULL,
+		json_object('id', new.id, 'todo_id', new.todo_id, 'title', new.title, 'completed', new.completed, 'created_at', new.created_at, 'deleted_at', new.deleted_at, 'updated_at', new.updated_at, 'completed_at', new.completed_at)
+	FROM command_log WHERE status = 'recording';
+END;
+
+CREATE TRIGGER subtasks_command_update AFTER UPDATE ON subtasks
+WHEN EXISTS (SELECT 1 FROM command_log WHERE status = 'recording') BEGIN
+	INSERT INTO command_changes (command_id, table_name, row_id, before, after)
+	SELECT id, 'subtasks', new.id,
+		json_object('id', old.id, 'todo_id', old.todo_id, 'title', old.title, 'completed', old.completed, 'created_at', old.created_at, 'deleted_at', old.deleted_at, 'updated_at', old.updated_at, 'completed_at', old.completed_at),
+		json_object('id', new.id, 'todo_id', new.todo_id, 'title', new.title, 'completed', new.completed, 'created_at', new.created_at, 'deleted_at', new.deleted_at, 'updated_at', new.updated_at, 'completed_at', new.completed_at)
+	FROM command_log WHERE status = 'recording';
+END;
+
+CREATE TRIGGER subtasks_command_delete AFTER DELETE ON subtasks
+WHEN EXISTS (SELECT 1 FROM command_log WHERE status = 'recording') BEGIN
+	INSERT INTO command_changes (command_id, table_name, row_id, before, after)
+	SELECT id, 'subtasks', old.id,
+		json_object('id', old.id, 'todo_id', old.todo_id, 'title', old.title, 'completed', old.completed, 'created_at', old.created_at, 'deleted_at', old.deleted_at, 'updated_at', old.updated_at, 'completed_at', old.completed_at),
+		NULL
+	FROM command_log WHERE status = 'recording';
+END;
@@ -0,0 +1,7 @@
+CREATE TABLE digests_by_date (
+	date TEXT PRIMARY KEY,
+	sent_at DATETIME NOT NULL
+);
+INSERT INTO digests_by_date (date, sent_at) SELECT date, MIN(sent_at) FROM digests GROUP BY date;
+DROP TABLE digests;
+ALTER TABLE digests_by_date RENAME TO digests;
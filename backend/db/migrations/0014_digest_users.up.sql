@@ -0,0 +1,13 @@
+-- Digests go out per user, so a day's digest is claimed once per user
+-- rather than once. user_id 0 is the digest of everyone's todos, sent when
+-- there are no users. Days already sent count as sent for every user.
+CREATE TABLE digests_by_user (
+	user_id INTEGER NOT NULL DEFAULT 0,
+	date TEXT NOT NULL, -- YYYY-MM-DD in the digest's timezone
+	sent_at DATETIME NOT NULL,
+	PRIMARY KEY (user_id, date)
+);
+INSERT INTO digests_by_user (user_id, date, sent_at) SELECT 0, date, sent_at FROM digests;
+INSERT INTO digests_by_user (user_id, date, sent_at) SELECT u.id, d.date, d.sent_at FROM digests d, users u;
+DROP TABLE digests;
+ALTER TABLE digests_by_user RENAME TO digests;
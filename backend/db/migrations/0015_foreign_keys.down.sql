@@ -0,0 +1,2 @@
+-- The rows cleared on the way up were dangling; there is nothing to restore.
+SELECT 1;
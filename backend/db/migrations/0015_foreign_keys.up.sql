@@ -0,0 +1,10 @@
+-- Foreign keys are enforced from now on. Releases before didn't, so clear
+-- the references they may have left dangling.
+UPDATE todos SET project_id = NULL WHERE project_id IS NOT NULL AND project_id NOT IN (SELECT id FROM projects);
+UPDATE todos SET series_id = NULL WHERE series_id IS NOT NULL AND series_id NOT IN (SELECT id FROM series);
+UPDATE series SET project_id = NULL WHERE project_id IS NOT NULL AND project_id NOT IN (SELECT id FROM projects);
+DELETE FROM subtasks WHERE todo_id NOT IN (SELECT id FROM todos);
+DELETE FROM reminders WHERE todo_id NOT IN (SELECT id FROM todos);
+DELETE FROM notifications WHERE todo_id NOT IN (SELECT id FROM todos);
+DELETE FROM tokens WHERE user_id NOT IN (SELECT id FROM users);
+DELETE FROM command_changes WHERE command_id NOT IN (SELECT id FROM command_log);
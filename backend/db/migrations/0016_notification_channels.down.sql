@@ -0,0 +1 @@
+ALTER TABLE notifications DROP COLUMN delivered;
@@ -0,0 +1,3 @@
+-- The channels, by position, a notification has reached, so retrying a
+-- delivery that failed on some of them only sends it on the others.
+ALTER TABLE notifications ADD COLUMN delivered TEXT NOT NULL DEFAULT '[]';
@@ -0,0 +1,9 @@
+CREATE TABLE digests_claims (
+	user_id INTEGER NOT NULL DEFAULT 0,
+	date TEXT NOT NULL,
+	sent_at DATETIME NOT NULL,
+	PRIMARY KEY (user_id, date)
+);
+INSERT INTO digests_claims (user_id, date, sent_at) SELECT user_id, date, COALESCE(sent_at, next_attempt_at, CURRENT_TIMESTAMP) FROM digests;
+DROP TABLE digests;
+ALTER TABLE digests_claims RENAME TO digests;
@@ -0,0 +1,17 @@
+-- Delivery state of each digest, like that of notifications: a digest is
+-- claimed while 'sending', retried with backoff while 'failed', and skips
+-- the channels in delivered on a retry. Digests recorded so far were sent.
+CREATE TABLE digests_delivery (
+	user_id INTEGER NOT NULL DEFAULT 0,
+	date TEXT NOT NULL, -- YYYY-MM-DD in the digest's timezone
+	status TEXT NOT NULL DEFAULT 'sending', -- sending, sent, failed
+	attempts INTEGER NOT NULL DEFAULT 1,
+	last_error TEXT NOT NULL DEFAULT '',
+	delivered TEXT NOT NULL DEFAULT '[]',
+	next_attempt_at DATETIME,
+	sent_at DATETIME,
+	PRIMARY KEY (user_id, date)
+);
+INSERT INTO digests_delivery (user_id, date, status, sent_at) SELECT user_id, date, 'sent', sent_at FROM digests;
+DROP TABLE digests;
+ALTER TABLE digests_delivery RENAME TO digests;
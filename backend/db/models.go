@@ -1,34 +1,274 @@
 package db
 
-import "time"
+import (
+	"sync"
+	"time"
+)
 
 type Project struct {
-	ID          int       `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	Color       string    `json:"color"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID          int        `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Color       string     `json:"color"`
+	OwnerID     *int       `json:"-"` // user the project belongs to; nil before there were users
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"` // set while the project is in the trash
 }
 
 type Subtask struct {
+	ID          int        `json:"id"`
+	TodoID      int        `json:"todo_id"`
+	Title       string     `json:"title"`
+	Completed   bool       `json:"completed"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"` // set while the subtask is in the trash
+}
+
+type Todo struct {
+	ID            int        `json:"id"`
+	Title         string     `json:"title"`
+	Description   string     `json:"description"`
+	Completed     bool       `json:"completed"`
+	Priority      string     `json:"priority"`
+	DueDate       *time.Time `json:"due_date"`               // UTC instant
+	RemindAt      *time.Time `json:"remind_at"`              // UTC instant
+	TimeZone      string     `json:"timezone"`               // IANA zone, "" for UTC
+	AllDay        bool       `json:"all_day"`                // DueDate is midnight of a calendar day in TimeZone
+	DueLocal      string     `json:"due_local,omitempty"`    // DueDate in TimeZone, filled in when read
+	RemindLocal   string     `json:"remind_local,omitempty"` // RemindAt in TimeZone, filled in when read
+	Repeat        string     `json:"repeat"`
+	RepeatAnchor  string     `json:"repeat_anchor"` // "due" or "completion"
+	RepeatCatchUp bool       `json:"repeat_catch_up"`
+	Tags          []string   `json:"tags"`
+	ProjectID     *int       `json:"project_id"`          // Nullable
+	SeriesID      *int       `json:"series_id"`           // Set for repeating todos
+	SeriesIndex   int        `json:"series_index"`        // Position within the series, from 0
+	Subtasks      []Subtask  `json:"subtasks,omitempty"`  // For API response
+	Reminders     []Reminder `json:"reminders,omitempty"` // For API response
+	OwnerID       *int       `json:"-"`                   // user the todo belongs to; nil before there were users
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	CompletedAt   *time.Time `json:"completed_at"`         // nil while open, and for todos completed before it was recorded
+	DeletedAt     *time.Time `json:"deleted_at,omitempty"` // set while the todo is in the trash
+}
+
+// locations caches the zones todos are in by name, since every todo read
+// needs its zone and time.LoadLocation reads it from disk each time.
+var locations sync.Map // string -> *time.Location
+
+// Location returns the zone the todo's dates are interpreted in. Unknown
+// zones fall back to UTC.
+func (t *Todo) Location() *time.Location {
+	if loc, ok := locations.Load(t.TimeZone); ok {
+		return loc.(*time.Location)
+	}
+	loc, err := time.LoadLocation(t.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	locations.Store(t.TimeZone, loc)
+	return loc
+}
+
+// localize fills in DueLocal and RemindLocal. All-day due dates are shown as
+// a plain date.
+func (t *Todo) localize() {
+	loc := t.Location()
+	t.DueLocal, t.RemindLocal = "", ""
+	if t.DueDate != nil {
+		if t.AllDay {
+			t.DueLocal = t.DueDate.In(loc).Format(time.DateOnly)
+		} else {
+			t.DueLocal = t.DueDate.In(loc).Format(time.RFC3339)
+		}
+	}
+	if t.RemindAt != nil {
+		t.RemindLocal = t.RemindAt.In(loc).Format(time.RFC3339)
+	}
+}
+
+// Reminder is an additional reminder for a todo, either at a fixed time or
+// a number of minutes before the todo is due.
+type Reminder struct {
+	ID               int        `json:"id"`
+	TodoID           int        `json:"todo_id"`
+	RemindAt         *time.Time `json:"remind_at,omitempty"`          // absolute reminders
+	BeforeDueMinutes *int       `json:"before_due_minutes,omitempty"` // relative reminders
+	FireAt           *time.Time `json:"fire_at"`                      // nil for a relative reminder on a todo without a due date
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// Series links the occurrences of a repeating todo. Its fields are the
+// template each new occurrence is created from.
+type Series struct {
+	ID            int       `json:"id"`
+	Title         string    `json:"title"`
+	Description   string    `json:"description"`
+	Priority      string    `json:"priority"`
+	Repeat        string    `json:"repeat"` // "" once the series has been ended
+	RepeatAnchor  string    `json:"repeat_anchor"`
+	RepeatCatchUp bool      `json:"repeat_catch_up"`
+	Tags          []string  `json:"tags"`
+	ProjectID     *int      `json:"project_id"`
+	CopySubtasks  bool      `json:"copy_subtasks"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Trash holds what has been deleted and can still be restored, most
+// recently deleted first.
+type Trash struct {
+	Projects []Project `json:"projects"`
+	Todos    []Todo    `json:"todos"`    // except those deleted along with their project
+	Subtasks []Subtask `json:"subtasks"` // except those of a todo in the trash
+}
+
+// Command statuses.
+const (
+	CommandRecording = "recording" // still running; only seen inside its own transaction
+	CommandReplaying = "replaying" // being undone or redone, likewise
+	CommandDone      = "done"      // can be undone
+	CommandUndone    = "undone"    // can be redone
+)
+
+// Command is one change made through the API, which can be undone as a
+// whole. Name says what it was, e.g. "Create a todo".
+type Command struct {
 	ID        int       `json:"id"`
-	TodoID    int       `json:"todo_id"`
-	Title     string    `json:"title"`
-	Completed bool      `json:"completed"`
+	SessionID int       `json:"-"`
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
-type Todo struct {
-	ID          int       `json:"id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Completed   bool      `json:"completed"`
-	Priority    string    `json:"priority"`
-	DueDate     *time.Time `json:"due_date"`
-	RemindAt    *time.Time `json:"remind_at"`
-	Repeat      string    `json:"repeat"`
-	Tags        []string  `json:"tags"`
-	ProjectID   *int      `json:"project_id"` // Nullable
-	Subtasks    []Subtask `json:"subtasks,omitempty"` // For API response
-	CreatedAt   time.Time `json:"created_at"`
+// Event actions.
+const (
+	EventCreated = "created"
+	EventUpdated = "updated"
+)
+
+// Event is one entry of the change history of a todo, subtask or project.
+// An update records a single field, with its value before and after.
+type Event struct {
+	ID        int       `json:"id"`
+	Kind      string    `json:"kind"`    // "todo", "subtask" or "project"
+	RefID     int       `json:"ref_id"`  // of the todo, subtask or project
+	TodoID    *int      `json:"todo_id"` // the todo, or the subtask's; nil for projects
+	UserID    *int      `json:"user_id"` // nil if not made by a signed-in user
+	Username  string    `json:"username,omitempty"`
+	Action    string    `json:"action"`
+	Field     string    `json:"field,omitempty"`
+	Old       any       `json:"old,omitempty"`
+	New       any       `json:"new,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// StatsTotals are the headline counts of the statistics of a period.
+type StatsTotals struct {
+	Created   int `json:"created"`   // in the period
+	Completed int `json:"completed"` // in the period
+	Open      int `json:"open"`      // now
+	Overdue   int `json:"overdue"`   // open and past their deadline now
+	Due       int `json:"due"`       // with a deadline in the period that has passed
+	Late      int `json:"late"`      // of Due, completed after the deadline or not at all
+}
+
+// ProjectStats is a project's share of the statistics of a period. Todos
+// without a project are counted under a nil ProjectID.
+type ProjectStats struct {
+	ProjectID    *int     `json:"project_id"`
+	Name         string   `json:"name"`
+	Created      int      `json:"created"`
+	Completed    int      `json:"completed"`
+	Throughput   float64  `json:"throughput"`     // completed per week
+	LeadTimeDays *float64 `json:"lead_time_days"` // mean time from creation to completion; nil if none was completed
+}
+
+// Breakdown counts the todos created and completed in a period that share
+// a tag or priority.
+type Breakdown struct {
+	Name      string `json:"name"`
+	Created   int    `json:"created"`
+	Completed int    `json:"completed"`
+}
+
+// Notification statuses.
+const (
+	NotificationScheduled = "scheduled" // due, waiting to be delivered
+	NotificationSending   = "sending"   // claimed by a scheduler until NextAttemptAt, when the claim lapses
+	NotificationSent      = "sent"
+	NotificationFailed    = "failed"  // delivery failed; retried until attempts run out
+	NotificationSnoozed   = "snoozed" // postponed until NextAttemptAt
+	NotificationExpired   = "expired" // missed by more than the catch-up cutoff, or no longer relevant
+	NotificationDismissed = "dismissed"
+)
+
+// Notification tracks the delivery of one reminder.
+type Notification struct {
+	ID            int        `json:"id"`
+	TodoID        int        `json:"todo_id"`
+	Title         string     `json:"title"`      // of the todo
+	ProjectID     *int       `json:"project_id"` // of the todo
+	OwnerID       *int       `json:"-"`          // of the todo
+	ScheduledFor  time.Time  `json:"scheduled_for"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error,omitempty"`
+	Delivered     []int      `json:"-"` // channels a failed delivery reached
+	NextAttemptAt *time.Time `json:"next_attempt_at"`
+	SentAt        *time.Time `json:"sent_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// Digest is the delivery state of one user's daily digest. Its statuses are
+// those of a Notification: sending, sent or failed.
+type Digest struct {
+	UserID        int    // 0 for the digest of everyone's todos
+	Date          string // YYYY-MM-DD in the digest's timezone
+	Status        string
+	Attempts      int
+	LastError     string
+	Delivered     []int // channels a failed delivery reached
+	NextAttemptAt *time.Time
+	SentAt        *time.Time
+}
+
+// User is an account of the HTTP API.
+type User struct {
+	ID           int       `json:"id"`
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"-"` // bcrypt
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Token kinds.
+const (
+	TokenSession = "session" // issued by logging in; expires
+	TokenAPI     = "api"     // personal API token for scripts
+)
+
+// Token is a bearer token of a user. Only the SHA-256 hash of the secret
+// is stored.
+type Token struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"user_id"`
+	Kind       string     `json:"kind"`
+	Name       string     `json:"name"`
+	Hash       string     `json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// SearchResult is a single full-text search hit. Title and Snippet carry
+// <mark>…</mark> around the matched terms.
+type SearchResult struct {
+	Kind    string  `json:"kind"` // "todo", "subtask" or "project"
+	ID      int     `json:"id"`
+	TodoID  *int    `json:"todo_id,omitempty"`
+	Title   string  `json:"title"`
+	Snippet string  `json:"snippet"`
+	Rank    float64 `json:"rank"`
 }
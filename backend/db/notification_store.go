@@ -0,0 +1,189 @@
+package db
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const notificationColumns = "n.id, n.todo_id, t.title, t.project_id, t.owner_id, n.scheduled_for, n.status, n.attempts, n.last_error, n.delivered, n.next_attempt_at, n.sent_at, n.created_at"
+
+// NotificationStore is the SQLite implementation of service.NotificationRepository.
+type NotificationStore struct {
+	q     Querier
+	owner owner
+}
+
+// NewNotificationStore returns a store of the notifications for ownerID's
+// todos, or for every user's when ownerID is 0. The delivery queue itself
+// (Enqueue, Expire, ListPending, Claim, MarkSent and MarkFailed) is run by the
+// scheduler over everyone's todos and isn't restricted.
+func NewNotificationStore(q Querier, ownerID int) *NotificationStore {
+	return &NotificationStore{q: q, owner: owner(ownerID)}
+}
+
+func scanNotification(row rowScanner) (Notification, error) {
+	var n Notification
+	var delivered string
+	err := row.Scan(&n.ID, &n.TodoID, &n.Title, &n.ProjectID, &n.OwnerID, &n.ScheduledFor, &n.Status, &n.Attempts, &n.LastError, &delivered, &n.NextAttemptAt, &n.SentAt, &n.CreatedAt)
+	n.Delivered = decodeChannels(delivered)
+	return n, err
+}
+
+// encodeChannels stores the positions of the channels a message reached
+// as a JSON array.
+func encodeChannels(channels []int) string {
+	if channels == nil {
+		return "[]"
+	}
+	raw, _ := json.Marshal(channels)
+	return string(raw)
+}
+
+func decodeChannels(raw string) []int {
+	var channels []int
+	json.Unmarshal([]byte(raw), &channels)
+	return channels
+}
+
+// Enqueue creates a scheduled notification for every reminder of an open
+// todo outside the trash, its remind_at or one in the reminders table, that is due by now and
+// doesn't have one yet. It returns how many were created.
+func (s *NotificationStore) Enqueue(now time.Time) (int, error) {
+	res, err := s.q.Exec(`INSERT OR IGNORE INTO notifications (todo_id, scheduled_for, next_attempt_at)
+		SELECT id, remind_at, remind_at FROM todos
+		WHERE completed = false AND deleted_at IS NULL AND remind_at IS NOT NULL AND remind_at <= ?1
+		UNION
+		SELECT r.todo_id, r.fire_at, r.fire_at FROM reminders r JOIN todos t ON t.id = r.todo_id
+		WHERE t.completed = false AND t.deleted_at IS NULL AND r.fire_at IS NOT NULL AND r.fire_at <= ?1`, now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// Expire gives up on pending notifications that were due for delivery
+// before cutoff, and on those whose todo has been completed, rescheduled or
+// deleted since they were queued.
+func (s *NotificationStore) Expire(cutoff time.Time) (int, error) {
+	res, err := s.q.Exec(`UPDATE notifications SET status = ?, next_attempt_at = NULL
+		WHERE status IN (?, ?, ?, ?) AND next_attempt_at IS NOT NULL AND (next_attempt_at < ? OR NOT EXISTS (
+			SELECT 1 FROM todos t
+			WHERE t.id = notifications.todo_id AND t.completed = false AND t.deleted_at IS NULL AND (t.remind_at = notifications.scheduled_for OR EXISTS (
+				SELECT 1 FROM reminders r WHERE r.todo_id = t.id AND r.fire_at = notifications.scheduled_for
+			))
+		))`, NotificationExpired, NotificationScheduled, NotificationSending, NotificationFailed, NotificationSnoozed, cutoff.UTC())
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// ListPending returns notifications ready to be (re)delivered at now, oldest
+// first, including those whose claim has lapsed without a delivery.
+func (s *NotificationStore) ListPending(now time.Time, limit int) ([]Notification, error) {
+	rows, err := s.q.Query(`SELECT `+notificationColumns+`
+		FROM notifications n JOIN todos t ON t.id = n.todo_id
+		WHERE n.status IN (?, ?, ?, ?) AND n.next_attempt_at <= ? AND t.deleted_at IS NULL
+		ORDER BY n.next_attempt_at, n.id
+		LIMIT ?`, NotificationScheduled, NotificationSending, NotificationFailed, NotificationSnoozed, now.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notifications []Notification
+	for rows.Next() {
+		n, err := scanNotification(rows)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
+
+// Claim takes a pending notification for delivery until leaseUntil. It
+// reports false if another scheduler holds it. A claim that lapses, because
+// the scheduler died before MarkSent or MarkFailed, makes it pending again,
+// so a notification is delivered at least once and rarely twice.
+func (s *NotificationStore) Claim(id int, now, leaseUntil time.Time) (bool, error) {
+	res, err := s.q.Exec(`UPDATE notifications SET status = ?, attempts = attempts + 1, next_attempt_at = ?
+		WHERE id = ? AND status IN (?, ?, ?, ?) AND next_attempt_at <= ?`, NotificationSending, leaseUntil.UTC(), id, NotificationScheduled, NotificationSending, NotificationFailed, NotificationSnoozed, now.UTC())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// MarkSent records the delivery of a claimed notification.
+func (s *NotificationStore) MarkSent(id int, at time.Time) error {
+	_, err := s.q.Exec("UPDATE notifications SET status = ?, sent_at = ?, next_attempt_at = NULL, delivered = '[]' WHERE id = ? AND status = ?", NotificationSent, at.UTC(), id, NotificationSending)
+	return err
+}
+
+// MarkFailed records a failed delivery of a claimed notification, and the
+// channels it did reach, which a retry skips. A nil retryAt gives up on it.
+func (s *NotificationStore) MarkFailed(id int, deliveryErr string, delivered []int, retryAt *time.Time) error {
+	_, err := s.q.Exec("UPDATE notifications SET status = ?, sent_at = NULL, last_error = ?, delivered = ?, next_attempt_at = ? WHERE id = ?", NotificationFailed, deliveryErr, encodeChannels(delivered), utc(retryAt), id)
+	return err
+}
+
+// Snooze postpones a delivered or pending notification until the given
+// time. It reports false if the notification was dismissed or expired.
+func (s *NotificationStore) Snooze(id int, until time.Time) (bool, error) {
+	res, err := s.q.Exec(`UPDATE notifications SET status = ?, next_attempt_at = ?, attempts = 0, last_error = '', delivered = '[]'
+		WHERE id = ? AND status IN (?, ?, ?, ?) AND `+s.owner.ownsLiveTodo("todo_id"), NotificationSnoozed, until.UTC(), id, NotificationScheduled, NotificationSent, NotificationFailed, NotificationSnoozed)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// Dismiss closes a notification so it isn't delivered again. It reports
+// false if the notification had already expired.
+func (s *NotificationStore) Dismiss(id int) (bool, error) {
+	res, err := s.q.Exec("UPDATE notifications SET status = ?, next_attempt_at = NULL WHERE id = ? AND status <> ? AND "+s.owner.ownsLiveTodo("todo_id"), NotificationDismissed, id, NotificationExpired)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// ListActive returns the notifications a user can still act on, those sent
+// or snoozed, most recently sent first.
+func (s *NotificationStore) ListActive(limit int) ([]Notification, error) {
+	rows, err := s.q.Query(`SELECT `+notificationColumns+`
+		FROM notifications n JOIN todos t ON t.id = n.todo_id
+		WHERE n.status IN (?, ?) AND t.completed = false AND t.deleted_at IS NULL AND `+s.owner.owns("t.owner_id")+`
+		ORDER BY n.sent_at DESC, n.id DESC
+		LIMIT ?`, NotificationSent, NotificationSnoozed, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notifications []Notification
+	for rows.Next() {
+		n, err := scanNotification(rows)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
+
+// Get returns a single notification.
+func (s *NotificationStore) Get(id int) (*Notification, error) {
+	n, err := scanNotification(s.q.QueryRow(`SELECT `+notificationColumns+`
+		FROM notifications n JOIN todos t ON t.id = n.todo_id WHERE n.id = ? AND t.deleted_at IS NULL AND `+s.owner.owns("t.owner_id"), id))
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
@@ -0,0 +1,63 @@
+package db
+
+import "strconv"
+
+// owner restricts a store to the rows of one user. The zero owner is not
+// restricted and sees every user's rows, as the desktop app and the
+// notification scheduler do.
+type owner int
+
+// owns returns a condition matching rows whose column holds the owner's ID.
+// The ID is an integer, so it is written into the SQL rather than bound,
+// which keeps the stores' positional arguments unchanged.
+func (o owner) owns(column string) string {
+	if o == 0 {
+		return "1"
+	}
+	return column + " = " + strconv.Itoa(int(o))
+}
+
+// ownsTodo returns a condition matching rows whose column refers to one of
+// the owner's todos.
+func (o owner) ownsTodo(column string) string {
+	if o == 0 {
+		return "1"
+	}
+	return column + " IN (SELECT id FROM todos WHERE owner_id = " + strconv.Itoa(int(o)) + ")"
+}
+
+// ownsLiveTodo returns a condition matching rows whose column refers to
+// one of the owner's todos that isn't in the trash. Unlike the others it
+// restricts the zero owner too.
+func (o owner) ownsLiveTodo(column string) string {
+	return column + " IN (SELECT id FROM todos WHERE deleted_at IS NULL AND " + o.owns("owner_id") + ")"
+}
+
+// of returns the owner_id to store for a new row: the store's owner, or
+// the given one when the store sees everyone's rows.
+func (o owner) of(id *int) *int {
+	if o == 0 {
+		return id
+	}
+	v := int(o)
+	return &v
+}
+
+// ownsSeries returns a condition matching rows whose column refers to a
+// series with occurrences owned by the owner.
+func (o owner) ownsSeries(column string) string {
+	if o == 0 {
+		return "1"
+	}
+	return column + " IN (SELECT series_id FROM todos WHERE owner_id = " + strconv.Itoa(int(o)) + ")"
+}
+
+// ownsEntry returns a condition matching search_index rows of the owner's
+// todos, subtasks and projects.
+func (o owner) ownsEntry() string {
+	if o == 0 {
+		return "1"
+	}
+	id := strconv.Itoa(int(o))
+	return "((kind = 'project' AND ref_id IN (SELECT id FROM projects WHERE owner_id = " + id + ")) OR (kind <> 'project' AND " + o.ownsTodo("todo_id") + "))"
+}
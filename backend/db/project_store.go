@@ -0,0 +1,77 @@
+package db
+
+import "time"
+
+// ProjectStore is the SQLite implementation of service.ProjectRepository.
+type ProjectStore struct {
+	q     Querier
+	owner owner
+}
+
+// NewProjectStore returns a store of the projects owned by ownerID, or of
+// every user's projects when ownerID is 0.
+func NewProjectStore(q Querier, ownerID int) *ProjectStore {
+	return &ProjectStore{q: q, owner: owner(ownerID)}
+}
+
+const projectColumns = "id, name, description, color, owner_id, created_at, updated_at, deleted_at"
+
+func scanProject(row rowScanner) (Project, error) {
+	var p Project
+	err := row.Scan(&p.ID, &p.Name, &p.Description, &p.Color, &p.OwnerID, &p.CreatedAt, &p.UpdatedAt, &p.DeletedAt)
+	return p, err
+}
+
+func (s *ProjectStore) Create(p *Project) (int64, error) {
+	res, err := s.q.Exec("INSERT INTO projects (name, description, color, owner_id, updated_at) VALUES (?, ?, ?, ?, ?)", p.Name, p.Description, p.Color, s.owner.of(p.OwnerID), p.UpdatedAt.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (s *ProjectStore) Get(id int) (*Project, error) {
+	p, err := scanProject(s.q.QueryRow("SELECT "+projectColumns+" FROM projects WHERE id = ? AND deleted_at IS NULL AND "+s.owner.owns("owner_id"), id))
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (s *ProjectStore) List() ([]Project, error) {
+	return s.query("SELECT " + projectColumns + " FROM projects WHERE deleted_at IS NULL AND " + s.owner.owns("owner_id") + " ORDER BY created_at ASC")
+}
+
+func (s *ProjectStore) query(query string, args ...any) ([]Project, error) {
+	rows, err := s.q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var projects []Project
+	for rows.Next() {
+		p, err := scanProject(rows)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, p)
+	}
+	return projects, rows.Err()
+}
+
+func (s *ProjectStore) Update(p *Project) error {
+	return execOne(s.q, "UPDATE projects SET name = ?, description = ?, color = ?, updated_at = ? WHERE id = ? AND deleted_at IS NULL AND "+s.owner.owns("owner_id"), p.Name, p.Description, p.Color, p.UpdatedAt.UTC(), p.ID)
+}
+
+// Delete moves a project to the trash along with its todos. They are
+// stamped with the same time, which is how restoring the project tells them
+// from todos that were deleted on their own.
+func (s *ProjectStore) Delete(id int) error {
+	now := time.Now().UTC()
+	if err := execOne(s.q, "UPDATE projects SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL AND "+s.owner.owns("owner_id"), now, id); err != nil {
+		return err
+	}
+	_, err := s.q.Exec("UPDATE todos SET deleted_at = ? WHERE project_id = ? AND deleted_at IS NULL AND "+s.owner.owns("owner_id"), now, id)
+	return err
+}
@@ -0,0 +1,26 @@
+package db
+
+import "database/sql"
+
+// Querier is the subset of *sql.DB and *sql.Tx the stores need, so the same
+// store can run standalone or inside a caller's transaction.
+type Querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// execOne runs an UPDATE or DELETE of one row by ID. It returns
+// sql.ErrNoRows if no row matched, e.g. one that doesn't exist or belongs
+// to another owner.
+func execOne(q Querier, query string, args ...any) error {
+	res, err := q.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err == nil && n == 0 {
+		err = sql.ErrNoRows
+	}
+	return err
+}
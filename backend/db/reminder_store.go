@@ -0,0 +1,78 @@
+package db
+
+import "encoding/json"
+
+// ReminderStore is the SQLite implementation of service.ReminderRepository.
+type ReminderStore struct {
+	q     Querier
+	owner owner
+}
+
+// NewReminderStore returns a store of the reminders of ownerID's todos, or
+// of every user's when ownerID is 0.
+func NewReminderStore(q Querier, ownerID int) *ReminderStore {
+	return &ReminderStore{q: q, owner: owner(ownerID)}
+}
+
+const reminderColumns = "id, todo_id, remind_at, before_due_minutes, fire_at, created_at"
+
+func scanReminder(row rowScanner) (Reminder, error) {
+	var r Reminder
+	err := row.Scan(&r.ID, &r.TodoID, &r.RemindAt, &r.BeforeDueMinutes, &r.FireAt, &r.CreatedAt)
+	return r, err
+}
+
+func (s *ReminderStore) Create(r *Reminder) (int64, error) {
+	res, err := s.q.Exec("INSERT INTO reminders (todo_id, remind_at, before_due_minutes, fire_at) VALUES (?, ?, ?, ?)", r.TodoID, utc(r.RemindAt), r.BeforeDueMinutes, utc(r.FireAt))
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (s *ReminderStore) Get(id int) (*Reminder, error) {
+	r, err := scanReminder(s.q.QueryRow("SELECT "+reminderColumns+" FROM reminders WHERE id = ? AND "+s.owner.ownsLiveTodo("todo_id"), id))
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func (s *ReminderStore) ListByTodo(todoID int) ([]Reminder, error) {
+	byTodo, err := s.ListByTodos([]int{todoID})
+	return byTodo[todoID], err
+}
+
+// ListByTodos loads the reminders of many todos with a single query, keyed
+// by todo ID.
+func (s *ReminderStore) ListByTodos(todoIDs []int) (map[int][]Reminder, error) {
+	byTodo := make(map[int][]Reminder, len(todoIDs))
+	if len(todoIDs) == 0 {
+		return byTodo, nil
+	}
+	idsJSON, _ := json.Marshal(todoIDs)
+	rows, err := s.q.Query("SELECT "+reminderColumns+" FROM reminders WHERE todo_id IN (SELECT value FROM json_each(?)) AND "+s.owner.ownsLiveTodo("todo_id")+" ORDER BY todo_id, fire_at IS NULL, fire_at, id", string(idsJSON))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		r, err := scanReminder(rows)
+		if err != nil {
+			return nil, err
+		}
+		byTodo[r.TodoID] = append(byTodo[r.TodoID], r)
+	}
+	return byTodo, rows.Err()
+}
+
+// UpdateFireAt records when a reminder now goes off.
+func (s *ReminderStore) UpdateFireAt(r *Reminder) error {
+	_, err := s.q.Exec("UPDATE reminders SET fire_at = ? WHERE id = ? AND "+s.owner.ownsLiveTodo("todo_id"), utc(r.FireAt), r.ID)
+	return err
+}
+
+func (s *ReminderStore) Delete(id int) error {
+	return execOne(s.q, "DELETE FROM reminders WHERE id = ? AND "+s.owner.ownsLiveTodo("todo_id"), id)
+}
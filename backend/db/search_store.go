@@ -0,0 +1,48 @@
+package db
+
+// SearchStore is the SQLite FTS5 implementation of service.SearchRepository.
+type SearchStore struct {
+	q     Querier
+	owner owner
+}
+
+// NewSearchStore returns a store searching ownerID's todos and projects, or
+// every user's when ownerID is 0.
+func NewSearchStore(q Querier, ownerID int) *SearchStore {
+	return &SearchStore{q: q, owner: owner(ownerID)}
+}
+
+// liveEntry matches search_index rows of todos, subtasks and projects that
+// aren't in the trash. A subtask is hidden while its todo is.
+const liveEntry = `(CASE kind
+	WHEN 'project' THEN ref_id IN (SELECT id FROM projects WHERE deleted_at IS NULL)
+	WHEN 'subtask' THEN ref_id IN (SELECT id FROM subtasks WHERE deleted_at IS NULL) AND todo_id IN (SELECT id FROM todos WHERE deleted_at IS NULL)
+	ELSE todo_id IN (SELECT id FROM todos WHERE deleted_at IS NULL)
+END)`
+
+// Search runs an FTS5 MATCH expression against search_index, best hits first.
+// Title matches weigh more than tags, which weigh more than descriptions.
+func (s *SearchStore) Search(match string, limit int) ([]SearchResult, error) {
+	rows, err := s.q.Query(`SELECT kind, ref_id, todo_id,
+			highlight(search_index, 3, '<mark>', '</mark>'),
+			snippet(search_index, -1, '<mark>', '</mark>', '…', 12),
+			bm25(search_index, 0, 0, 0, 10.0, 1.0, 5.0) AS rank
+		FROM search_index
+		WHERE search_index MATCH ? AND `+liveEntry+` AND `+s.owner.ownsEntry()+`
+		ORDER BY rank
+		LIMIT ?`, match, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var r SearchResult
+		if err := rows.Scan(&r.Kind, &r.ID, &r.TodoID, &r.Title, &r.Snippet, &r.Rank); err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
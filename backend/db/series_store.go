@@ -0,0 +1,44 @@
+package db
+
+import "encoding/json"
+
+// SeriesStore is the SQLite implementation of service.SeriesRepository.
+type SeriesStore struct {
+	q     Querier
+	owner owner
+}
+
+// NewSeriesStore returns a store of the series of ownerID's todos, or of
+// every user's when ownerID is 0. A series belongs to whoever owns its
+// occurrences.
+func NewSeriesStore(q Querier, ownerID int) *SeriesStore {
+	return &SeriesStore{q: q, owner: owner(ownerID)}
+}
+
+func (s *SeriesStore) Create(sr *Series) (int64, error) {
+	res, err := s.q.Exec("INSERT INTO series (title, description, priority, repeat, repeat_anchor, repeat_catch_up, tags, project_id, copy_subtasks) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)", sr.Title, sr.Description, sr.Priority, sr.Repeat, repeatAnchor(sr.RepeatAnchor), sr.RepeatCatchUp, encodeTags(sr.Tags), sr.ProjectID, sr.CopySubtasks)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (s *SeriesStore) Get(id int) (*Series, error) {
+	var sr Series
+	var tagsJSON string
+	err := s.q.QueryRow("SELECT id, title, description, priority, repeat, repeat_anchor, repeat_catch_up, tags, project_id, copy_subtasks, created_at FROM series WHERE id = ? AND "+s.owner.ownsSeries("id"), id).
+		Scan(&sr.ID, &sr.Title, &sr.Description, &sr.Priority, &sr.Repeat, &sr.RepeatAnchor, &sr.RepeatCatchUp, &tagsJSON, &sr.ProjectID, &sr.CopySubtasks, &sr.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	json.Unmarshal([]byte(tagsJSON), &sr.Tags)
+	if sr.Tags == nil {
+		sr.Tags = []string{}
+	}
+	return &sr, nil
+}
+
+func (s *SeriesStore) Update(sr *Series) error {
+	_, err := s.q.Exec("UPDATE series SET title = ?, description = ?, priority = ?, repeat = ?, repeat_anchor = ?, repeat_catch_up = ?, tags = ?, project_id = ?, copy_subtasks = ? WHERE id = ? AND "+s.owner.ownsSeries("id"), sr.Title, sr.Description, sr.Priority, sr.Repeat, repeatAnchor(sr.RepeatAnchor), sr.RepeatCatchUp, encodeTags(sr.Tags), sr.ProjectID, sr.CopySubtasks, sr.ID)
+	return err
+}
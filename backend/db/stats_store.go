@@ -0,0 +1,155 @@
+package db
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// StatsStore is the SQLite implementation of service.StatsRepository. Every
+// figure is an aggregate query, so the todos of a period are never loaded.
+type StatsStore struct {
+	q     Querier
+	owner owner
+}
+
+// NewStatsStore returns a store of the statistics of ownerID's todos, or of
+// every user's when ownerID is 0.
+func NewStatsStore(q Querier, ownerID int) *StatsStore {
+	return &StatsStore{q: q, owner: owner(ownerID)}
+}
+
+// statsTime formats a bound the way the timestamps it is compared with
+// start: created_at is CURRENT_TIMESTAMP text, completed_at and due_date
+// are UTC times with fractional seconds and a zone after it, both of which
+// compare correctly with it as text.
+func statsTime(t time.Time) string {
+	return t.UTC().Format(time.DateTime)
+}
+
+// deadlineSQL is when a todo is late: its due time, or the end of its due
+// day for an all-day todo.
+const deadlineSQL = "(CASE WHEN all_day THEN datetime(substr(due_date, 1, 19), '+1 day') ELSE substr(due_date, 1, 19) END)"
+
+// Totals counts the todos created and completed in [from, to), those open
+// and overdue at now, and how many of those due in the period by now were
+// late. Completed todos from before completion times were recorded don't
+// count as late.
+func (s *StatsStore) Totals(from, to, now time.Time) (*StatsTotals, error) {
+	var t StatsTotals
+	err := s.q.QueryRow(`SELECT
+			COUNT(*) FILTER (WHERE created_at >= ?1 AND created_at < ?2),
+			COUNT(*) FILTER (WHERE completed_at >= ?1 AND completed_at < ?2),
+			COUNT(*) FILTER (WHERE completed = 0),
+			COUNT(*) FILTER (WHERE completed = 0 AND `+deadlineSQL+` < ?3),
+			COUNT(*) FILTER (WHERE due_date >= ?1 AND due_date < ?2 AND `+deadlineSQL+` < ?3 AND (completed = 0 OR completed_at IS NOT NULL)),
+			COUNT(*) FILTER (WHERE due_date >= ?1 AND due_date < ?2 AND `+deadlineSQL+` < ?3 AND (completed = 0 OR substr(completed_at, 1, 19) > `+deadlineSQL+`))
+		FROM todos
+		WHERE deleted_at IS NULL AND `+s.owner.owns("owner_id"),
+		statsTime(from), statsTime(to), statsTime(now)).Scan(&t.Created, &t.Completed, &t.Open, &t.Overdue, &t.Due, &t.Late)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Completions counts the todos completed in each period between
+// consecutive bounds, which must be ascending.
+func (s *StatsStore) Completions(bounds []time.Time) ([]int, error) {
+	counts := make([]int, max(len(bounds)-1, 0))
+	if len(counts) == 0 {
+		return counts, nil
+	}
+	texts := make([]string, len(bounds))
+	for i, b := range bounds {
+		texts[i] = statsTime(b)
+	}
+	boundsJSON, _ := json.Marshal(texts)
+
+	rows, err := s.q.Query(`SELECT (SELECT MAX(b.key) FROM json_each(?1) b WHERE b.value <= completed_at) AS period, COUNT(*)
+		FROM todos
+		WHERE completed_at >= ?2 AND completed_at < ?3 AND deleted_at IS NULL AND `+s.owner.owns("owner_id")+`
+		GROUP BY period`, string(boundsJSON), texts[0], texts[len(texts)-1])
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var period, n int
+		if err := rows.Scan(&period, &n); err != nil {
+			return nil, err
+		}
+		counts[period] = n
+	}
+	return counts, rows.Err()
+}
+
+// ByProject returns the statistics of [from, to) per project that had todos
+// created or completed in it, busiest first.
+func (s *StatsStore) ByProject(from, to time.Time) ([]ProjectStats, error) {
+	weeks := to.Sub(from).Hours() / (24 * 7)
+	rows, err := s.q.Query(`SELECT t.project_id, COALESCE(p.name, ''),
+			COUNT(*) FILTER (WHERE t.created_at >= ?1 AND t.created_at < ?2) AS created,
+			COUNT(*) FILTER (WHERE t.completed_at >= ?1 AND t.completed_at < ?2) AS completed,
+			AVG(julianday(substr(t.completed_at, 1, 19)) - julianday(substr(t.created_at, 1, 19))) FILTER (WHERE t.completed_at >= ?1 AND t.completed_at < ?2)
+		FROM todos t LEFT JOIN projects p ON p.id = t.project_id
+		WHERE t.deleted_at IS NULL AND `+s.owner.owns("t.owner_id")+`
+			AND ((t.created_at >= ?1 AND t.created_at < ?2) OR (t.completed_at >= ?1 AND t.completed_at < ?2))
+		GROUP BY t.project_id
+		ORDER BY completed DESC, created DESC, p.name`, statsTime(from), statsTime(to))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	projects := []ProjectStats{}
+	for rows.Next() {
+		var p ProjectStats
+		if err := rows.Scan(&p.ProjectID, &p.Name, &p.Created, &p.Completed, &p.LeadTimeDays); err != nil {
+			return nil, err
+		}
+		if weeks > 0 {
+			p.Throughput = float64(p.Completed) / weeks
+		}
+		projects = append(projects, p)
+	}
+	return projects, rows.Err()
+}
+
+// ByTag breaks the todos created or completed in [from, to) down by tag,
+// most completed first.
+func (s *StatsStore) ByTag(from, to time.Time) ([]Breakdown, error) {
+	return s.breakdown("todos, json_each(todos.tags) tag", "tag.value", from, to)
+}
+
+// ByPriority breaks the todos created or completed in [from, to) down by
+// priority, most completed first.
+func (s *StatsStore) ByPriority(from, to time.Time) ([]Breakdown, error) {
+	return s.breakdown("todos", "todos.priority", from, to)
+}
+
+// breakdown groups the todos of source created or completed in [from, to)
+// by key.
+func (s *StatsStore) breakdown(source, key string, from, to time.Time) ([]Breakdown, error) {
+	rows, err := s.q.Query(`SELECT `+key+`,
+			COUNT(*) FILTER (WHERE todos.created_at >= ?1 AND todos.created_at < ?2) AS created,
+			COUNT(*) FILTER (WHERE todos.completed_at >= ?1 AND todos.completed_at < ?2) AS completed
+		FROM `+source+`
+		WHERE todos.deleted_at IS NULL AND `+s.owner.owns("todos.owner_id")+`
+			AND ((todos.created_at >= ?1 AND todos.created_at < ?2) OR (todos.completed_at >= ?1 AND todos.completed_at < ?2))
+		GROUP BY `+key+`
+		ORDER BY completed DESC, created DESC, `+key, statsTime(from), statsTime(to))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	breakdown := []Breakdown{}
+	for rows.Next() {
+		var b Breakdown
+		if err := rows.Scan(&b.Name, &b.Created, &b.Completed); err != nil {
+			return nil, err
+		}
+		breakdown = append(breakdown, b)
+	}
+	return breakdown, rows.Err()
+}
@@ -0,0 +1,95 @@
+package db
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// SubtaskStore is the SQLite implementation of service.SubtaskRepository.
+// Subtasks of a todo in the trash are hidden along with it.
+type SubtaskStore struct {
+	q     Querier
+	owner owner
+}
+
+// NewSubtaskStore returns a store of the subtasks of ownerID's todos, or of
+// every user's when ownerID is 0.
+func NewSubtaskStore(q Querier, ownerID int) *SubtaskStore {
+	return &SubtaskStore{q: q, owner: owner(ownerID)}
+}
+
+const subtaskColumns = "id, todo_id, title, completed, created_at, updated_at, completed_at, deleted_at"
+
+func scanSubtask(row rowScanner) (Subtask, error) {
+	var st Subtask
+	err := row.Scan(&st.ID, &st.TodoID, &st.Title, &st.Completed, &st.CreatedAt, &st.UpdatedAt, &st.CompletedAt, &st.DeletedAt)
+	return st, err
+}
+
+func (s *SubtaskStore) Create(st *Subtask) (int64, error) {
+	res, err := s.q.Exec("INSERT INTO subtasks (todo_id, title, updated_at) VALUES (?, ?, ?)", st.TodoID, st.Title, st.UpdatedAt.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (s *SubtaskStore) Get(id int) (*Subtask, error) {
+	st, err := scanSubtask(s.q.QueryRow("SELECT "+subtaskColumns+" FROM subtasks WHERE id = ? AND deleted_at IS NULL AND "+s.owner.ownsLiveTodo("todo_id"), id))
+	if err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+func (s *SubtaskStore) ListByTodo(todoID int) ([]Subtask, error) {
+	return s.query("SELECT "+subtaskColumns+" FROM subtasks WHERE todo_id = ? AND deleted_at IS NULL AND "+s.owner.ownsLiveTodo("todo_id")+" ORDER BY created_at ASC", todoID)
+}
+
+// ListByTodos loads the subtasks of many todos with a single query, keyed by
+// todo ID. The IDs are passed as one JSON array to stay clear of SQLite's
+// bound-parameter limit.
+func (s *SubtaskStore) ListByTodos(todoIDs []int) (map[int][]Subtask, error) {
+	byTodo := make(map[int][]Subtask, len(todoIDs))
+	if len(todoIDs) == 0 {
+		return byTodo, nil
+	}
+	idsJSON, _ := json.Marshal(todoIDs)
+	subtasks, err := s.query("SELECT "+subtaskColumns+" FROM subtasks WHERE todo_id IN (SELECT value FROM json_each(?)) AND deleted_at IS NULL AND "+s.owner.ownsLiveTodo("todo_id")+" ORDER BY todo_id, created_at ASC", string(idsJSON))
+	if err != nil {
+		return nil, err
+	}
+	for _, st := range subtasks {
+		byTodo[st.TodoID] = append(byTodo[st.TodoID], st)
+	}
+	return byTodo, nil
+}
+
+func (s *SubtaskStore) query(query string, args ...any) ([]Subtask, error) {
+	rows, err := s.q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subtasks []Subtask
+	for rows.Next() {
+		st, err := scanSubtask(rows)
+		if err != nil {
+			return nil, err
+		}
+		subtasks = append(subtasks, st)
+	}
+	return subtasks, rows.Err()
+}
+
+// Update saves a subtask changed at st.UpdatedAt, which becomes its
+// completion time if it is checked off then.
+func (s *SubtaskStore) Update(st *Subtask) error {
+	return execOne(s.q, "UPDATE subtasks SET title = ?1, completed = ?2, completed_at = CASE WHEN ?2 THEN COALESCE(completed_at, ?3) END, updated_at = ?3 WHERE id = ?4 AND deleted_at IS NULL AND "+s.owner.ownsLiveTodo("todo_id"), st.Title, st.Completed, st.UpdatedAt.UTC(), st.ID)
+}
+
+// Delete moves a subtask to the trash.
+func (s *SubtaskStore) Delete(id int) error {
+	return execOne(s.q, "UPDATE subtasks SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL AND "+s.owner.ownsLiveTodo("todo_id"), time.Now().UTC(), id)
+}
@@ -0,0 +1,231 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"todo/backend/filter"
+)
+
+// TodoQuery describes which todos TodoStore.List returns and in what order.
+type TodoQuery struct {
+	Filter *filter.Filter // nil matches every todo
+	Sort   string         // one of TodoSorts; defaults to "created"
+	Desc   bool
+	Now    time.Time // reference time for relative dates in Filter
+	Limit  int       // maximum number of todos to return; 0 means no limit
+	After  *TodoCursor
+}
+
+// TodoCursor marks the position just after the last todo of a page. Keys
+// holds that todo's sort key values, so the next page can be fetched with a
+// keyset condition instead of an OFFSET.
+type TodoCursor struct {
+	Sort string `json:"s"`
+	Desc bool   `json:"d"`
+	Keys []any  `json:"k"`
+}
+
+// ErrInvalidCursor is returned when a cursor does not fit the query's sort.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// TodoSorts are the accepted values of TodoQuery.Sort.
+var TodoSorts = []string{"created", "due", "priority", "title"}
+
+const priorityRankSQL = "(CASE todos.priority WHEN 'low' THEN 0 WHEN 'medium' THEN 1 WHEN 'high' THEN 2 ELSE 1 END)"
+
+// likeEscaper escapes LIKE wildcards in user text; pair with ESCAPE '\'.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+type sortKey struct {
+	expr string
+	desc bool
+}
+
+// todoSortKeys returns the ORDER BY keys for a sort. Every ordering ends in
+// todos.id so that keys are unique and pages never overlap.
+func todoSortKeys(sort string, desc bool) ([]sortKey, error) {
+	switch sort {
+	case "", "created":
+		return []sortKey{{"todos.created_at", desc}, {"todos.id", desc}}, nil
+	case "due":
+		// Todos without a due date always go last
+		return []sortKey{{"(todos.due_date IS NULL)", false}, {"COALESCE(todos.due_date, '')", desc}, {"todos.id", desc}}, nil
+	case "priority":
+		return []sortKey{{priorityRankSQL, desc}, {"todos.created_at", desc}, {"todos.id", desc}}, nil
+	case "title":
+		return []sortKey{{"todos.title COLLATE NOCASE", desc}, {"todos.id", desc}}, nil
+	}
+	return nil, fmt.Errorf("unknown sort %q", sort)
+}
+
+// buildTodoQuery compiles q into a WHERE clause, an ORDER BY clause and the
+// arguments for the WHERE clause, limited to o's todos outside the trash.
+// keys are the sort keys for cursors.
+func buildTodoQuery(q TodoQuery, o owner) (where, order string, args []any, keys []sortKey, err error) {
+	now := q.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	conds := []string{"todos.deleted_at IS NULL"}
+	if o != 0 {
+		conds = append(conds, o.owns("todos.owner_id"))
+	}
+	if q.Filter != nil {
+		for _, term := range q.Filter.Terms {
+			cond, condArgs, err := compileTerm(term, now)
+			if err != nil {
+				return "", "", nil, nil, err
+			}
+			if term.Negate {
+				// NULL columns (e.g. no due date) should count as "not matching"
+				// rather than dropping out of both the term and its negation.
+				cond = "NOT COALESCE((" + cond + "), 0)"
+			}
+			conds = append(conds, cond)
+			args = append(args, condArgs...)
+		}
+	}
+
+	keys, err = todoSortKeys(q.Sort, q.Desc)
+	if err != nil {
+		return "", "", nil, nil, err
+	}
+
+	if q.After != nil {
+		if q.After.Sort != q.Sort || q.After.Desc != q.Desc || len(q.After.Keys) != len(keys) {
+			return "", "", nil, nil, ErrInvalidCursor
+		}
+		cond, condArgs := keysetCondition(keys, q.After.Keys)
+		conds = append(conds, cond)
+		args = append(args, condArgs...)
+	}
+
+	where = " WHERE " + strings.Join(conds, " AND ")
+
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k.expr + " ASC"
+		if k.desc {
+			parts[i] = k.expr + " DESC"
+		}
+	}
+	return where, " ORDER BY " + strings.Join(parts, ", "), args, keys, nil
+}
+
+// keysetCondition matches rows that sort strictly after values:
+// (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ..., flipping > for descending keys.
+func keysetCondition(keys []sortKey, values []any) (string, []any) {
+	var ors []string
+	var args []any
+	for i, k := range keys {
+		var ands []string
+		for j := 0; j < i; j++ {
+			ands = append(ands, keys[j].expr+" = ?")
+			args = append(args, values[j])
+		}
+		op := " > ?"
+		if k.desc {
+			op = " < ?"
+		}
+		ands = append(ands, k.expr+op)
+		args = append(args, values[i])
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+	}
+	return "(" + strings.Join(ors, " OR ") + ")", args
+}
+
+func compileTerm(t filter.Term, now time.Time) (string, []any, error) {
+	switch t.Field {
+	case filter.FieldText:
+		pattern := "%" + likeEscaper.Replace(t.Value) + "%"
+		return `(todos.title LIKE ? ESCAPE '\' OR todos.description LIKE ? ESCAPE '\')`, []any{pattern, pattern}, nil
+
+	case filter.FieldProject:
+		switch strings.ToLower(t.Value) {
+		case "none":
+			return "todos.project_id IS NULL", nil, nil
+		case "any":
+			return "todos.project_id IS NOT NULL", nil, nil
+		}
+		return "todos.project_id IN (SELECT id FROM projects WHERE name = ? COLLATE NOCASE AND deleted_at IS NULL)", []any{t.Value}, nil
+
+	case filter.FieldPriority:
+		rank := filter.PriorityRank(t.Value)
+		return fmt.Sprintf("%s %s ?", priorityRankSQL, sqlOp(t.Op)), []any{rank}, nil
+
+	case filter.FieldTag:
+		return "EXISTS (SELECT 1 FROM json_each(todos.tags) WHERE json_each.value = ? COLLATE NOCASE)", []any{t.Value}, nil
+
+	case filter.FieldRepeat:
+		switch strings.ToLower(t.Value) {
+		case "none":
+			return "COALESCE(todos.repeat, '') = ''", nil, nil
+		case "any":
+			return "COALESCE(todos.repeat, '') <> ''", nil, nil
+		}
+		return "todos.repeat = ? COLLATE NOCASE", []any{t.Value}, nil
+
+	case filter.FieldIs:
+		switch strings.ToLower(t.Value) {
+		case "completed":
+			return "todos.completed = 1", nil, nil
+		case "active":
+			return "todos.completed = 0", nil, nil
+		case "overdue":
+			return "(todos.completed = 0 AND todos.due_date < ?)", []any{now.UTC()}, nil
+		case "repeating":
+			return "COALESCE(todos.repeat, '') <> ''", nil, nil
+		}
+
+	case filter.FieldDue:
+		return compileDate("todos.due_date", t, now, func(t time.Time) any { return t.UTC() })
+
+	case filter.FieldCreated:
+		// created_at is filled by CURRENT_TIMESTAMP, i.e. UTC text without a zone.
+		return compileDate("todos.created_at", t, now, func(t time.Time) any { return t.UTC().Format("2006-01-02 15:04:05") })
+	}
+	return "", nil, fmt.Errorf("unsupported filter term %s:%s", t.Field, t.Value)
+}
+
+func compileDate(column string, t filter.Term, now time.Time, bind func(time.Time) any) (string, []any, error) {
+	switch strings.ToLower(t.Value) {
+	case "none":
+		return column + " IS NULL", nil, nil
+	case "any":
+		return column + " IS NOT NULL", nil, nil
+	}
+	start, end, err := filter.ResolveDate(t.Value, now)
+	if err != nil {
+		return "", nil, err
+	}
+	switch t.Op {
+	case filter.OpLt:
+		return column + " < ?", []any{bind(start)}, nil
+	case filter.OpLte:
+		if start.Equal(end) {
+			return column + " <= ?", []any{bind(end)}, nil
+		}
+		return column + " < ?", []any{bind(end)}, nil
+	case filter.OpGt:
+		if start.Equal(end) {
+			return column + " > ?", []any{bind(end)}, nil
+		}
+		return column + " >= ?", []any{bind(end)}, nil
+	case filter.OpGte:
+		return column + " >= ?", []any{bind(start)}, nil
+	}
+	if start.Equal(end) {
+		return column + " = ?", []any{bind(start)}, nil
+	}
+	return "(" + column + " >= ? AND " + column + " < ?)", []any{bind(start), bind(end)}, nil
+}
+
+func sqlOp(op filter.Op) string {
+	if op == filter.OpEq {
+		return "="
+	}
+	return string(op)
+}
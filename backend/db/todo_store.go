@@ -0,0 +1,175 @@
+package db
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const todoColumns = "id, title, description, completed, priority, due_date, remind_at, timezone, all_day, repeat, repeat_anchor, repeat_catch_up, tags, project_id, series_id, series_index, owner_id, created_at, updated_at, completed_at, deleted_at"
+
+// TodoStore is the SQLite implementation of service.TodoRepository.
+type TodoStore struct {
+	q     Querier
+	owner owner
+}
+
+// NewTodoStore returns a store of the todos owned by ownerID, or of every
+// user's todos when ownerID is 0.
+func NewTodoStore(q Querier, ownerID int) *TodoStore {
+	return &TodoStore{q: q, owner: owner(ownerID)}
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanTodo(row rowScanner, extra ...any) (Todo, error) {
+	var t Todo
+	var tagsJSON string
+	dest := append([]any{&t.ID, &t.Title, &t.Description, &t.Completed, &t.Priority, &t.DueDate, &t.RemindAt, &t.TimeZone, &t.AllDay, &t.Repeat, &t.RepeatAnchor, &t.RepeatCatchUp, &tagsJSON, &t.ProjectID, &t.SeriesID, &t.SeriesIndex, &t.OwnerID, &t.CreatedAt, &t.UpdatedAt, &t.CompletedAt, &t.DeletedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return t, err
+	}
+	if tagsJSON != "" {
+		json.Unmarshal([]byte(tagsJSON), &t.Tags)
+	}
+	if t.Tags == nil {
+		t.Tags = []string{}
+	}
+	t.localize()
+	return t, nil
+}
+
+func encodeTags(tags []string) string {
+	if tags == nil {
+		return "[]"
+	}
+	tagsJSON, _ := json.Marshal(tags)
+	return string(tagsJSON)
+}
+
+// repeatAnchor stores the default anchor for todos created without one.
+func repeatAnchor(anchor string) string {
+	if anchor == "" {
+		return "due"
+	}
+	return anchor
+}
+
+// utc normalizes a timestamp before it is stored. Times are kept as text,
+// so mixing zones would break the range comparisons used by List and the
+// reminder scheduler.
+func utc(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
+func (s *TodoStore) Create(t *Todo) (int64, error) {
+	res, err := s.q.Exec("INSERT INTO todos (title, description, priority, due_date, remind_at, timezone, all_day, repeat, repeat_anchor, repeat_catch_up, tags, project_id, series_id, series_index, owner_id, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", t.Title, t.Description, t.Priority, utc(t.DueDate), utc(t.RemindAt), t.TimeZone, t.AllDay, t.Repeat, repeatAnchor(t.RepeatAnchor), t.RepeatCatchUp, encodeTags(t.Tags), t.ProjectID, t.SeriesID, t.SeriesIndex, s.owner.of(t.OwnerID), t.UpdatedAt.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (s *TodoStore) Get(id int) (*Todo, error) {
+	t, err := scanTodo(s.q.QueryRow("SELECT "+todoColumns+" FROM todos WHERE id = ? AND deleted_at IS NULL AND "+s.owner.owns("owner_id"), id))
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// List returns the todos matching q in the order it asks for. When q.Limit
+// cuts the result short it also returns the cursor for the next page.
+func (s *TodoStore) List(q TodoQuery) ([]Todo, *TodoCursor, error) {
+	where, order, args, keys, err := buildTodoQuery(q, s.owner)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Select the sort keys alongside each row so the last one can become the
+	// cursor. Dates are cast so they round-trip as the text SQLite compares.
+	cols := todoColumns
+	for _, k := range keys {
+		expr := k.expr
+		if expr == "todos.created_at" {
+			expr = "CAST(todos.created_at AS TEXT)"
+		}
+		cols += ", " + expr
+	}
+	query := "SELECT " + cols + " FROM todos" + where + order
+	if q.Limit > 0 {
+		// Fetch one extra row to learn whether there is a next page
+		query += " LIMIT ?"
+		args = append(args, q.Limit+1)
+	}
+
+	rows, err := s.q.Query(query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var todos []Todo
+	var last []any
+	for rows.Next() {
+		if q.Limit > 0 && len(todos) == q.Limit {
+			return todos, &TodoCursor{Sort: q.Sort, Desc: q.Desc, Keys: last}, nil
+		}
+		values := make([]any, len(keys))
+		dest := make([]any, len(keys))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		t, err := scanTodo(rows, dest...)
+		if err != nil {
+			return nil, nil, err
+		}
+		todos = append(todos, t)
+		last = values
+	}
+	return todos, nil, rows.Err()
+}
+
+// ListBySeries returns the occurrences of a series in order.
+func (s *TodoStore) ListBySeries(seriesID int) ([]Todo, error) {
+	return s.query("SELECT "+todoColumns+" FROM todos WHERE series_id = ? AND deleted_at IS NULL AND "+s.owner.owns("owner_id")+" ORDER BY series_index ASC", seriesID)
+}
+
+func (s *TodoStore) query(query string, args ...any) ([]Todo, error) {
+	rows, err := s.q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var todos []Todo
+	for rows.Next() {
+		t, err := scanTodo(rows)
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, t)
+	}
+	return todos, rows.Err()
+}
+
+// UpdateStatus completes or reopens a todo at the given time. Completing
+// one that is already completed keeps the time it was first completed.
+func (s *TodoStore) UpdateStatus(id int, completed bool, at time.Time) error {
+	return execOne(s.q, "UPDATE todos SET completed = ?1, completed_at = CASE WHEN ?1 THEN COALESCE(completed_at, ?2) END, updated_at = ?2 WHERE id = ?3 AND deleted_at IS NULL AND "+s.owner.owns("owner_id"), completed, at.UTC(), id)
+}
+
+func (s *TodoStore) Update(t *Todo) error {
+	return execOne(s.q, "UPDATE todos SET title = ?, description = ?, priority = ?, due_date = ?, remind_at = ?, timezone = ?, all_day = ?, repeat = ?, repeat_anchor = ?, repeat_catch_up = ?, tags = ?, project_id = ?, series_id = ?, series_index = ?, updated_at = ? WHERE id = ? AND deleted_at IS NULL AND "+s.owner.owns("owner_id"), t.Title, t.Description, t.Priority, utc(t.DueDate), utc(t.RemindAt), t.TimeZone, t.AllDay, t.Repeat, repeatAnchor(t.RepeatAnchor), t.RepeatCatchUp, encodeTags(t.Tags), t.ProjectID, t.SeriesID, t.SeriesIndex, t.UpdatedAt.UTC(), t.ID)
+}
+
+// Delete moves a todo to the trash. Its subtasks go with it, without being
+// marked, so restoring the todo brings them back.
+func (s *TodoStore) Delete(id int) error {
+	return execOne(s.q, "UPDATE todos SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL AND "+s.owner.owns("owner_id"), time.Now().UTC(), id)
+}
@@ -0,0 +1,106 @@
+package db
+
+import "time"
+
+// TrashStore is the SQLite implementation of service.TrashRepository.
+type TrashStore struct {
+	q     Querier
+	owner owner
+}
+
+// NewTrashStore returns a store of the trash of ownerID, or of every user's
+// when ownerID is 0.
+func NewTrashStore(q Querier, ownerID int) *TrashStore {
+	return &TrashStore{q: q, owner: owner(ownerID)}
+}
+
+// restoreTodoSet brings a todo back from the trash. One whose place in its
+// series has been taken by a new occurrence leaves the series.
+const restoreTodoSet = `deleted_at = NULL,
+	series_id = CASE WHEN EXISTS (
+		SELECT 1 FROM todos o WHERE o.series_id = todos.series_id AND o.series_index = todos.series_index AND o.deleted_at IS NULL
+	) THEN NULL ELSE series_id END`
+
+// List returns what is in the trash, most recently deleted first.
+func (s *TrashStore) List() (*Trash, error) {
+	var trash Trash
+	var err error
+	trash.Projects, err = NewProjectStore(s.q, int(s.owner)).query("SELECT " + projectColumns + " FROM projects WHERE deleted_at IS NOT NULL AND " + s.owner.owns("owner_id") + " ORDER BY deleted_at DESC, id DESC")
+	if err != nil {
+		return nil, err
+	}
+	trash.Todos, err = NewTodoStore(s.q, int(s.owner)).query(`SELECT ` + todoColumns + ` FROM todos
+		WHERE deleted_at IS NOT NULL AND ` + s.owner.owns("owner_id") + ` AND NOT EXISTS (
+			SELECT 1 FROM projects p WHERE p.id = todos.project_id AND p.deleted_at = todos.deleted_at
+		)
+		ORDER BY deleted_at DESC, id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	trash.Subtasks, err = NewSubtaskStore(s.q, int(s.owner)).query("SELECT " + subtaskColumns + " FROM subtasks WHERE deleted_at IS NOT NULL AND " + s.owner.ownsLiveTodo("todo_id") + " ORDER BY deleted_at DESC, id DESC")
+	if err != nil {
+		return nil, err
+	}
+	return &trash, nil
+}
+
+// RestoreTodo takes a todo out of the trash, along with its subtasks. If
+// its project is still in the trash it comes back without one.
+func (s *TrashStore) RestoreTodo(id int) error {
+	return execOne(s.q, `UPDATE todos SET `+restoreTodoSet+`,
+		project_id = CASE WHEN project_id IN (SELECT id FROM projects WHERE deleted_at IS NULL) THEN project_id END
+		WHERE id = ? AND deleted_at IS NOT NULL AND `+s.owner.owns("owner_id"), id)
+}
+
+// RestoreProject takes a project out of the trash, along with the todos
+// that were deleted with it.
+func (s *TrashStore) RestoreProject(id int) error {
+	_, err := s.q.Exec(`UPDATE todos SET `+restoreTodoSet+`
+		WHERE project_id = ?1 AND deleted_at = (SELECT deleted_at FROM projects WHERE id = ?1 AND `+s.owner.owns("owner_id")+`)`, id)
+	if err != nil {
+		return err
+	}
+	return execOne(s.q, "UPDATE projects SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL AND "+s.owner.owns("owner_id"), id)
+}
+
+// RestoreSubtask takes a subtask out of the trash. A subtask of a todo in
+// the trash can't be restored on its own.
+func (s *TrashStore) RestoreSubtask(id int) error {
+	return execOne(s.q, "UPDATE subtasks SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL AND "+s.owner.ownsLiveTodo("todo_id"), id)
+}
+
+// Purge deletes for good what was moved to the trash before the given time,
+// with the subtasks, reminders and notifications of the todos it deletes.
+// It returns how many projects, todos and subtasks were deleted.
+func (s *TrashStore) Purge(before time.Time) (int, error) {
+	before = before.UTC()
+	purgedTodos := "SELECT id FROM todos WHERE deleted_at < ?1 AND " + s.owner.owns("owner_id")
+	purgedProjects := "SELECT id FROM projects WHERE deleted_at < ?1 AND " + s.owner.owns("owner_id")
+
+	var total int64
+	for _, stmt := range []struct {
+		query string
+		count bool
+	}{
+		{"DELETE FROM subtasks WHERE (deleted_at < ?1 AND " + s.owner.ownsTodo("todo_id") + ") OR todo_id IN (" + purgedTodos + ")", true},
+		{"DELETE FROM reminders WHERE todo_id IN (" + purgedTodos + ")", false},
+		{"DELETE FROM notifications WHERE todo_id IN (" + purgedTodos + ")", false},
+		{"DELETE FROM todos WHERE id IN (" + purgedTodos + ")", true},
+		{"UPDATE todos SET project_id = NULL WHERE project_id IN (" + purgedProjects + ")", false},
+		{"UPDATE series SET project_id = NULL WHERE project_id IN (" + purgedProjects + ")", false},
+		{"DELETE FROM projects WHERE id IN (" + purgedProjects + ")", true},
+	} {
+		res, err := s.q.Exec(stmt.query, before)
+		if err != nil {
+			return 0, err
+		}
+		if stmt.count {
+			n, err := res.RowsAffected()
+			if err != nil {
+				return 0, err
+			}
+			total += n
+		}
+	}
+	return int(total), nil
+}
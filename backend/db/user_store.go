@@ -0,0 +1,164 @@
+package db
+
+import "time"
+
+// UserStore is the SQLite implementation of service.UserRepository.
+type UserStore struct {
+	q Querier
+}
+
+func NewUserStore(q Querier) *UserStore {
+	return &UserStore{q: q}
+}
+
+const userColumns = "id, username, password_hash, created_at"
+
+func scanUser(row rowScanner) (User, error) {
+	var u User
+	err := row.Scan(&u.ID, &u.Username, &u.PasswordHash, &u.CreatedAt)
+	return u, err
+}
+
+func (s *UserStore) Create(u *User) (int64, error) {
+	res, err := s.q.Exec("INSERT INTO users (username, password_hash) VALUES (?, ?)", u.Username, u.PasswordHash)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (s *UserStore) Get(id int) (*User, error) {
+	u, err := scanUser(s.q.QueryRow("SELECT "+userColumns+" FROM users WHERE id = ?", id))
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// GetByName looks a user up by username, ignoring case.
+func (s *UserStore) GetByName(username string) (*User, error) {
+	u, err := scanUser(s.q.QueryRow("SELECT "+userColumns+" FROM users WHERE username = ?", username))
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (s *UserStore) List() ([]User, error) {
+	rows, err := s.q.Query("SELECT " + userColumns + " FROM users ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+func (s *UserStore) UpdatePassword(id int, hash string) error {
+	_, err := s.q.Exec("UPDATE users SET password_hash = ? WHERE id = ?", hash, id)
+	return err
+}
+
+// ClaimUnowned gives the user every todo and project that has no owner,
+// i.e. those created before there were users.
+func (s *UserStore) ClaimUnowned(id int) error {
+	if _, err := s.q.Exec("UPDATE todos SET owner_id = ? WHERE owner_id IS NULL", id); err != nil {
+		return err
+	}
+	_, err := s.q.Exec("UPDATE projects SET owner_id = ? WHERE owner_id IS NULL", id)
+	return err
+}
+
+// TokenStore is the SQLite implementation of service.TokenRepository.
+type TokenStore struct {
+	q Querier
+}
+
+func NewTokenStore(q Querier) *TokenStore {
+	return &TokenStore{q: q}
+}
+
+const tokenColumns = "id, user_id, kind, name, hash, created_at, expires_at, last_used_at"
+
+func scanToken(row rowScanner) (Token, error) {
+	var t Token
+	err := row.Scan(&t.ID, &t.UserID, &t.Kind, &t.Name, &t.Hash, &t.CreatedAt, &t.ExpiresAt, &t.LastUsedAt)
+	return t, err
+}
+
+func (s *TokenStore) Create(t *Token) (int64, error) {
+	res, err := s.q.Exec("INSERT INTO tokens (user_id, kind, name, hash, expires_at) VALUES (?, ?, ?, ?, ?)", t.UserID, t.Kind, t.Name, t.Hash, utc(t.ExpiresAt))
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// GetByHash finds the token whose secret hashes to hash.
+func (s *TokenStore) GetByHash(hash string) (*Token, error) {
+	t, err := scanToken(s.q.QueryRow("SELECT "+tokenColumns+" FROM tokens WHERE hash = ?", hash))
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ListByUser returns a user's tokens of one kind, newest first.
+func (s *TokenStore) ListByUser(userID int, kind string) ([]Token, error) {
+	rows, err := s.q.Query("SELECT "+tokenColumns+" FROM tokens WHERE user_id = ? AND kind = ? ORDER BY id DESC", userID, kind)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []Token
+	for rows.Next() {
+		t, err := scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+// Touch records that a token was used at the given time.
+func (s *TokenStore) Touch(id int, at time.Time) error {
+	_, err := s.q.Exec("UPDATE tokens SET last_used_at = ? WHERE id = ?", at.UTC(), id)
+	return err
+}
+
+// Delete revokes one of a user's tokens. It reports false if the user has
+// no such token.
+func (s *TokenStore) Delete(id, userID int) (bool, error) {
+	res, err := s.q.Exec("DELETE FROM tokens WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// DeleteByUser revokes all of a user's tokens of one kind.
+func (s *TokenStore) DeleteByUser(userID int, kind string) error {
+	_, err := s.q.Exec("DELETE FROM tokens WHERE user_id = ? AND kind = ?", userID, kind)
+	return err
+}
+
+// DeleteExpired removes the tokens that expired before now.
+func (s *TokenStore) DeleteExpired(now time.Time) (int, error) {
+	res, err := s.q.Exec("DELETE FROM tokens WHERE expires_at IS NOT NULL AND expires_at < ?", now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
@@ -0,0 +1,319 @@
+// Package filter parses the todo filter language used by GET /api/todos?filter=.
+//
+// A filter is a whitespace-separated list of terms that must all match:
+//
+//	project:Work priority:high due<2026-11-01 tag:urgent -completed
+//
+// Terms are either free text (matched against title and description) or
+// field:value pairs. Date and priority fields also accept <, <=, > and >=.
+// Any term can be negated with a leading "-", and values containing spaces
+// can be double-quoted (project:"Side Projects").
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Op is the comparison a term applies between its field and value.
+type Op string
+
+const (
+	OpEq  Op = ":"
+	OpLt  Op = "<"
+	OpLte Op = "<="
+	OpGt  Op = ">"
+	OpGte Op = ">="
+)
+
+// Fields understood by the parser.
+const (
+	FieldText     = ""
+	FieldProject  = "project"
+	FieldPriority = "priority"
+	FieldDue      = "due"
+	FieldCreated  = "created"
+	FieldTag      = "tag"
+	FieldRepeat   = "repeat"
+	FieldIs       = "is"
+)
+
+// Term is a single condition of a filter.
+type Term struct {
+	Negate bool
+	Field  string
+	Op     Op
+	Value  string
+}
+
+// Filter is a parsed filter expression; an empty filter matches everything.
+type Filter struct {
+	Terms []Term
+}
+
+// SyntaxError reports a malformed filter expression.
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("filter: %s at position %d", e.Msg, e.Pos)
+}
+
+// SavedFilter is a named filter that can be referenced as view:<name>.
+type SavedFilter struct {
+	Name  string `json:"name"`
+	Query string `json:"query"`
+}
+
+// SavedFilters are the built-in smart views.
+var SavedFilters = []SavedFilter{
+	{Name: "inbox", Query: "project:none"},
+	{Name: "today", Query: "due:today"},
+	{Name: "upcoming", Query: "due>=now due<=+7d"},
+	{Name: "overdue", Query: "due<now -is:completed"},
+}
+
+// Priorities in ascending order; comparisons on priority use this ordering.
+var Priorities = []string{"low", "medium", "high"}
+
+var isValues = map[string]bool{"completed": true, "active": true, "overdue": true, "repeating": true}
+
+// Parse parses and validates a filter expression.
+func Parse(input string) (*Filter, error) {
+	return parse(input, 0)
+}
+
+func parse(input string, depth int) (*Filter, error) {
+	f := &Filter{}
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+	for _, tok := range tokens {
+		term, err := parseTerm(tok)
+		if err != nil {
+			return nil, err
+		}
+		if term.Field == "view" {
+			if term.Negate || term.Op != OpEq {
+				return nil, &SyntaxError{Pos: tok.pos, Msg: "views can only be used as view:<name>"}
+			}
+			saved, ok := lookupSaved(term.Value)
+			if !ok || depth > 0 {
+				return nil, &SyntaxError{Pos: tok.pos, Msg: fmt.Sprintf("unknown view %q", term.Value)}
+			}
+			sub, err := parse(saved.Query, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			f.Terms = append(f.Terms, sub.Terms...)
+			continue
+		}
+		f.Terms = append(f.Terms, term)
+	}
+	return f, nil
+}
+
+func lookupSaved(name string) (SavedFilter, bool) {
+	for _, s := range SavedFilters {
+		if strings.EqualFold(s.Name, name) {
+			return s, true
+		}
+	}
+	return SavedFilter{}, false
+}
+
+type token struct {
+	pos int
+	raw string
+}
+
+// tokenize splits on whitespace outside double quotes. Quotes are kept so
+// parseTerm can tell "due:today" (text) from due:today (a field).
+func tokenize(input string) ([]token, error) {
+	var tokens []token
+	start, quoteStart, inQuote := -1, 0, false
+	for i, r := range input {
+		switch {
+		case r == '"':
+			if start < 0 {
+				start = i
+			}
+			if !inQuote {
+				quoteStart = i
+			}
+			inQuote = !inQuote
+		case unicode.IsSpace(r) && !inQuote:
+			if start >= 0 {
+				tokens = append(tokens, token{pos: start, raw: input[start:i]})
+				start = -1
+			}
+		default:
+			if start < 0 {
+				start = i
+			}
+		}
+	}
+	if inQuote {
+		return nil, &SyntaxError{Pos: quoteStart, Msg: "unterminated quote"}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{pos: start, raw: input[start:]})
+	}
+	return tokens, nil
+}
+
+func unquote(s string) string {
+	return strings.ReplaceAll(s, `"`, "")
+}
+
+func parseTerm(tok token) (Term, error) {
+	text := tok.raw
+	term := Term{Op: OpEq}
+	if strings.HasPrefix(text, "-") && len(text) > 1 {
+		term.Negate = true
+		text = text[1:]
+	}
+
+	// Free text, either a bare word or a quoted phrase.
+	i := strings.IndexAny(text, `:<>"`)
+	if i <= 0 || text[i] == '"' || !isFieldName(text[:i]) {
+		term.Field = FieldText
+		term.Value = unquote(text)
+		// A bare flag such as "completed" is shorthand for is:completed.
+		if isValues[strings.ToLower(text)] {
+			term.Field = FieldIs
+			term.Value = strings.ToLower(text)
+		}
+		if term.Value == "" {
+			return term, &SyntaxError{Pos: tok.pos, Msg: "empty term"}
+		}
+		return term, nil
+	}
+
+	term.Field = strings.ToLower(text[:i])
+	rest := text[i:]
+	switch {
+	case strings.HasPrefix(rest, "<="):
+		term.Op, rest = OpLte, rest[2:]
+	case strings.HasPrefix(rest, ">="):
+		term.Op, rest = OpGte, rest[2:]
+	case strings.HasPrefix(rest, "<"):
+		term.Op, rest = OpLt, rest[1:]
+	case strings.HasPrefix(rest, ">"):
+		term.Op, rest = OpGt, rest[1:]
+	default:
+		rest = rest[1:]
+	}
+	term.Value = unquote(rest)
+	if term.Value == "" {
+		return term, &SyntaxError{Pos: tok.pos, Msg: fmt.Sprintf("missing value for %s", term.Field)}
+	}
+	if err := validate(term); err != nil {
+		return term, &SyntaxError{Pos: tok.pos, Msg: err.Error()}
+	}
+	return term, nil
+}
+
+func isFieldName(s string) bool {
+	switch strings.ToLower(s) {
+	case FieldProject, FieldPriority, FieldDue, FieldCreated, FieldTag, FieldRepeat, FieldIs, "view":
+		return true
+	}
+	return false
+}
+
+func validate(t Term) error {
+	switch t.Field {
+	case FieldPriority:
+		if PriorityRank(t.Value) < 0 {
+			return fmt.Errorf("unknown priority %q", t.Value)
+		}
+	case FieldDue, FieldCreated:
+		if isNoneOrAny(t.Value) {
+			if t.Op != OpEq {
+				return fmt.Errorf("%s:%s cannot be compared", t.Field, t.Value)
+			}
+			return nil
+		}
+		if _, _, err := ResolveDate(t.Value, time.Now()); err != nil {
+			return err
+		}
+	case FieldIs:
+		if !isValues[strings.ToLower(t.Value)] {
+			return fmt.Errorf("unknown is:%s", t.Value)
+		}
+		fallthrough
+	case FieldProject, FieldTag, FieldRepeat, "view":
+		if t.Op != OpEq {
+			return fmt.Errorf("%s does not support %s", t.Field, t.Op)
+		}
+	}
+	return nil
+}
+
+func isNoneOrAny(v string) bool {
+	v = strings.ToLower(v)
+	return v == "none" || v == "any"
+}
+
+// PriorityRank returns the position of p in Priorities, or -1.
+func PriorityRank(p string) int {
+	for i, v := range Priorities {
+		if strings.EqualFold(v, p) {
+			return i
+		}
+	}
+	return -1
+}
+
+// ResolveDate resolves a date value to the half-open interval [start, end)
+// it denotes, relative to now. Dates are whole days in now's location;
+// "now" and full timestamps are instants (start == end).
+//
+// Accepted forms: now, today, tomorrow, yesterday, +Nd/-Nd (days from
+// today), +Nw/-Nw (weeks), YYYY-MM-DD and RFC 3339 timestamps.
+func ResolveDate(value string, now time.Time) (time.Time, time.Time, error) {
+	v := strings.ToLower(value)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	day := func(d time.Time) (time.Time, time.Time, error) { return d, d.AddDate(0, 0, 1), nil }
+
+	switch v {
+	case "now":
+		return now, now, nil
+	case "today":
+		return day(today)
+	case "tomorrow":
+		return day(today.AddDate(0, 0, 1))
+	case "yesterday":
+		return day(today.AddDate(0, 0, -1))
+	}
+
+	if len(v) >= 3 && (v[0] == '+' || v[0] == '-') {
+		unit, digits := v[len(v)-1], v[1:len(v)-1]
+		// Atoi would take a second sign, making +-3d three days ago
+		n, err := strconv.Atoi(digits)
+		if err == nil && strings.Trim(digits, "0123456789") == "" && (unit == 'd' || unit == 'w') {
+			if unit == 'w' {
+				n *= 7
+			}
+			if v[0] == '-' {
+				n = -n
+			}
+			return day(today.AddDate(0, 0, n))
+		}
+	}
+
+	if d, err := time.ParseInLocation("2006-01-02", value, now.Location()); err == nil {
+		return day(d)
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, t, nil
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("invalid date %q", value)
+}
@@ -0,0 +1,95 @@
+package filter
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	f, err := Parse(`project:Work priority>=medium due<2026-11-01 tag:urgent -completed "fix build" project:"Side Projects"`)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	want := []Term{
+		{Field: FieldProject, Op: OpEq, Value: "Work"},
+		{Field: FieldPriority, Op: OpGte, Value: "medium"},
+		{Field: FieldDue, Op: OpLt, Value: "2026-11-01"},
+		{Field: FieldTag, Op: OpEq, Value: "urgent"},
+		{Negate: true, Field: FieldIs, Op: OpEq, Value: "completed"},
+		{Field: FieldText, Op: OpEq, Value: "fix build"},
+		{Field: FieldProject, Op: OpEq, Value: "Side Projects"},
+	}
+	if !reflect.DeepEqual(f.Terms, want) {
+		t.Errorf("Unexpected terms:\n got %+v\nwant %+v", f.Terms, want)
+	}
+}
+
+func TestParseViewsAndText(t *testing.T) {
+	f, err := Parse(`view:overdue "due:today" http://example.com`)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	want := []Term{
+		{Field: FieldDue, Op: OpLt, Value: "now"},
+		{Negate: true, Field: FieldIs, Op: OpEq, Value: "completed"},
+		{Field: FieldText, Op: OpEq, Value: "due:today"},
+		{Field: FieldText, Op: OpEq, Value: "http://example.com"},
+	}
+	if !reflect.DeepEqual(f.Terms, want) {
+		t.Errorf("Unexpected terms:\n got %+v\nwant %+v", f.Terms, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, input := range []string{
+		"priority:urgent",
+		"due<someday",
+		"tag>foo",
+		"project:",
+		`"unterminated`,
+		"view:nope",
+		"-view:today",
+		"is:sleeping",
+		"due<none",
+	} {
+		_, err := Parse(input)
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("Parse(%q): expected SyntaxError, got %v", input, err)
+		}
+	}
+}
+
+func TestResolveDate(t *testing.T) {
+	now := time.Date(2026, 3, 10, 15, 30, 0, 0, time.UTC)
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+
+	cases := []struct {
+		value      string
+		start, end time.Time
+	}{
+		{"now", now, now},
+		{"today", day(2026, 3, 10), day(2026, 3, 11)},
+		{"tomorrow", day(2026, 3, 11), day(2026, 3, 12)},
+		{"-1d", day(2026, 3, 9), day(2026, 3, 10)},
+		{"+2w", day(2026, 3, 24), day(2026, 3, 25)},
+		{"2026-11-01", day(2026, 11, 1), day(2026, 11, 2)},
+	}
+	for _, c := range cases {
+		start, end, err := ResolveDate(c.value, now)
+		if err != nil {
+			t.Errorf("ResolveDate(%q) failed: %v", c.value, err)
+			continue
+		}
+		if !start.Equal(c.start) || !end.Equal(c.end) {
+			t.Errorf("ResolveDate(%q) = [%v, %v), want [%v, %v)", c.value, start, end, c.start, c.end)
+		}
+	}
+	for _, bad := range []string{"+-3d", "--3d", "++3d", "+d", "soon"} {
+		if start, _, err := ResolveDate(bad, now); err == nil {
+			t.Errorf("ResolveDate(%q) = %v, want an error", bad, start)
+		}
+	}
+}
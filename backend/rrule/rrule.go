@@ -0,0 +1,609 @@
+// Package rrule evaluates the subset of RFC 5545 recurrence rules used for
+// repeating todos.
+//
+// A rule is stored as text, either one of the legacy keywords (daily, weekly,
+// monthly, yearly, weekdays) or RFC 5545 content lines:
+//
+//	DTSTART:20260106T090000Z
+//	RRULE:FREQ=MONTHLY;BYDAY=2TU;COUNT=12
+//	EXDATE:20260310T090000Z
+//
+// The "RRULE:" prefix is optional for a single-line rule. Supported parts are
+// FREQ (DAILY, WEEKLY, MONTHLY, YEARLY), INTERVAL, COUNT, UNTIL, BYDAY,
+// BYMONTHDAY, BYMONTH, BYSETPOS and WKST.
+package rrule
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Frequency int
+
+const (
+	Daily Frequency = iota
+	Weekly
+	Monthly
+	Yearly
+)
+
+var freqNames = map[string]Frequency{"DAILY": Daily, "WEEKLY": Weekly, "MONTHLY": Monthly, "YEARLY": Yearly}
+
+func (f Frequency) String() string {
+	for name, v := range freqNames {
+		if v == f {
+			return name
+		}
+	}
+	return "UNKNOWN"
+}
+
+// WeekdayNum is a BYDAY entry such as TU, 2TU or -1FR. N is 0 for "every".
+type WeekdayNum struct {
+	N   int
+	Day time.Weekday
+}
+
+var dayNames = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+func (w WeekdayNum) String() string {
+	if w.N == 0 {
+		return dayNames[w.Day]
+	}
+	return strconv.Itoa(w.N) + dayNames[w.Day]
+}
+
+// ExDate is an excluded occurrence. Date-only exclusions match the whole day.
+type ExDate struct {
+	Time     time.Time
+	DateOnly bool
+}
+
+// Rule is a parsed recurrence rule.
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	Count      int       // 0 means unbounded
+	Until      time.Time // zero means unbounded
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []int
+	BySetPos   []int
+	WeekStart  time.Weekday
+	DTStart    time.Time // zero means "anchor on the time passed to After"
+	ExDates    []ExDate
+}
+
+// Aliases maps the legacy repeat keywords to their RRULE equivalents.
+var Aliases = map[string]string{
+	"daily":    "FREQ=DAILY",
+	"weekly":   "FREQ=WEEKLY",
+	"monthly":  "FREQ=MONTHLY",
+	"yearly":   "FREQ=YEARLY",
+	"weekdays": "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR",
+}
+
+// IsAlias reports whether s is one of the legacy repeat keywords.
+func IsAlias(s string) bool {
+	_, ok := Aliases[strings.ToLower(strings.TrimSpace(s))]
+	return ok
+}
+
+// Parse parses a repeat rule in any of the accepted forms.
+func Parse(s string) (*Rule, error) {
+	s = strings.TrimSpace(s)
+	if alias, ok := Aliases[strings.ToLower(s)]; ok {
+		s = alias
+	}
+
+	r := &Rule{Interval: 1, WeekStart: time.Monday}
+	sawRule := false
+	lines := strings.FieldsFunc(s, func(c rune) bool { return c == '\n' || c == '\r' })
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		name, value := "RRULE", line
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			name, value = line[:i], line[i+1:]
+		}
+		params := strings.Split(name, ";")
+		switch strings.ToUpper(params[0]) {
+		case "RRULE":
+			if sawRule {
+				return nil, fmt.Errorf("rrule: only one RRULE is supported")
+			}
+			if err := r.parseRule(value); err != nil {
+				return nil, err
+			}
+			sawRule = true
+		case "DTSTART":
+			t, _, err := parseTime(value, params[1:])
+			if err != nil {
+				return nil, fmt.Errorf("rrule: DTSTART: %w", err)
+			}
+			r.DTStart = t
+		case "EXDATE":
+			for _, v := range strings.Split(value, ",") {
+				t, dateOnly, err := parseTime(v, params[1:])
+				if err != nil {
+					return nil, fmt.Errorf("rrule: EXDATE: %w", err)
+				}
+				r.ExDates = append(r.ExDates, ExDate{Time: t, DateOnly: dateOnly})
+			}
+		default:
+			return nil, fmt.Errorf("rrule: unsupported property %q", params[0])
+		}
+	}
+	if !sawRule {
+		return nil, fmt.Errorf("rrule: missing RRULE")
+	}
+	return r, nil
+}
+
+func (r *Rule) parseRule(value string) error {
+	sawFreq := false
+	for _, part := range strings.Split(value, ";") {
+		if part == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(part, "=")
+		if !ok {
+			return fmt.Errorf("rrule: malformed part %q", part)
+		}
+		var err error
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			f, ok := freqNames[strings.ToUpper(val)]
+			if !ok {
+				return fmt.Errorf("rrule: unsupported FREQ %q", val)
+			}
+			r.Freq, sawFreq = f, true
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(val)
+			if err == nil && r.Interval < 1 {
+				err = fmt.Errorf("must be positive")
+			}
+		case "COUNT":
+			r.Count, err = strconv.Atoi(val)
+			if err == nil && r.Count < 1 {
+				err = fmt.Errorf("must be positive")
+			}
+		case "UNTIL":
+			var dateOnly bool
+			r.Until, dateOnly, err = parseTime(val, nil)
+			if err == nil && dateOnly {
+				// A date-only UNTIL includes that whole day
+				r.Until = r.Until.AddDate(0, 0, 1).Add(-time.Nanosecond)
+			}
+		case "BYDAY":
+			r.ByDay, err = parseByDay(val)
+		case "BYMONTHDAY":
+			r.ByMonthDay, err = parseInts(val, -31, 31)
+		case "BYMONTH":
+			r.ByMonth, err = parseInts(val, 1, 12)
+		case "BYSETPOS":
+			r.BySetPos, err = parseInts(val, -366, 366)
+		case "WKST":
+			var days []WeekdayNum
+			days, err = parseByDay(val)
+			if err == nil && (len(days) != 1 || days[0].N != 0) {
+				err = fmt.Errorf("must be a single weekday")
+			}
+			if err == nil {
+				r.WeekStart = days[0].Day
+			}
+		default:
+			return fmt.Errorf("rrule: unsupported part %q", key)
+		}
+		if err != nil {
+			return fmt.Errorf("rrule: %s: %w", strings.ToUpper(key), err)
+		}
+	}
+	if !sawFreq {
+		return fmt.Errorf("rrule: missing FREQ")
+	}
+	if len(r.BySetPos) > 0 && len(r.ByDay) == 0 && len(r.ByMonthDay) == 0 && len(r.ByMonth) == 0 {
+		return fmt.Errorf("rrule: BYSETPOS requires another BYxxx part")
+	}
+	return nil
+}
+
+func parseByDay(val string) ([]WeekdayNum, error) {
+	var days []WeekdayNum
+	for _, item := range strings.Split(val, ",") {
+		item = strings.ToUpper(strings.TrimSpace(item))
+		if len(item) < 2 {
+			return nil, fmt.Errorf("invalid weekday %q", item)
+		}
+		day := -1
+		for i, name := range dayNames {
+			if item[len(item)-2:] == name {
+				day = i
+			}
+		}
+		if day < 0 {
+			return nil, fmt.Errorf("invalid weekday %q", item)
+		}
+		n := 0
+		if prefix := item[:len(item)-2]; prefix != "" {
+			var err error
+			if n, err = strconv.Atoi(prefix); err != nil || n == 0 || n < -53 || n > 53 {
+				return nil, fmt.Errorf("invalid weekday %q", item)
+			}
+		}
+		days = append(days, WeekdayNum{N: n, Day: time.Weekday(day)})
+	}
+	return days, nil
+}
+
+func parseInts(val string, min, max int) ([]int, error) {
+	var out []int
+	for _, item := range strings.Split(val, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil || n == 0 || n < min || n > max {
+			return nil, fmt.Errorf("invalid value %q", item)
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+// parseTime parses an RFC 5545 DATE or DATE-TIME value. A TZID parameter sets
+// the zone of floating times, which are otherwise taken as UTC.
+func parseTime(value string, params []string) (time.Time, bool, error) {
+	loc := time.UTC
+	dateOnly := len(value) == 8
+	for _, p := range params {
+		k, v, _ := strings.Cut(p, "=")
+		switch strings.ToUpper(k) {
+		case "TZID":
+			l, err := time.LoadLocation(v)
+			if err != nil {
+				return time.Time{}, false, err
+			}
+			loc = l
+		case "VALUE":
+			dateOnly = strings.EqualFold(v, "DATE")
+		}
+	}
+	if dateOnly {
+		t, err := time.ParseInLocation("20060102", value, loc)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		return t, false, err
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	return t, false, err
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// String renders the rule as RFC 5545 content lines.
+func (r *Rule) String() string {
+	var lines []string
+	if !r.DTStart.IsZero() {
+		lines = append(lines, "DTSTART:"+formatTime(r.DTStart))
+	}
+
+	parts := []string{"FREQ=" + r.Freq.String()}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+formatTime(r.Until))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, d := range r.ByDay {
+			days[i] = d.String()
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	for _, p := range []struct {
+		name string
+		vals []int
+	}{{"BYMONTHDAY", r.ByMonthDay}, {"BYMONTH", r.ByMonth}, {"BYSETPOS", r.BySetPos}} {
+		if len(p.vals) > 0 {
+			strs := make([]string, len(p.vals))
+			for i, v := range p.vals {
+				strs[i] = strconv.Itoa(v)
+			}
+			parts = append(parts, p.name+"="+strings.Join(strs, ","))
+		}
+	}
+	if r.WeekStart != time.Monday {
+		parts = append(parts, "WKST="+dayNames[r.WeekStart])
+	}
+	lines = append(lines, "RRULE:"+strings.Join(parts, ";"))
+
+	if len(r.ExDates) > 0 {
+		var times, dates []string
+		for _, ex := range r.ExDates {
+			if ex.DateOnly {
+				dates = append(dates, ex.Time.Format("20060102"))
+			} else {
+				times = append(times, formatTime(ex.Time))
+			}
+		}
+		if len(times) > 0 {
+			lines = append(lines, "EXDATE:"+strings.Join(times, ","))
+		}
+		if len(dates) > 0 {
+			lines = append(lines, "EXDATE;VALUE=DATE:"+strings.Join(dates, ","))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// horizon bounds the search for a next occurrence, so rules that can never
+// match again (e.g. BYMONTHDAY=31;BYMONTH=2) terminate.
+const horizon = 100 // years
+
+// After returns the first occurrence strictly after t. Without a DTSTART the
+// rule is anchored on t itself. It reports false once COUNT or UNTIL is
+// exhausted or no occurrence exists within the search horizon.
+func (r *Rule) After(t time.Time) (time.Time, bool) {
+	start := r.DTStart
+	if start.IsZero() {
+		start = t
+	}
+	limit := t.AddDate(horizon, 0, 0)
+
+	// COUNT has to be counted from DTSTART; otherwise skip straight to the
+	// period containing t.
+	period := 0
+	if r.Count == 0 {
+		if units := r.unitsBetween(start, t); units > r.Interval {
+			period = units/r.Interval - 1
+		}
+	}
+
+	count := 0
+	for ; ; period++ {
+		periodStart, candidates := r.expand(start, period)
+		if periodStart.After(limit) {
+			return time.Time{}, false
+		}
+		for _, c := range candidates {
+			if c.Before(start) {
+				continue
+			}
+			if !r.Until.IsZero() && c.After(r.Until) {
+				return time.Time{}, false
+			}
+			// Excluded dates still use up a COUNT slot (RFC 5545 3.8.5.1)
+			count++
+			if r.Count > 0 && count > r.Count {
+				return time.Time{}, false
+			}
+			if c.After(t) && !r.excluded(c) {
+				return c, true
+			}
+		}
+	}
+}
+
+func (r *Rule) excluded(t time.Time) bool {
+	for _, ex := range r.ExDates {
+		if ex.DateOnly {
+			y1, m1, d1 := t.Date()
+			y2, m2, d2 := ex.Time.Date()
+			if y1 == y2 && m1 == m2 && d1 == d2 {
+				return true
+			}
+		} else if ex.Time.Equal(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// unitsBetween counts whole frequency units from start to t.
+func (r *Rule) unitsBetween(start, t time.Time) int {
+	t = t.In(start.Location())
+	switch r.Freq {
+	case Daily:
+		return daysBetween(start, t)
+	case Weekly:
+		return daysBetween(start, t) / 7
+	case Monthly:
+		return (t.Year()-start.Year())*12 + int(t.Month()-start.Month())
+	default:
+		return t.Year() - start.Year()
+	}
+}
+
+// expand returns the start of the given period and its candidate
+// occurrences in ascending order, after BYSETPOS.
+func (r *Rule) expand(start time.Time, period int) (time.Time, []time.Time) {
+	loc := start.Location()
+	y, m, d := start.Date()
+	hh, mm, ss := start.Clock()
+	at := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, hh, mm, ss, start.Nanosecond(), loc)
+	}
+	step := period * r.Interval
+
+	var periodStart time.Time
+	var days []time.Time
+	switch r.Freq {
+	case Daily:
+		periodStart = at(y, m, d+step)
+		if r.matchesDay(periodStart) {
+			days = append(days, periodStart)
+		}
+	case Weekly:
+		offset := (int(start.Weekday()) - int(r.WeekStart) + 7) % 7
+		periodStart = at(y, m, d-offset+7*step)
+		for i := 0; i < 7; i++ {
+			day := periodStart.AddDate(0, 0, i)
+			if len(r.ByDay) == 0 && day.Weekday() != start.Weekday() {
+				continue
+			}
+			if r.matchesWeekday(day) && r.matchesMonth(day) {
+				days = append(days, day)
+			}
+		}
+	case Monthly:
+		periodStart = at(y, m+time.Month(step), 1)
+		if r.matchesMonth(periodStart) {
+			days = r.expandMonth(periodStart, d)
+		}
+	case Yearly:
+		periodStart = at(y+step, 1, 1)
+		switch {
+		case len(r.ByMonth) > 0:
+			for _, month := range r.ByMonth {
+				days = append(days, r.expandMonth(at(periodStart.Year(), time.Month(month), 1), d)...)
+			}
+		case len(r.ByDay) > 0:
+			// Without BYMONTH, BYDAY ordinals count within the year
+			days = r.expandRange(periodStart, periodStart.AddDate(1, 0, 0))
+		case len(r.ByMonthDay) > 0:
+			for month := 1; month <= 12; month++ {
+				days = append(days, r.expandMonth(at(periodStart.Year(), time.Month(month), 1), d)...)
+			}
+		default:
+			if day := at(periodStart.Year(), m, d); day.Month() == m {
+				days = append(days, day)
+			}
+		}
+	}
+
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return periodStart, r.applySetPos(days)
+}
+
+// expandMonth lists the days of the month starting at first that match
+// BYDAY/BYMONTHDAY, or defaultDay when neither is set.
+func (r *Rule) expandMonth(first time.Time, defaultDay int) []time.Time {
+	if len(r.ByDay) == 0 && len(r.ByMonthDay) == 0 {
+		day := first.AddDate(0, 0, defaultDay-1)
+		if day.Month() != first.Month() {
+			return nil // e.g. the 31st in a 30-day month
+		}
+		return []time.Time{day}
+	}
+	return r.expandRange(first, first.AddDate(0, 1, 0))
+}
+
+// expandRange lists the days in [from, to) matching BYDAY (with ordinals
+// relative to the range) and BYMONTHDAY.
+func (r *Rule) expandRange(from, to time.Time) []time.Time {
+	var all []time.Time
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		if r.matchesMonthDay(day) {
+			all = append(all, day)
+		}
+	}
+	if len(r.ByDay) == 0 {
+		return all
+	}
+
+	var out []time.Time
+	for _, day := range all {
+		for _, wd := range r.ByDay {
+			if day.Weekday() != wd.Day {
+				continue
+			}
+			if wd.N == 0 || ordinalInRange(day, from, to, wd.N) {
+				out = append(out, day)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// ordinalInRange reports whether day is the n-th (or -n-th from the end)
+// occurrence of its weekday in [from, to).
+func ordinalInRange(day, from, to time.Time, n int) bool {
+	if n > 0 {
+		nth := daysBetween(from, day)/7 + 1
+		return nth == n
+	}
+	nth := (daysBetween(day, to)-1)/7 + 1
+	return nth == -n
+}
+
+// daysBetween counts calendar days from a to b. Days are compared by date,
+// so a 23- or 25-hour day across a DST change counts as one.
+func daysBetween(a, b time.Time) int {
+	y1, m1, d1 := a.Date()
+	y2, m2, d2 := b.Date()
+	return int(time.Date(y2, m2, d2, 0, 0, 0, 0, time.UTC).Sub(time.Date(y1, m1, d1, 0, 0, 0, 0, time.UTC)).Hours() / 24)
+}
+
+func (r *Rule) applySetPos(days []time.Time) []time.Time {
+	if len(r.BySetPos) == 0 || len(days) == 0 {
+		return days
+	}
+	var out []time.Time
+	seen := map[int]bool{}
+	for _, pos := range r.BySetPos {
+		i := pos - 1
+		if pos < 0 {
+			i = len(days) + pos
+		}
+		if i >= 0 && i < len(days) && !seen[i] {
+			seen[i] = true
+			out = append(out, days[i])
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Before(out[j]) })
+	return out
+}
+
+func (r *Rule) matchesDay(t time.Time) bool {
+	return r.matchesMonth(t) && r.matchesMonthDay(t) && r.matchesWeekday(t)
+}
+
+func (r *Rule) matchesMonth(t time.Time) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, m := range r.ByMonth {
+		if time.Month(m) == t.Month() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesMonthDay(t time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	daysInMonth := time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, t.Location()).Day()
+	for _, md := range r.ByMonthDay {
+		if md == t.Day() || (md < 0 && daysInMonth+md+1 == t.Day()) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesWeekday checks BYDAY ignoring ordinals, as used by DAILY and WEEKLY.
+func (r *Rule) matchesWeekday(t time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, wd := range r.ByDay {
+		if wd.Day == t.Weekday() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+package rrule
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustTime(t *testing.T, s string) time.Time {
+	t.Helper()
+	v, err := time.Parse("2006-01-02 15:04", s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+// occurrences walks the rule from t, returning up to n next occurrences.
+func occurrences(r *Rule, t time.Time, n int) []string {
+	var out []string
+	for i := 0; i < n; i++ {
+		next, ok := r.After(t)
+		if !ok {
+			break
+		}
+		out = append(out, next.Format("2006-01-02 15:04 Mon"))
+		t = next
+	}
+	return out
+}
+
+func TestAfter(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  string
+		start string
+		n     int
+		want  []string
+	}{
+		{"daily alias", "daily", "2026-01-30 09:00", 3,
+			[]string{"2026-01-31 09:00 Sat", "2026-02-01 09:00 Sun", "2026-02-02 09:00 Mon"}},
+		{"weekdays alias", "weekdays", "2026-01-30 09:00", 2,
+			[]string{"2026-02-02 09:00 Mon", "2026-02-03 09:00 Tue"}},
+		{"weekly alias", "Weekly", "2026-01-30 09:00", 2,
+			[]string{"2026-02-06 09:00 Fri", "2026-02-13 09:00 Fri"}},
+		{"monthly skips short months", "monthly", "2026-01-31 09:00", 3,
+			[]string{"2026-03-31 09:00 Tue", "2026-05-31 09:00 Sun", "2026-07-31 09:00 Fri"}},
+		{"every other day", "FREQ=DAILY;INTERVAL=2", "2026-01-01 08:00", 2,
+			[]string{"2026-01-03 08:00 Sat", "2026-01-05 08:00 Mon"}},
+		{"biweekly tue thu", "RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH", "2026-01-06 10:00", 4,
+			[]string{"2026-01-08 10:00 Thu", "2026-01-20 10:00 Tue", "2026-01-22 10:00 Thu", "2026-02-03 10:00 Tue"}},
+		{"second tuesday", "FREQ=MONTHLY;BYDAY=2TU", "2026-01-01 09:00", 3,
+			[]string{"2026-01-13 09:00 Tue", "2026-02-10 09:00 Tue", "2026-03-10 09:00 Tue"}},
+		{"last friday", "FREQ=MONTHLY;BYDAY=-1FR", "2026-01-01 09:00", 2,
+			[]string{"2026-01-30 09:00 Fri", "2026-02-27 09:00 Fri"}},
+		{"last weekday of month", "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1", "2026-01-01 17:00", 3,
+			[]string{"2026-01-30 17:00 Fri", "2026-02-27 17:00 Fri", "2026-03-31 17:00 Tue"}},
+		{"last day of month", "FREQ=MONTHLY;BYMONTHDAY=-1", "2026-01-15 12:00", 3,
+			[]string{"2026-01-31 12:00 Sat", "2026-02-28 12:00 Sat", "2026-03-31 12:00 Tue"}},
+		{"1st and 15th", "FREQ=MONTHLY;BYMONTHDAY=1,15", "2026-01-01 12:00", 3,
+			[]string{"2026-01-15 12:00 Thu", "2026-02-01 12:00 Sun", "2026-02-15 12:00 Sun"}},
+		{"friday the 13th", "FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13", "2026-01-01 00:00", 2,
+			[]string{"2026-02-13 00:00 Fri", "2026-03-13 00:00 Fri"}},
+		{"yearly thanksgiving", "FREQ=YEARLY;BYMONTH=11;BYDAY=4TH", "2026-01-01 12:00", 2,
+			[]string{"2026-11-26 12:00 Thu", "2027-11-25 12:00 Thu"}},
+		{"yearly leap day", "FREQ=YEARLY", "2024-02-29 12:00", 1,
+			[]string{"2028-02-29 12:00 Tue"}},
+		{"until inclusive", "FREQ=DAILY;UNTIL=20260103", "2026-01-01 09:00", 5,
+			[]string{"2026-01-02 09:00 Fri", "2026-01-03 09:00 Sat"}},
+		{"exdate skipped", "RRULE:FREQ=DAILY\nEXDATE:20260102T090000Z", "2026-01-01 09:00", 2,
+			[]string{"2026-01-03 09:00 Sat", "2026-01-04 09:00 Sun"}},
+		{"exdate whole day", "RRULE:FREQ=DAILY\nEXDATE;VALUE=DATE:20260102", "2026-01-01 09:00", 1,
+			[]string{"2026-01-03 09:00 Sat"}},
+		{"count from dtstart", "DTSTART:20260101T090000Z\nRRULE:FREQ=DAILY;COUNT=3", "2026-01-01 09:00", 5,
+			[]string{"2026-01-02 09:00 Fri", "2026-01-03 09:00 Sat"}},
+		{"dtstart aligns interval", "DTSTART:20260101T090000Z\nRRULE:FREQ=DAILY;INTERVAL=3", "2026-01-05 09:00", 2,
+			[]string{"2026-01-07 09:00 Wed", "2026-01-10 09:00 Sat"}},
+		{"never matches", "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30", "2026-01-01 00:00", 1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.rule, err)
+			}
+			got := occurrences(r, mustTime(t, tt.start), tt.n)
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"fortnightly",
+		"FREQ=HOURLY",
+		"INTERVAL=2",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=-1",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=MONTHLY;BYDAY=0TU",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=MONTHLY;BYSETPOS=1",
+		"FREQ=DAILY;UNTIL=tomorrow",
+		"FREQ=DAILY;FOO=1",
+		"RRULE:FREQ=DAILY\nRRULE:FREQ=WEEKLY",
+		"RRULE:FREQ=DAILY\nRDATE:20260101",
+		"DTSTART:20260101T090000Z",
+	} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("Parse(%q) should fail", s)
+		}
+	}
+}
+
+func TestStringRoundTrip(t *testing.T) {
+	in := "DTSTART;TZID=Europe/Berlin:20260105T090000\nRRULE:FREQ=MONTHLY;INTERVAL=2;COUNT=5;BYDAY=-1FR;WKST=SU\nEXDATE:20260327T080000Z"
+	r, err := Parse(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "DTSTART:20260105T080000Z\nRRULE:FREQ=MONTHLY;INTERVAL=2;COUNT=5;BYDAY=-1FR;WKST=SU\nEXDATE:20260327T080000Z"
+	if got := r.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	again, err := Parse(r.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.String() != want {
+		t.Errorf("round trip changed rule: %q", again.String())
+	}
+	if got := mustParse(t, "weekdays").String(); got != "RRULE:FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR" {
+		t.Errorf("alias expanded to %q", got)
+	}
+}
+
+func mustParse(t *testing.T, s string) *Rule {
+	t.Helper()
+	r, err := Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestAfterDST(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no zoneinfo:", err)
+	}
+	// DST starts on 2026-03-08 and ends on 2026-11-01 in New York
+	tests := []struct {
+		name  string
+		rule  string
+		start time.Time
+		n     int
+		want  []string
+	}{
+		{"second sunday", "FREQ=MONTHLY;BYDAY=2SU", time.Date(2026, 3, 1, 9, 0, 0, 0, ny), 2,
+			[]string{"2026-03-08 09:00 Sun", "2026-04-12 09:00 Sun"}},
+		{"third sunday", "FREQ=MONTHLY;BYDAY=3SU", time.Date(2026, 3, 1, 9, 0, 0, 0, ny), 2,
+			[]string{"2026-03-15 09:00 Sun", "2026-04-19 09:00 Sun"}},
+		{"last sunday", "FREQ=MONTHLY;BYDAY=-1SU", time.Date(2026, 10, 1, 9, 0, 0, 0, ny), 2,
+			[]string{"2026-10-25 09:00 Sun", "2026-11-29 09:00 Sun"}},
+		{"every other day", "FREQ=DAILY;INTERVAL=2", time.Date(2026, 3, 7, 0, 30, 0, 0, ny), 3,
+			[]string{"2026-03-09 00:30 Mon", "2026-03-11 00:30 Wed", "2026-03-13 00:30 Fri"}},
+		{"biweekly", "DTSTART;TZID=America/New_York:20261025T000000\nRRULE:FREQ=WEEKLY;INTERVAL=2", time.Date(2026, 11, 7, 12, 0, 0, 0, ny), 2,
+			[]string{"2026-11-08 00:00 Sun", "2026-11-22 00:00 Sun"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.rule, err)
+			}
+			got := occurrences(r, tt.start, tt.n)
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"todo/backend/service"
+)
+
+// GetAgendaHandler serves the agenda for ?date= (default today) in ?tz=
+// (default UTC). ?format=markdown or ?format=text renders it instead of
+// returning JSON.
+func (s *Server) GetAgendaHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	format := q.Get("format")
+	if format != "" && format != "json" && format != "markdown" && format != "text" {
+		badRequest(w, "format", "must be json, markdown or text")
+		return
+	}
+
+	agenda, err := s.service(r).GetAgenda(q.Get("date"), q.Get("tz"))
+	if errors.Is(err, service.ErrInvalidTimeZone) {
+		badRequest(w, "tz", err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, err, "agenda")
+		return
+	}
+
+	switch format {
+	case "markdown":
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		io.WriteString(w, agenda.Text(true))
+	case "text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, agenda.Text(false))
+	default:
+		json.NewEncoder(w).Encode(agenda)
+	}
+}
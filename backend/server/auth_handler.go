@@ -0,0 +1,119 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+	"todo/backend/db"
+	"todo/backend/service"
+)
+
+// sessionKey is the context key of the request's session.
+type sessionKey struct{}
+
+// session is who a request was authenticated as.
+type session struct {
+	user  *db.User
+	token *db.Token
+	svc   *service.Service // limited to user's data
+}
+
+func requestSession(r *http.Request) *session {
+	sess, _ := r.Context().Value(sessionKey{}).(*session)
+	return sess
+}
+
+// service returns the Service a request works on: the one of its user when
+// auth is required, otherwise the server's. On an undoable route it records
+// what the request changes.
+func (s *Server) service(r *http.Request) *service.Service {
+	svc := s.svc
+	if sess := requestSession(r); sess != nil {
+		svc = sess.svc
+	}
+	if rec, ok := r.Context().Value(recorderKey{}).(*service.Recorder); ok {
+		return svc.Recording(rec)
+	}
+	return svc
+}
+
+type loginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type loginResponse struct {
+	Token     string     `json:"token"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	User      *db.User   `json:"user"`
+}
+
+type tokenRequest struct {
+	Name string `json:"name,omitempty"` // what the token is for
+}
+
+func (s *Server) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	var req loginRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	token, user, err := s.svc.Login(req.Username, req.Password)
+	if err != nil {
+		writeError(w, err, "user")
+		return
+	}
+	json.NewEncoder(w).Encode(loginResponse{Token: token.Secret, ExpiresAt: token.ExpiresAt, User: user})
+}
+
+// LogoutHandler revokes the token the request was made with.
+func (s *Server) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	sess := requestSession(r)
+	if err := s.svc.RevokeToken(sess.user.ID, sess.token.ID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		writeError(w, err, "token")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) GetCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(requestSession(r).user)
+}
+
+func (s *Server) GetTokensHandler(w http.ResponseWriter, r *http.Request) {
+	tokens, err := s.svc.GetAPITokens(requestSession(r).user.ID)
+	if err != nil {
+		writeError(w, err, "token")
+		return
+	}
+	json.NewEncoder(w).Encode(tokens)
+}
+
+func (s *Server) CreateTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var req tokenRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	token, err := s.svc.CreateAPIToken(requestSession(r).user.ID, req.Name)
+	if err != nil {
+		writeError(w, err, "token")
+		return
+	}
+	json.NewEncoder(w).Encode(token)
+}
+
+func (s *Server) DeleteTokenHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.svc.RevokeToken(requestSession(r).user.ID, id); err != nil {
+		writeError(w, err, "token")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"todo/backend/service"
+)
+
+type batchRequest struct {
+	Operations []service.BatchOp `json:"operations"`
+	Atomic     bool              `json:"atomic,omitempty"` // roll everything back if one operation fails
+}
+
+// batchResult is how one operation of a batch went, in the order they were
+// sent.
+type batchResult struct {
+	ID    int       `json:"id"`
+	OK    bool      `json:"ok"`
+	Error *apiError `json:"error,omitempty"`
+}
+
+type batchResponse struct {
+	Results []batchResult `json:"results"`
+}
+
+// BatchTodosHandler runs several operations on todos in one transaction.
+// An atomic batch that fails is answered like the failing operation, with
+// nothing changed; otherwise each operation reports its own result.
+func (s *Server) BatchTodosHandler(w http.ResponseWriter, r *http.Request) {
+	var req batchRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	errs, err := s.service(r).Batch(req.Operations, req.Atomic)
+	if err != nil {
+		writeError(w, err, "todo")
+		return
+	}
+
+	res := batchResponse{Results: make([]batchResult, len(errs))}
+	for i, err := range errs {
+		res.Results[i] = batchResult{ID: req.Operations[i].ID, OK: err == nil}
+		if err != nil {
+			_, e := errorResponse(err, "todo")
+			res.Results[i].Error = &e
+		}
+	}
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,194 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"todo/backend/filter"
+	"todo/backend/service"
+)
+
+// Error codes of apiError, one per status.
+const (
+	codeBadRequest       = "bad_request"       // 400: malformed JSON, id or query parameter
+	codeUnauthorized     = "unauthorized"      // 401
+	codeForbidden        = "forbidden"         // 403
+	codeNotFound         = "not_found"         // 404
+	codeConflict         = "conflict"          // 409: the change clashes with the current state
+	codeValidationFailed = "validation_failed" // 422: well-formed, but fields have invalid values
+	codeInternal         = "internal"          // 500
+)
+
+// apiError is the body of every error response.
+type apiError struct {
+	Code    string               `json:"code"`
+	Message string               `json:"message"`
+	Details []service.FieldError `json:"details,omitempty"`
+}
+
+// writeJSONError writes an error response, like http.Error but as JSON.
+func writeJSONError(w http.ResponseWriter, status int, e apiError) {
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", "application/json")
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(e)
+}
+
+// badRequest reports a request that couldn't be understood, naming the
+// field, path value or query parameter at fault if there is one.
+func badRequest(w http.ResponseWriter, field, message string) {
+	e := apiError{Code: codeBadRequest, Message: message}
+	if field != "" {
+		e.Details = []service.FieldError{{Field: field, Message: message}}
+	}
+	writeJSONError(w, http.StatusBadRequest, e)
+}
+
+// requestFields names the request field each service validation error is
+// about; they are answered with 422.
+var requestFields = []struct {
+	err   error
+	field string
+}{
+	{service.ErrInvalidRepeat, "repeat"},
+	{service.ErrInvalidTimeZone, "timezone"},
+	{service.ErrInvalidProject, "project_id"},
+	{service.ErrInvalidReminder, "remind_at"},
+	{service.ErrInvalidSnooze, "for"},
+}
+
+// queryParams names the query parameter each service error is about; they
+// are answered with 400.
+var queryParams = []struct {
+	err   error
+	param string
+}{
+	{service.ErrInvalidSort, "sort"},
+	{service.ErrInvalidCursor, "cursor"},
+	{service.ErrInvalidLimit, "limit"},
+	{service.ErrInvalidScope, "scope"},
+	{service.ErrInvalidDate, "date"},
+}
+
+// writeError answers with the status and code that fit err. notFound names
+// the thing sql.ErrNoRows means is missing, e.g. "todo". Unexpected errors
+// are logged and answered with 500, without their text.
+func writeError(w http.ResponseWriter, err error, notFound string) {
+	status, e := errorResponse(err, notFound)
+	writeJSONError(w, status, e)
+}
+
+// errorResponse is the status and body writeError answers err with.
+func errorResponse(err error, notFound string) (int, apiError) {
+	var batchErr *service.BatchError
+	if errors.As(err, &batchErr) {
+		return batchErrorResponse(batchErr, notFound)
+	}
+	var validationErr *service.ValidationError
+	if errors.As(err, &validationErr) {
+		return http.StatusUnprocessableEntity, apiError{Code: codeValidationFailed, Message: err.Error(), Details: validationErr.Fields}
+	}
+	for _, f := range requestFields {
+		if errors.Is(err, f.err) {
+			return http.StatusUnprocessableEntity, apiError{
+				Code:    codeValidationFailed,
+				Message: err.Error(),
+				Details: []service.FieldError{{Field: f.field, Message: err.Error()}},
+			}
+		}
+	}
+	for _, p := range queryParams {
+		if errors.Is(err, p.err) {
+			return http.StatusBadRequest, apiError{
+				Code:    codeBadRequest,
+				Message: err.Error(),
+				Details: []service.FieldError{{Field: p.param, Message: err.Error()}},
+			}
+		}
+	}
+	var syntaxErr *filter.SyntaxError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return http.StatusBadRequest, apiError{Code: codeBadRequest, Message: err.Error(), Details: []service.FieldError{{Field: "filter", Message: err.Error()}}}
+	case errors.Is(err, sql.ErrNoRows):
+		return http.StatusNotFound, apiError{Code: codeNotFound, Message: notFound + " not found"}
+	case errors.Is(err, service.ErrNotificationClosed), errors.Is(err, service.ErrUserExists), errors.Is(err, service.ErrNoDueDate),
+		errors.Is(err, service.ErrUndoConflict):
+		return http.StatusConflict, apiError{Code: codeConflict, Message: err.Error()}
+	case errors.Is(err, service.ErrInvalidCredentials), errors.Is(err, service.ErrInvalidToken):
+		return http.StatusUnauthorized, apiError{Code: codeUnauthorized, Message: err.Error()}
+	default:
+		log.Printf("Internal error: %v", err)
+		return http.StatusInternalServerError, apiError{Code: codeInternal, Message: "internal server error"}
+	}
+}
+
+// batchErrorResponse answers a rolled back batch with the status of the
+// operation that failed, its details naming the operation, e.g.
+// operations[2].priority.
+func batchErrorResponse(err *service.BatchError, notFound string) (int, apiError) {
+	status, e := errorResponse(err.Err, notFound)
+	if status == http.StatusInternalServerError {
+		return status, e
+	}
+	op := fmt.Sprintf("operations[%d]", err.Index)
+	if len(e.Details) == 0 {
+		e.Details = []service.FieldError{{Field: op, Message: e.Message}}
+	} else {
+		details := make([]service.FieldError, len(e.Details))
+		for i, d := range e.Details {
+			details[i] = service.FieldError{Field: op + "." + d.Field, Message: d.Message}
+		}
+		e.Details = details
+	}
+	e.Message = fmt.Sprintf("operation %d: %s; nothing was changed", err.Index, e.Message)
+	return status, e
+}
+
+// decodeJSON reads the request body into v. It answers with 400 and
+// returns false if the body isn't valid JSON for v.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			badRequest(w, typeErr.Field, "must be a "+typeErr.Type.String())
+			return false
+		}
+		badRequest(w, "", "invalid JSON body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// pathID parses the {id} path value. It answers with 400 and returns false
+// if it isn't a positive integer.
+func pathID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		badRequest(w, "id", "must be a positive integer")
+		return 0, false
+	}
+	return id, true
+}
+
+// queryLimit parses the optional ?limit= query parameter, returning 0 when
+// it is absent. It answers with 400 and returns false if it isn't a
+// positive integer.
+func queryLimit(w http.ResponseWriter, r *http.Request) (int, bool) {
+	v := r.URL.Query().Get("limit")
+	if v == "" {
+		return 0, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		badRequest(w, "limit", "must be a positive integer")
+		return 0, false
+	}
+	return n, true
+}
@@ -3,72 +3,203 @@ package server
 import (
 	"encoding/json"
 	"net/http"
-	"strconv"
+	"reflect"
 	"time"
-	"todo/backend/db"
 	"todo/backend/service"
 )
 
-func GetTodosHandler(w http.ResponseWriter, r *http.Request) {
-	todos, err := service.GetTodos()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+// dateTime is a due date in a request body: an RFC 3339 timestamp, or a
+// plain YYYY-MM-DD date for all-day todos.
+type dateTime struct {
+	time.Time
+}
+
+func (d *dateTime) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		if t, err := time.Parse(time.DateOnly, s); err == nil {
+			d.Time = t
+			return nil
+		}
+	}
+	return d.Time.UnmarshalJSON(b)
+}
+
+func (d *dateTime) ptr() *time.Time {
+	if d == nil {
+		return nil
+	}
+	return &d.Time
+}
+
+// optional is a field of a JSON Merge Patch (RFC 7396): Set tells a field
+// that was left out, which keeps its value, from one given as null, which
+// clears it.
+type optional[T any] struct {
+	Set   bool
+	Value *T // nil for null
+}
+
+func (o *optional[T]) UnmarshalJSON(b []byte) error {
+	o.Set = true
+	if string(b) == "null" {
+		o.Value = nil
+		return nil
 	}
-	if todos == nil {
-		todos = []db.Todo{}
+	o.Value = new(T)
+	return json.Unmarshal(b, o.Value)
+}
+
+// elem lets the OpenAPI document describe the field as a nullable T.
+func (optional[T]) elem() reflect.Type {
+	return reflect.TypeFor[T]()
+}
+
+// orZero returns the patched value of a field that can't be empty: nil if
+// it was left out, the zero value if it was null.
+func (o optional[T]) orZero() *T {
+	if o.Set && o.Value == nil {
+		return new(T)
 	}
-	json.NewEncoder(w).Encode(todos)
+	return o.Value
 }
 
-func CreateTodoHandler(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		Title       string     `json:"title"`
-		Description string     `json:"description"`
-		Priority    string     `json:"priority"`
-		DueDate     *time.Time `json:"due_date"`
-		RemindAt    *time.Time `json:"remind_at"`
-		Repeat      string     `json:"repeat"`
-		Tags        []string   `json:"tags"`
-		ProjectID   *int       `json:"project_id"`
+// nullable returns the patch of a field that can be empty.
+func (o optional[T]) nullable() service.Nullable[T] {
+	return service.Nullable[T]{Set: o.Set, Value: o.Value}
+}
+
+// defaultPageSize applies when a client asks for a page without a limit.
+const defaultPageSize = 50
+
+func (s *Server) GetTodosHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	opts := service.TodoListOptions{
+		Filter: query.Get("filter"),
+		Sort:   query.Get("sort"),
+		Order:  query.Get("order"),
+		Cursor: query.Get("cursor"),
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	// Paginated requests get a {"items", "next_cursor"} envelope; plain
+	// requests keep returning the bare array.
+	paginated := query.Has("limit") || query.Has("cursor")
+	if paginated {
+		opts.Limit = defaultPageSize
+	}
+	limit, ok := queryLimit(w, r)
+	if !ok {
 		return
 	}
-	id, err := service.CreateTodo(req.Title, req.Description, req.Priority, req.DueDate, req.RemindAt, req.Repeat, req.Tags, req.ProjectID)
+	if limit > 0 {
+		opts.Limit = limit
+	}
+
+	page, err := s.service(r).GetTodosPage(opts)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err, "todo")
+		return
+	}
+	if paginated {
+		json.NewEncoder(w).Encode(page)
 		return
 	}
-	json.NewEncoder(w).Encode(map[string]int64{"id": id})
+	json.NewEncoder(w).Encode(page.Todos)
 }
 
-func UpdateTodoHandler(w http.ResponseWriter, r *http.Request) {
-	idStr := r.PathValue("id")
-	id, _ := strconv.Atoi(idStr)
+func (s *Server) GetSavedFiltersHandler(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(s.service(r).SavedFilters())
+}
+
+// idResponse answers a create with the new ID.
+type idResponse struct {
+	ID int64 `json:"id"`
+}
 
-	var req struct {
-		Completed   *bool      `json:"completed"`
-		Title       *string    `json:"title"`
-		Description *string    `json:"description"`
-		Priority    *string    `json:"priority"`
-		DueDate     *time.Time `json:"due_date"`
-		RemindAt    *time.Time `json:"remind_at"`
-		Repeat      *string    `json:"repeat"`
-		Tags        []string   `json:"tags"`
-		ProjectID   *int       `json:"project_id"`
+// Request bodies mark optional fields omitempty; the OpenAPI document lists
+// the others as required.
+
+type createTodoRequest struct {
+	Title         string     `json:"title"`
+	Description   string     `json:"description,omitempty"`
+	Priority      string     `json:"priority,omitempty"`
+	DueDate       *dateTime  `json:"due_date,omitempty"`
+	RemindAt      *time.Time `json:"remind_at,omitempty"`
+	TimeZone      string     `json:"timezone,omitempty"`
+	AllDay        bool       `json:"all_day,omitempty"`
+	Repeat        string     `json:"repeat,omitempty"`
+	RepeatAnchor  string     `json:"repeat_anchor,omitempty"`
+	RepeatCatchUp bool       `json:"repeat_catch_up,omitempty"`
+	Tags          []string   `json:"tags,omitempty"`
+	ProjectID     *int       `json:"project_id,omitempty"`
+}
+
+type updateTodoRequest struct {
+	Completed     *bool      `json:"completed,omitempty"`
+	Title         *string    `json:"title,omitempty"`
+	Description   *string    `json:"description,omitempty"`
+	Priority      *string    `json:"priority,omitempty"`
+	DueDate       *dateTime  `json:"due_date,omitempty"`
+	RemindAt      *time.Time `json:"remind_at,omitempty"`
+	TimeZone      string     `json:"timezone,omitempty"`
+	AllDay        bool       `json:"all_day,omitempty"`
+	Repeat        *string    `json:"repeat,omitempty"`
+	RepeatAnchor  string     `json:"repeat_anchor,omitempty"`
+	RepeatCatchUp bool       `json:"repeat_catch_up,omitempty"`
+	Tags          []string   `json:"tags,omitempty"`
+	ProjectID     *int       `json:"project_id,omitempty"`
+}
+
+// patchTodoRequest is a JSON Merge Patch of a todo. null clears due_date,
+// remind_at and project_id, and resets other fields to their default.
+type patchTodoRequest struct {
+	Completed     optional[bool]                 `json:"completed,omitempty"`
+	Title         optional[string]               `json:"title,omitempty"`
+	Description   optional[string]               `json:"description,omitempty"`
+	Priority      optional[string]               `json:"priority,omitempty"`
+	DueDate       optional[dateTime]             `json:"due_date,omitempty"`
+	RemindAt      optional[time.Time]            `json:"remind_at,omitempty"`
+	TimeZone      optional[string]               `json:"timezone,omitempty"`
+	AllDay        optional[bool]                 `json:"all_day,omitempty"`
+	Repeat        optional[string]               `json:"repeat,omitempty"`
+	RepeatAnchor  optional[service.RepeatAnchor] `json:"repeat_anchor,omitempty"`
+	RepeatCatchUp optional[bool]                 `json:"repeat_catch_up,omitempty"`
+	Tags          optional[[]string]             `json:"tags,omitempty"`
+	ProjectID     optional[int]                  `json:"project_id,omitempty"`
+}
+
+func (s *Server) CreateTodoHandler(w http.ResponseWriter, r *http.Request) {
+	var req createTodoRequest
+	if !decodeJSON(w, r, &req) {
+		return
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	repeat := service.Repeat{Rule: req.Repeat, Anchor: service.RepeatAnchor(req.RepeatAnchor), CatchUp: req.RepeatCatchUp}
+	when := service.Schedule{DueDate: req.DueDate.ptr(), RemindAt: req.RemindAt, TimeZone: req.TimeZone, AllDay: req.AllDay}
+	id, err := s.service(r).CreateTodo(req.Title, req.Description, req.Priority, when, repeat, req.Tags, req.ProjectID)
+	if err != nil {
+		writeError(w, err, "todo")
 		return
 	}
+	json.NewEncoder(w).Encode(idResponse{ID: id})
+}
 
-	if req.Completed != nil {
-		if err := service.UpdateTodoStatus(id, *req.Completed); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
+func (s *Server) UpdateTodoHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	var req updateTodoRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if req.Completed == nil && req.Title == nil {
+		writeJSONError(w, http.StatusUnprocessableEntity, apiError{
+			Code:    codeValidationFailed,
+			Message: "nothing to update: give completed or title",
+			Details: []service.FieldError{{Field: "title", Message: "is required unless completed is given"}},
+		})
+		return
 	}
 
 	if req.Title != nil {
@@ -80,16 +211,26 @@ func UpdateTodoHandler(w http.ResponseWriter, r *http.Request) {
 		if req.Description != nil {
 			description = *req.Description
 		}
-		repeat := ""
+		repeat := service.Repeat{Anchor: service.RepeatAnchor(req.RepeatAnchor), CatchUp: req.RepeatCatchUp}
 		if req.Repeat != nil {
-			repeat = *req.Repeat
+			repeat.Rule = *req.Repeat
 		}
 		tags := []string{}
 		if req.Tags != nil {
 			tags = req.Tags
 		}
-		if err := service.UpdateTodoDetails(id, *req.Title, description, priority, req.DueDate, req.RemindAt, repeat, tags, req.ProjectID); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		scope := service.EditScope(r.URL.Query().Get("scope"))
+		when := service.Schedule{DueDate: req.DueDate.ptr(), RemindAt: req.RemindAt, TimeZone: req.TimeZone, AllDay: req.AllDay}
+		err := s.service(r).UpdateTodoDetails(id, scope, *req.Title, description, priority, when, repeat, tags, req.ProjectID)
+		if err != nil {
+			writeError(w, err, "todo")
+			return
+		}
+	}
+
+	if req.Completed != nil {
+		if err := s.service(r).UpdateTodoStatus(id, *req.Completed); err != nil {
+			writeError(w, err, "todo")
 			return
 		}
 	}
@@ -97,13 +238,72 @@ func UpdateTodoHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
-func DeleteTodoHandler(w http.ResponseWriter, r *http.Request) {
-	idStr := r.PathValue("id")
-	id, _ := strconv.Atoi(idStr)
+// PatchTodoHandler applies a JSON Merge Patch to a todo: fields left out
+// keep their value. Unlike PUT it never resets fields that weren't sent.
+func (s *Server) PatchTodoHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
 
-	if err := service.DeleteTodo(id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	var req patchTodoRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	p := service.TodoPatch{
+		Completed:     req.Completed.orZero(),
+		Title:         req.Title.orZero(),
+		Description:   req.Description.orZero(),
+		Priority:      req.Priority.orZero(),
+		RemindAt:      req.RemindAt.nullable(),
+		TimeZone:      req.TimeZone.orZero(),
+		AllDay:        req.AllDay.orZero(),
+		Repeat:        req.Repeat.orZero(),
+		RepeatAnchor:  req.RepeatAnchor.orZero(),
+		RepeatCatchUp: req.RepeatCatchUp.orZero(),
+		ProjectID:     req.ProjectID.nullable(),
+	}
+	if req.DueDate.Set {
+		p.DueDate = service.Nullable[time.Time]{Set: true, Value: req.DueDate.Value.ptr()}
+	}
+	if tags := req.Tags.orZero(); tags != nil {
+		p.Tags = append([]string{}, *tags...)
+	}
+
+	scope := service.EditScope(r.URL.Query().Get("scope"))
+	if err := s.service(r).PatchTodo(id, scope, p); err != nil {
+		writeError(w, err, "todo")
 		return
 	}
 	w.WriteHeader(http.StatusOK)
 }
+
+func (s *Server) DeleteTodoHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.service(r).DeleteTodo(id); err != nil {
+		writeError(w, err, "todo")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetTodoHistoryHandler lists the changes made to a todo and its subtasks,
+// oldest first.
+func (s *Server) GetTodoHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	events, err := s.service(r).GetTodoHistory(id)
+	if err != nil {
+		writeError(w, err, "todo")
+		return
+	}
+	json.NewEncoder(w).Encode(events)
+}
@@ -0,0 +1,252 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// OpenAPIHandler serves the OpenAPI 3.1 document of the routes this server
+// has registered.
+func (s *Server) OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.OpenAPI())
+}
+
+// OpenAPI describes the API as an OpenAPI 3.1 document. The schemas are
+// derived from the request and response types of the routes: a field is
+// required unless it is omitempty, and nullable if it is a pointer.
+func (s *Server) OpenAPI() map[string]any {
+	g := &schemaGen{schemas: map[string]any{}}
+
+	security := map[string]any{}
+	schemes := map[string]any{}
+	if s.auth {
+		schemes["bearerAuth"] = map[string]any{"type": "http", "scheme": "bearer", "description": "A session token from /api/auth/login or an API token"}
+		security["bearerAuth"] = []string{}
+	}
+	if s.secret != "" {
+		schemes["desktopSecret"] = map[string]any{"type": "apiKey", "in": "header", "name": SecretHeader, "description": "The secret of this launch of the desktop app"}
+		security["desktopSecret"] = []string{}
+	}
+
+	errResponse := map[string]any{
+		"description": "Error",
+		"content":     jsonContent(g.schema(reflect.TypeFor[apiError]())),
+	}
+	paths := map[string]any{}
+	for _, rt := range s.routes() {
+		op := map[string]any{
+			"operationId": operationID(rt.handler),
+			"summary":     rt.summary,
+			"tags":        []string{strings.Split(rt.path, "/")[2]},
+		}
+
+		var params []any
+		for _, m := range pathParam.FindAllStringSubmatch(rt.path, -1) {
+			params = append(params, map[string]any{"name": m[1], "in": "path", "required": true, "schema": map[string]any{"type": "integer", "minimum": 1}})
+		}
+		for _, p := range rt.query {
+			params = append(params, map[string]any{"name": p.name, "in": "query", "description": p.description, "schema": map[string]any{"type": p.typ}})
+		}
+		if params != nil {
+			op["parameters"] = params
+		}
+
+		if rt.body != nil {
+			content := jsonContent(g.schema(reflect.TypeOf(rt.body)))
+			if rt.method == "PATCH" {
+				content["application/merge-patch+json"] = content["application/json"]
+			}
+			op["requestBody"] = map[string]any{"required": true, "content": content}
+		}
+
+		ok := map[string]any{"description": "OK"}
+		if rt.response != nil {
+			content := jsonContent(g.responseSchema(rt.response))
+			if rt.formats {
+				content["text/markdown"] = map[string]any{"schema": map[string]any{"type": "string"}}
+				content["text/plain"] = map[string]any{"schema": map[string]any{"type": "string"}}
+			}
+			ok["content"] = content
+		}
+		op["responses"] = map[string]any{"200": ok, "default": errResponse}
+
+		if rt.public {
+			public := map[string]any{}
+			if s.secret != "" {
+				public["desktopSecret"] = []string{}
+			}
+			op["security"] = []any{public}
+		}
+
+		item, _ := paths[rt.path].(map[string]any)
+		if item == nil {
+			item = map[string]any{}
+			paths[rt.path] = item
+		}
+		item[strings.ToLower(rt.method)] = op
+	}
+
+	components := map[string]any{"schemas": g.schemas}
+	doc := map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":   "Todo API",
+			"version": "1.0.0",
+		},
+		"paths":      paths,
+		"components": components,
+	}
+	if len(schemes) > 0 {
+		components["securitySchemes"] = schemes
+		doc["security"] = []any{security}
+	}
+	return doc
+}
+
+var pathParam = regexp.MustCompile(`\{(\w+)\}`)
+
+func jsonContent(schema map[string]any) map[string]any {
+	return map[string]any{"application/json": map[string]any{"schema": schema}}
+}
+
+// operationID names an operation after its handler, e.g. getTodos for
+// GetTodosHandler.
+func operationID(h http.HandlerFunc) string {
+	name := runtime.FuncForPC(reflect.ValueOf(h).Pointer()).Name()
+	name = name[strings.LastIndex(name, ".")+1:]
+	name = strings.TrimSuffix(strings.TrimSuffix(name, "-fm"), "Handler")
+	return string(unicode.ToLower(rune(name[0]))) + name[1:]
+}
+
+// schemaNames overrides the component name of types whose Go name doesn't
+// suit clients.
+var schemaNames = map[reflect.Type]string{
+	reflect.TypeFor[apiError](): "Error",
+}
+
+// schemaGen derives JSON Schemas from Go types, collecting named structs as
+// components.
+type schemaGen struct {
+	schemas map[string]any
+}
+
+func (g *schemaGen) responseSchema(v any) map[string]any {
+	if alts, ok := v.(oneOf); ok {
+		var schemas []any
+		for _, alt := range alts {
+			schemas = append(schemas, g.schema(reflect.TypeOf(alt)))
+		}
+		return map[string]any{"oneOf": schemas}
+	}
+	return g.schema(reflect.TypeOf(v))
+}
+
+func (g *schemaGen) schema(t reflect.Type) map[string]any {
+	switch t {
+	case reflect.TypeFor[time.Time]():
+		return map[string]any{"type": "string", "format": "date-time"}
+	case reflect.TypeFor[dateTime]():
+		return map[string]any{"type": "string", "description": "RFC 3339 timestamp, or YYYY-MM-DD for an all-day todo"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return nullable(g.schema(t.Elem()))
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if f, ok := reflect.Zero(t).Interface().(interface{ elem() reflect.Type }); ok {
+			return nullable(g.schema(f.elem()))
+		}
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		name := schemaNames[t]
+		if name == "" {
+			name = string(unicode.ToUpper(rune(t.Name()[0]))) + t.Name()[1:]
+		}
+		if _, ok := g.schemas[name]; !ok {
+			g.schemas[name] = nil // breaks cycles
+			g.schemas[name] = g.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	default:
+		return map[string]any{}
+	}
+}
+
+// object describes a struct the way encoding/json encodes it. No other
+// properties are allowed, so undocumented fields are caught.
+func (g *schemaGen) object(t reflect.Type) map[string]any {
+	props := map[string]any{}
+	required := []string{}
+	g.fields(t, props, &required)
+	return map[string]any{
+		"type":                 "object",
+		"properties":           props,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+func (g *schemaGen) fields(t reflect.Type, props map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.fields(ft, props, required)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = g.schema(f.Type)
+		if !strings.Contains(opts, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// nullable allows null besides what schema allows.
+func nullable(schema map[string]any) map[string]any {
+	if typ, ok := schema["type"].(string); ok {
+		n := make(map[string]any, len(schema))
+		for k, v := range schema {
+			n[k] = v
+		}
+		n["type"] = []string{typ, "null"}
+		return n
+	}
+	return map[string]any{"oneOf": []any{schema, map[string]any{"type": "null"}}}
+}
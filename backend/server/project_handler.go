@@ -3,15 +3,13 @@ package server
 import (
 	"encoding/json"
 	"net/http"
-	"strconv"
 	"todo/backend/db"
-	"todo/backend/service"
 )
 
-func GetProjectsHandler(w http.ResponseWriter, r *http.Request) {
-	projects, err := service.GetProjects()
+func (s *Server) GetProjectsHandler(w http.ResponseWriter, r *http.Request) {
+	projects, err := s.service(r).GetProjects()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err, "project")
 		return
 	}
 	if projects == nil {
@@ -20,51 +18,51 @@ func GetProjectsHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(projects)
 }
 
-func CreateProjectHandler(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		Name        string `json:"name"`
-		Description string `json:"description"`
-		Color       string `json:"color"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+type projectRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Color       string `json:"color,omitempty"` // e.g. #3B82F6; defaults to slate
+}
+
+func (s *Server) CreateProjectHandler(w http.ResponseWriter, r *http.Request) {
+	var req projectRequest
+	if !decodeJSON(w, r, &req) {
 		return
 	}
-	id, err := service.CreateProject(req.Name, req.Description, req.Color)
+	id, err := s.service(r).CreateProject(req.Name, req.Description, req.Color)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err, "project")
 		return
 	}
-	json.NewEncoder(w).Encode(map[string]int64{"id": id})
+	json.NewEncoder(w).Encode(idResponse{ID: id})
 }
 
-func UpdateProjectHandler(w http.ResponseWriter, r *http.Request) {
-	idStr := r.PathValue("id")
-	id, _ := strconv.Atoi(idStr)
-
-	var req struct {
-		Name        string `json:"name"`
-		Description string `json:"description"`
-		Color       string `json:"color"`
+func (s *Server) UpdateProjectHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+
+	var req projectRequest
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
-	if err := service.UpdateProject(id, req.Name, req.Description, req.Color); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := s.service(r).UpdateProject(id, req.Name, req.Description, req.Color); err != nil {
+		writeError(w, err, "project")
 		return
 	}
 	w.WriteHeader(http.StatusOK)
 }
 
-func DeleteProjectHandler(w http.ResponseWriter, r *http.Request) {
-	idStr := r.PathValue("id")
-	id, _ := strconv.Atoi(idStr)
+func (s *Server) DeleteProjectHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
 
-	if err := service.DeleteProject(id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := s.service(r).DeleteProject(id); err != nil {
+		writeError(w, err, "project")
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+	"todo/backend/db"
+)
+
+func (s *Server) GetRemindersHandler(w http.ResponseWriter, r *http.Request) {
+	todoID, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	reminders, err := s.service(r).GetReminders(todoID)
+	if err != nil {
+		writeError(w, err, "todo")
+		return
+	}
+	if reminders == nil {
+		reminders = []db.Reminder{}
+	}
+	json.NewEncoder(w).Encode(reminders)
+}
+
+// reminderRequest needs exactly one of its fields.
+type reminderRequest struct {
+	RemindAt         *time.Time `json:"remind_at,omitempty"`
+	BeforeDueMinutes *int       `json:"before_due_minutes,omitempty"`
+}
+
+// snoozeRequest needs one of its fields.
+type snoozeRequest struct {
+	For   string     `json:"for,omitempty"` // 10m, 1h or tomorrow
+	Until *time.Time `json:"until,omitempty"`
+}
+
+func (s *Server) CreateReminderHandler(w http.ResponseWriter, r *http.Request) {
+	todoID, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	var req reminderRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	id, err := s.service(r).AddReminder(todoID, req.RemindAt, req.BeforeDueMinutes)
+	if err != nil {
+		writeError(w, err, "todo")
+		return
+	}
+	json.NewEncoder(w).Encode(idResponse{ID: id})
+}
+
+func (s *Server) DeleteReminderHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.service(r).DeleteReminder(id); err != nil {
+		writeError(w, err, "reminder")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) GetNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	notifications, err := s.service(r).GetActiveNotifications()
+	if err != nil {
+		writeError(w, err, "notification")
+		return
+	}
+	json.NewEncoder(w).Encode(notifications)
+}
+
+func (s *Server) SnoozeNotificationHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	var req snoozeRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	s.writeNotificationResult(w, s.service(r).SnoozeNotification(id, req.For, req.Until))
+}
+
+func (s *Server) DismissNotificationHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	s.writeNotificationResult(w, s.service(r).DismissNotification(id))
+}
+
+func (s *Server) writeNotificationResult(w http.ResponseWriter, err error) {
+	if err != nil {
+		writeError(w, err, "notification")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"net/http"
+	"todo/backend/db"
+	"todo/backend/filter"
+	"todo/backend/service"
+)
+
+// route is one API endpoint. Handler registers the routes and the OpenAPI
+// document describes them, so the two can't disagree.
+type route struct {
+	method, path string
+	handler      http.HandlerFunc
+	summary      string
+	query        []param
+	body         any  // request body type, nil if there is none
+	response     any  // 200 response body type, nil for an empty body
+	formats      bool // also answers as text/markdown or text/plain, per ?format=
+	public       bool // needs no token when auth is required
+	undoable     bool // what it changes is recorded, so POST /api/undo can revert it
+}
+
+// param is a query parameter of a route.
+type param struct {
+	name        string
+	typ         string // JSON Schema type
+	description string
+}
+
+// oneOf is a response body that has one of several types.
+type oneOf []any
+
+// routes lists the endpoints of the API, in the order they are documented.
+func (s *Server) routes() []route {
+	var routes []route
+
+	// Auth
+	if s.auth {
+		routes = append(routes,
+			route{method: "POST", path: "/api/auth/login", handler: s.LoginHandler, summary: "Log in, starting a session", body: loginRequest{}, response: loginResponse{}, public: true},
+			route{method: "POST", path: "/api/auth/logout", handler: s.LogoutHandler, summary: "Revoke the token of the request"},
+			route{method: "GET", path: "/api/auth/me", handler: s.GetCurrentUserHandler, summary: "The user of the request", response: db.User{}},
+			route{method: "GET", path: "/api/tokens", handler: s.GetTokensHandler, summary: "List API tokens", response: []db.Token{}},
+			route{method: "POST", path: "/api/tokens", handler: s.CreateTokenHandler, summary: "Create an API token", body: tokenRequest{}, response: service.IssuedToken{}},
+			route{method: "DELETE", path: "/api/tokens/{id}", handler: s.DeleteTokenHandler, summary: "Revoke an API token or session"},
+		)
+	}
+
+	routes = append(routes,
+		// Todos
+		route{method: "GET", path: "/api/todos", handler: s.GetTodosHandler, summary: "List todos",
+			query: []param{
+				{"filter", "string", "Filter expression, e.g. due:today #work"},
+				{"sort", "string", "Field to sort by"},
+				{"order", "string", "asc or desc"},
+				{"limit", "integer", "Page size; with limit or cursor the response is a page"},
+				{"cursor", "string", "next_cursor of the previous page"},
+			},
+			response: oneOf{[]db.Todo{}, service.TodoPage{}}},
+		route{method: "POST", path: "/api/todos", handler: s.CreateTodoHandler, summary: "Create a todo", body: createTodoRequest{}, response: idResponse{}, undoable: true},
+		route{method: "PUT", path: "/api/todos/{id}", handler: s.UpdateTodoHandler, summary: "Update a todo's details or status",
+			query: []param{{"scope", "string", "For a repeating todo: this, or future (default)"}},
+			body:  updateTodoRequest{}, undoable: true},
+		route{method: "PATCH", path: "/api/todos/{id}", handler: s.PatchTodoHandler, summary: "Change some fields of a todo",
+			query: []param{{"scope", "string", "For a repeating todo: this, or future (default)"}},
+			body:  patchTodoRequest{}, undoable: true},
+		route{method: "DELETE", path: "/api/todos/{id}", handler: s.DeleteTodoHandler, summary: "Move a todo to the trash", undoable: true},
+		route{method: "GET", path: "/api/todos/{id}/history", handler: s.GetTodoHistoryHandler, summary: "Changes made to a todo and its subtasks", response: []db.Event{}},
+		route{method: "POST", path: "/api/todos/batch", handler: s.BatchTodosHandler, summary: "Change several todos in one transaction", body: batchRequest{}, response: batchResponse{}, undoable: true},
+		route{method: "GET", path: "/api/filters", handler: s.GetSavedFiltersHandler, summary: "List saved filters", response: []filter.SavedFilter{}},
+
+		// Projects
+		route{method: "GET", path: "/api/projects", handler: s.GetProjectsHandler, summary: "List projects", response: []db.Project{}},
+		route{method: "POST", path: "/api/projects", handler: s.CreateProjectHandler, summary: "Create a project", body: projectRequest{}, response: idResponse{}, undoable: true},
+		route{method: "PUT", path: "/api/projects/{id}", handler: s.UpdateProjectHandler, summary: "Update a project", body: projectRequest{}, undoable: true},
+		route{method: "DELETE", path: "/api/projects/{id}", handler: s.DeleteProjectHandler, summary: "Move a project and its todos to the trash", undoable: true},
+
+		// Subtasks
+		route{method: "GET", path: "/api/todos/{id}/subtasks", handler: s.GetSubtasksHandler, summary: "List a todo's subtasks", response: []db.Subtask{}},
+		route{method: "POST", path: "/api/todos/{id}/subtasks", handler: s.CreateSubtaskHandler, summary: "Add a subtask to a todo", body: createSubtaskRequest{}, response: idResponse{}, undoable: true},
+		route{method: "PUT", path: "/api/subtasks/{id}", handler: s.UpdateSubtaskHandler, summary: "Rename or check off a subtask", body: updateSubtaskRequest{}, undoable: true},
+		route{method: "PATCH", path: "/api/subtasks/{id}", handler: s.PatchSubtaskHandler, summary: "Change some fields of a subtask", body: patchSubtaskRequest{}, undoable: true},
+		route{method: "DELETE", path: "/api/subtasks/{id}", handler: s.DeleteSubtaskHandler, summary: "Move a subtask to the trash", undoable: true},
+
+		// Reminders
+		route{method: "GET", path: "/api/todos/{id}/reminders", handler: s.GetRemindersHandler, summary: "List a todo's reminders", response: []db.Reminder{}},
+		route{method: "POST", path: "/api/todos/{id}/reminders", handler: s.CreateReminderHandler, summary: "Add a reminder to a todo", body: reminderRequest{}, response: idResponse{}, undoable: true},
+		route{method: "DELETE", path: "/api/reminders/{id}", handler: s.DeleteReminderHandler, summary: "Delete a reminder", undoable: true},
+		route{method: "GET", path: "/api/notifications", handler: s.GetNotificationsHandler, summary: "List notifications that can be snoozed or dismissed", response: []db.Notification{}},
+		route{method: "POST", path: "/api/notifications/{id}/snooze", handler: s.SnoozeNotificationHandler, summary: "Deliver a notification again later", body: snoozeRequest{}},
+		route{method: "POST", path: "/api/notifications/{id}/dismiss", handler: s.DismissNotificationHandler, summary: "Stop delivering a notification"},
+
+		// Agenda
+		route{method: "GET", path: "/api/agenda", handler: s.GetAgendaHandler, summary: "What needs attention on a day",
+			query: []param{
+				{"date", "string", "YYYY-MM-DD, today (default), tomorrow, yesterday or +Nd/-Nd"},
+				{"tz", "string", "IANA zone the day is in (default UTC)"},
+				{"format", "string", "json (default), markdown or text"},
+			},
+			response: service.Agenda{}, formats: true},
+
+		// Statistics
+		route{method: "GET", path: "/api/stats", handler: s.GetStatsHandler, summary: "Completions, throughput and breakdowns over a range of days",
+			query: []param{
+				{"from", "string", "First day: YYYY-MM-DD, today or +Nd/-Nd (default 29 days before to)"},
+				{"to", "string", "Last day, likewise (default today)"},
+				{"tz", "string", "IANA zone the days are in (default UTC)"},
+			},
+			response: service.Stats{}},
+
+		// Series
+		route{method: "GET", path: "/api/series/{id}", handler: s.GetSeriesHandler, summary: "A series with its occurrences", response: service.SeriesHistory{}},
+		route{method: "PUT", path: "/api/series/{id}", handler: s.UpdateSeriesHandler, summary: "Change series settings", body: seriesRequest{}, undoable: true},
+
+		// Search
+		route{method: "GET", path: "/api/search", handler: s.SearchHandler, summary: "Full-text search over todos, subtasks and projects",
+			query: []param{
+				{"q", "string", "Search terms"},
+				{"limit", "integer", "Maximum number of results"},
+			},
+			response: []db.SearchResult{}},
+
+		// Trash
+		route{method: "GET", path: "/api/trash", handler: s.GetTrashHandler, summary: "List deleted projects, todos and subtasks", response: db.Trash{}},
+		route{method: "POST", path: "/api/trash/projects/{id}/restore", handler: s.RestoreProjectHandler, summary: "Restore a deleted project with the todos deleted along with it", undoable: true},
+		route{method: "POST", path: "/api/trash/todos/{id}/restore", handler: s.RestoreTodoHandler, summary: "Restore a deleted todo with its subtasks", undoable: true},
+		route{method: "POST", path: "/api/trash/subtasks/{id}/restore", handler: s.RestoreSubtaskHandler, summary: "Restore a deleted subtask", undoable: true},
+
+		// Undo
+		route{method: "POST", path: "/api/undo", handler: s.UndoHandler, summary: "Undo the latest change of the session", response: db.Command{}},
+		route{method: "POST", path: "/api/redo", handler: s.RedoHandler, summary: "Redo the change of the session undone last", response: db.Command{}},
+
+		// Docs
+		route{method: "GET", path: "/api/openapi.json", handler: s.OpenAPIHandler, summary: "This OpenAPI document", response: map[string]any{}, public: true},
+	)
+	return routes
+}
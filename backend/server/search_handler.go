@@ -0,0 +1,22 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+func (s *Server) SearchHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+
+	limit, ok := queryLimit(w, r)
+	if !ok {
+		return
+	}
+
+	results, err := s.service(r).Search(query, limit)
+	if err != nil {
+		writeError(w, err, "search")
+		return
+	}
+	json.NewEncoder(w).Encode(results)
+}
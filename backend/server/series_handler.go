@@ -0,0 +1,42 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+func (s *Server) GetSeriesHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	history, err := s.service(r).GetSeries(id)
+	if err != nil {
+		writeError(w, err, "series")
+		return
+	}
+	json.NewEncoder(w).Encode(history)
+}
+
+type seriesRequest struct {
+	CopySubtasks bool `json:"copy_subtasks"`
+}
+
+func (s *Server) UpdateSeriesHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	var req seriesRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if err := s.service(r).UpdateSeriesSettings(id, req.CopySubtasks); err != nil {
+		writeError(w, err, "series")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -2,61 +2,184 @@ package server
 
 import (
 	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"errors"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+	"todo/backend/service"
 )
 
-var srv *http.Server
+// Server exposes a Service over HTTP.
+type Server struct {
+	svc     *service.Service
+	srv     *http.Server
+	addr    net.Addr
+	auth    bool     // requests need a token and only see their user's data
+	secret  string   // requests need this in SecretHeader, unless empty
+	origins []string // browser origins allowed to call the API; all if empty
+}
+
+// SecretHeader carries the shared secret set by RequireSecret.
+const SecretHeader = "X-Todo-Secret"
+
+func New(svc *service.Service) *Server {
+	return &Server{svc: svc}
+}
+
+// RequireAuth makes every route except login need a session or API token,
+// and limits each request to the data of the token's user. The Service must
+// have been created by service.NewSQLite. It must be called before Handler.
+func (s *Server) RequireAuth() {
+	s.auth = true
+}
+
+// RequireSecret makes every request carry secret in SecretHeader. The
+// desktop app generates one per launch and hands it only to its webview. It
+// must be called before Handler.
+func (s *Server) RequireSecret(secret string) {
+	s.secret = secret
+}
+
+// AllowOrigins limits the browser origins that may call the API to the
+// given ones, e.g. "http://localhost:5173". An entry of just a scheme, e.g.
+// "wails://", allows every origin with that scheme. Requests from other
+// origins are refused; requests without an Origin header, i.e. not from a
+// browser, are let through. By default every origin is allowed. It must be
+// called before Handler.
+func (s *Server) AllowOrigins(origins ...string) {
+	s.origins = origins
+}
 
-func StartServer(port string) {
+// Handler returns the API routes wrapped in the CORS middleware and, when
+// required, the secret and auth middlewares.
+func (s *Server) Handler() http.Handler {
 	mux := http.NewServeMux()
+	public := map[string]bool{}
+	for _, rt := range s.routes() {
+		handler := rt.handler
+		if rt.undoable {
+			handler = recorded(rt.summary, handler)
+		}
+		mux.HandleFunc(rt.method+" "+rt.path, handler)
+		if rt.public {
+			public[rt.path] = true
+		}
+	}
+
+	// Apply auth, the shared secret and CORS
+	var h http.Handler = mux
+	if s.auth {
+		h = s.authMiddleware(h, public)
+	}
+	if s.secret != "" {
+		h = s.secretMiddleware(h)
+	}
+	return logMiddleware(s.corsMiddleware(h))
+}
 
-	// Register handlers
-	// Note: We need to wrap handler registration with middleware or just wrap the whole mux
-	mux.HandleFunc("GET /api/todos", GetTodosHandler)
-	mux.HandleFunc("POST /api/todos", CreateTodoHandler)
-	mux.HandleFunc("PUT /api/todos/{id}", UpdateTodoHandler)
-	mux.HandleFunc("DELETE /api/todos/{id}", DeleteTodoHandler)
-
-	// Projects
-	mux.HandleFunc("GET /api/projects", GetProjectsHandler)
-	mux.HandleFunc("POST /api/projects", CreateProjectHandler)
-	mux.HandleFunc("PUT /api/projects/{id}", UpdateProjectHandler)
-	mux.HandleFunc("DELETE /api/projects/{id}", DeleteProjectHandler)
-
-	// Subtasks
-	mux.HandleFunc("POST /api/todos/{id}/subtasks", CreateSubtaskHandler)
-	mux.HandleFunc("PUT /api/subtasks/{id}", UpdateSubtaskHandler)
-	mux.HandleFunc("DELETE /api/subtasks/{id}", DeleteSubtaskHandler)
-
-	// Apply CORS
-	handler := corsMiddleware(mux)
-
-	srv = &http.Server{
-		Addr:    ":" + port,
-		Handler: handler,
+// Listen binds addr and serves the API in the background until Stop is
+// called. Given a certificate and key file it serves HTTPS. Errors binding
+// the address or loading the certificate are returned before serving starts.
+// With port 0 a free port is picked; Addr tells which.
+func (s *Server) Listen(addr, certFile, keyFile string) error {
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: s.Handler(),
 	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return err
+		}
+		srv.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.srv = srv
+	s.addr = ln.Addr()
 
 	go func() {
-		log.Printf("Starting HTTP server on port %s", port)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("listen: %s\n", err)
+		log.Printf("Starting HTTP server on %s", ln.Addr())
+		var err error
+		if srv.TLSConfig != nil {
+			err = srv.ServeTLS(ln, "", "")
+		} else {
+			err = srv.Serve(ln)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Printf("serve: %s", err)
 		}
 	}()
+	return nil
+}
+
+// Addr returns the address Listen bound, or nil before it was called.
+func (s *Server) Addr() net.Addr {
+	return s.addr
 }
 
-func StopServer(ctx context.Context) error {
-	if srv != nil {
-		return srv.Shutdown(ctx)
+// Stop stops accepting connections and waits for in-flight requests to
+// finish, or for ctx to be done.
+func (s *Server) Stop(ctx context.Context) error {
+	if s.srv != nil {
+		return s.srv.Shutdown(ctx)
 	}
 	return nil
 }
 
-func corsMiddleware(next http.Handler) http.Handler {
+// statusRecorder remembers the status code written through it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// logMiddleware logs each request at debug level.
+func logMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		if !slog.Default().Enabled(r.Context(), slog.LevelDebug) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		slog.Debug("request", "method", r.Method, "path", r.URL.Path, "status", rec.status, "duration", time.Since(start))
+	})
+}
+
+// corsMiddleware answers preflight requests and lets browsers on an allowed
+// origin read responses. Requests from any other origin are refused.
+func (s *Server) corsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(s.origins) == 0 {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			w.Header().Add("Vary", "Origin")
+			origin := r.Header.Get("Origin")
+			if origin != "" && !s.allowsOrigin(origin) {
+				writeJSONError(w, http.StatusForbidden, apiError{Code: codeForbidden, Message: "origin not allowed"})
+				return
+			}
+			if origin != "" {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+		}
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, "+SecretHeader)
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -66,3 +189,50 @@ func corsMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+func (s *Server) allowsOrigin(origin string) bool {
+	if slices.Contains(s.origins, origin) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Scheme != "" && slices.Contains(s.origins, u.Scheme+"://")
+}
+
+// secretMiddleware lets through only requests carrying the shared secret.
+func (s *Server) secretMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get(SecretHeader)), []byte(s.secret)) != 1 {
+			writeJSONError(w, http.StatusUnauthorized, apiError{Code: codeUnauthorized, Message: "missing or wrong " + SecretHeader + " header"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authMiddleware lets through requests with a valid bearer token and hands
+// them a Service limited to the token's user. The public paths, such as
+// logging in, need no token.
+func (s *Server) authMiddleware(next http.Handler, public map[string]bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if public[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		secret, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="todo"`)
+			writeJSONError(w, http.StatusUnauthorized, apiError{Code: codeUnauthorized, Message: "authentication required"})
+			return
+		}
+		user, token, err := s.svc.Authenticate(strings.TrimSpace(secret))
+		if errors.Is(err, service.ErrInvalidToken) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="todo", error="invalid_token"`)
+		}
+		if err != nil {
+			writeError(w, err, "token")
+			return
+		}
+		sess := &session{user: user, token: token, svc: s.svc.ForUser(user.ID)}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), sessionKey{}, sess)))
+	})
+}
@@ -2,71 +2,36 @@ package server
 
 import (
 	"bytes"
-	"database/sql"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 	"todo/backend/db"
-
-	_ "modernc.org/sqlite"
+	"todo/backend/service"
 )
 
-func setupTestDB(t *testing.T) {
-	var err error
-	db.DB, err = sql.Open("sqlite", ":memory:")
+func setupTestServer(t *testing.T) *Server {
+	conn, err := db.Open(":memory:")
 	if err != nil {
 		t.Fatalf("Failed to open in-memory database: %v", err)
 	}
-
-	createTableSQL := `CREATE TABLE IF NOT EXISTS todos (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		title TEXT NOT NULL,
-		description TEXT DEFAULT '',
-		completed BOOLEAN DEFAULT FALSE,
-		priority TEXT DEFAULT 'medium',
-		due_date DATETIME,
-		remind_at DATETIME,
-		repeat TEXT DEFAULT '',
-		tags TEXT DEFAULT '[]',
-		project_id INTEGER,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);`
-	if _, err := db.DB.Exec(createTableSQL); err != nil {
-		t.Fatalf("Failed to create todos table: %v", err)
-	}
-
-	createProjectsTableSQL := `CREATE TABLE IF NOT EXISTS projects (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT NOT NULL,
-		description TEXT DEFAULT '',
-		color TEXT DEFAULT '#64748B',
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);`
-	if _, err := db.DB.Exec(createProjectsTableSQL); err != nil {
-		t.Fatalf("Failed to create projects table: %v", err)
-	}
-
-	createSubtasksTableSQL := `CREATE TABLE IF NOT EXISTS subtasks (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		todo_id INTEGER NOT NULL,
-		title TEXT NOT NULL,
-		completed BOOLEAN DEFAULT FALSE,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY(todo_id) REFERENCES todos(id) ON DELETE CASCADE
-	);`
-	if _, err := db.DB.Exec(createSubtasksTableSQL); err != nil {
-		t.Fatalf("Failed to create subtasks table: %v", err)
+	t.Cleanup(func() { conn.Close() })
+	if _, err := db.MigrateUp(conn, 0); err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
 	}
+	return New(service.NewSQLite(conn))
 }
 
 func TestGetTodosHandler(t *testing.T) {
-	setupTestDB(t)
-	defer db.DB.Close()
+	t.Parallel()
+	s := setupTestServer(t)
 
 	req, _ := http.NewRequest("GET", "/api/todos", nil)
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(GetTodosHandler)
+	handler := http.HandlerFunc(s.GetTodosHandler)
 
 	handler.ServeHTTP(rr, req)
 
@@ -84,8 +49,8 @@ func TestGetTodosHandler(t *testing.T) {
 }
 
 func TestCreateTodoHandler(t *testing.T) {
-	setupTestDB(t)
-	defer db.DB.Close()
+	t.Parallel()
+	s := setupTestServer(t)
 
 	todo := map[string]interface{}{
 		"title":    "Test Todo",
@@ -94,7 +59,7 @@ func TestCreateTodoHandler(t *testing.T) {
 	body, _ := json.Marshal(todo)
 	req, _ := http.NewRequest("POST", "/api/todos", bytes.NewBuffer(body))
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(CreateTodoHandler)
+	handler := http.HandlerFunc(s.CreateTodoHandler)
 
 	handler.ServeHTTP(rr, req)
 
@@ -110,9 +75,72 @@ func TestCreateTodoHandler(t *testing.T) {
 	}
 }
 
+func TestCreateTodoHandlerRepeat(t *testing.T) {
+	t.Parallel()
+	s := setupTestServer(t)
+
+	for repeat, want := range map[string]int{
+		"weekdays": http.StatusOK,
+		"FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1": http.StatusOK,
+		"RRULE:FREQ=WEEKLY;BYDAY=2TU,XX":                http.StatusUnprocessableEntity,
+		"every now and then":                            http.StatusUnprocessableEntity,
+	} {
+		body, _ := json.Marshal(map[string]string{"title": "Repeat", "repeat": repeat})
+		req, _ := http.NewRequest("POST", "/api/todos", bytes.NewBuffer(body))
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(s.CreateTodoHandler).ServeHTTP(rr, req)
+		if rr.Code != want {
+			t.Errorf("repeat %q: status %d, want %d (%s)", repeat, rr.Code, want, rr.Body.String())
+		}
+	}
+
+	for anchor, want := range map[string]int{"completion": http.StatusOK, "someday": http.StatusUnprocessableEntity} {
+		body, _ := json.Marshal(map[string]string{"title": "Water plants", "repeat": "FREQ=DAILY;INTERVAL=3", "repeat_anchor": anchor})
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(s.CreateTodoHandler).ServeHTTP(rr, httptest.NewRequest("POST", "/api/todos", bytes.NewBuffer(body)))
+		if rr.Code != want {
+			t.Errorf("anchor %q: status %d, want %d", anchor, rr.Code, want)
+		}
+	}
+}
+
+func TestCreateTodoHandlerTimeZone(t *testing.T) {
+	t.Parallel()
+	s := setupTestServer(t)
+	h := s.Handler()
+
+	body := `{"title": "Dentist", "due_date": "2026-11-03", "all_day": true, "timezone": "Asia/Tokyo", "remind_at": "2026-11-02T09:00:00+09:00"}`
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("POST", "/api/todos", bytes.NewBufferString(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("CreateTodoHandler returned %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/api/todos", nil))
+	var todos []map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &todos)
+	if len(todos) != 1 {
+		t.Fatalf("Expected 1 todo, got %d", len(todos))
+	}
+	got := todos[0]
+	if got["due_date"] != "2026-11-02T15:00:00Z" || got["due_local"] != "2026-11-03" {
+		t.Errorf("Unexpected due date: %v / %v", got["due_date"], got["due_local"])
+	}
+	if got["remind_at"] != "2026-11-02T00:00:00Z" || got["remind_local"] != "2026-11-02T09:00:00+09:00" {
+		t.Errorf("Unexpected reminder: %v / %v", got["remind_at"], got["remind_local"])
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("POST", "/api/todos", bytes.NewBufferString(`{"title": "x", "timezone": "Nowhere/Land"}`)))
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for unknown timezone, got %d", rr.Code)
+	}
+}
+
 func TestProjectHandlers(t *testing.T) {
-	setupTestDB(t)
-	defer db.DB.Close()
+	t.Parallel()
+	s := setupTestServer(t)
 
 	// Create
 	project := map[string]string{
@@ -122,7 +150,7 @@ func TestProjectHandlers(t *testing.T) {
 	body, _ := json.Marshal(project)
 	req, _ := http.NewRequest("POST", "/api/projects", bytes.NewBuffer(body))
 	rr := httptest.NewRecorder()
-	http.HandlerFunc(CreateProjectHandler).ServeHTTP(rr, req)
+	http.HandlerFunc(s.CreateProjectHandler).ServeHTTP(rr, req)
 
 	if rr.Code != http.StatusOK {
 		t.Errorf("CreateProjectHandler returned wrong status: %v", rr.Code)
@@ -131,12 +159,12 @@ func TestProjectHandlers(t *testing.T) {
 	// Get
 	req, _ = http.NewRequest("GET", "/api/projects", nil)
 	rr = httptest.NewRecorder()
-	http.HandlerFunc(GetProjectsHandler).ServeHTTP(rr, req)
+	http.HandlerFunc(s.GetProjectsHandler).ServeHTTP(rr, req)
 
 	if rr.Code != http.StatusOK {
 		t.Errorf("GetProjectsHandler returned wrong status: %v", rr.Code)
 	}
-	
+
 	var projects []map[string]interface{}
 	json.Unmarshal(rr.Body.Bytes(), &projects)
 	if len(projects) != 1 {
@@ -145,16 +173,16 @@ func TestProjectHandlers(t *testing.T) {
 }
 
 func TestSubtaskHandlers(t *testing.T) {
-	setupTestDB(t)
-	defer db.DB.Close()
+	t.Parallel()
+	s := setupTestServer(t)
 
 	// Need a todo first
 	todo := map[string]interface{}{"title": "Main Task"}
 	body, _ := json.Marshal(todo)
 	req, _ := http.NewRequest("POST", "/api/todos", bytes.NewBuffer(body))
 	rr := httptest.NewRecorder()
-	http.HandlerFunc(CreateTodoHandler).ServeHTTP(rr, req)
-	
+	http.HandlerFunc(s.CreateTodoHandler).ServeHTTP(rr, req)
+
 	var todoResp map[string]int
 	json.Unmarshal(rr.Body.Bytes(), &todoResp)
 	todoID := todoResp["id"]
@@ -165,11 +193,11 @@ func TestSubtaskHandlers(t *testing.T) {
 	// PathValue not supported in httptest nicely for default mux without routing
 	// But our handler uses r.PathValue("id").
 	// We need to set it manually or use the mux.
-	
+
 	mux := http.NewServeMux()
-	mux.HandleFunc("POST /api/todos/{id}/subtasks", CreateSubtaskHandler)
-	mux.HandleFunc("PUT /api/subtasks/{id}", UpdateSubtaskHandler)
-	mux.HandleFunc("DELETE /api/subtasks/{id}", DeleteSubtaskHandler)
+	mux.HandleFunc("POST /api/todos/{id}/subtasks", s.CreateSubtaskHandler)
+	mux.HandleFunc("PUT /api/subtasks/{id}", s.UpdateSubtaskHandler)
+	mux.HandleFunc("DELETE /api/subtasks/{id}", s.DeleteSubtaskHandler)
 
 	url := "/api/todos/" + string(rune(todoID+'0')) + "/subtasks" // hacky int to string for small int
 	// Better:
@@ -184,7 +212,7 @@ func TestSubtaskHandlers(t *testing.T) {
 	if rr.Code != http.StatusOK {
 		t.Errorf("CreateSubtaskHandler returned wrong status: %v", rr.Code)
 	}
-	
+
 	var subResp map[string]int
 	json.Unmarshal(rr.Body.Bytes(), &subResp)
 	subID := subResp["id"]
@@ -213,21 +241,196 @@ func TestSubtaskHandlers(t *testing.T) {
 	}
 }
 
+func TestSeriesHandlers(t *testing.T) {
+	t.Parallel()
+	s := setupTestServer(t)
+	h := s.Handler()
+
+	body, _ := json.Marshal(map[string]interface{}{"title": "Stretch", "repeat": "daily", "due_date": "2026-03-02T07:00:00Z"})
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("POST", "/api/todos", bytes.NewBuffer(body)))
+	var created map[string]int
+	json.Unmarshal(rr.Body.Bytes(), &created)
+
+	body, _ = json.Marshal(map[string]bool{"completed": true})
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("PUT", fmt.Sprintf("/api/todos/%d", created["id"]), bytes.NewBuffer(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Complete returned %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/api/series/1", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GetSeriesHandler returned %d: %s", rr.Code, rr.Body.String())
+	}
+	var history service.SeriesHistory
+	json.Unmarshal(rr.Body.Bytes(), &history)
+	if history.Series.Repeat != "daily" || len(history.Occurrences) != 2 {
+		t.Errorf("Unexpected series history: %+v", history)
+	}
+
+	body, _ = json.Marshal(map[string]bool{"copy_subtasks": true})
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("PUT", "/api/series/1", bytes.NewBuffer(body)))
+	if rr.Code != http.StatusOK {
+		t.Errorf("UpdateSeriesHandler returned %d", rr.Code)
+	}
+
+	body, _ = json.Marshal(map[string]string{"title": "Stretch", "repeat": "daily"})
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("PUT", fmt.Sprintf("/api/todos/%d?scope=everything", created["id"]), bytes.NewBuffer(body)))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for unknown scope, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/api/series/99", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown series, got %d", rr.Code)
+	}
+}
+
+func TestReminderHandlers(t *testing.T) {
+	t.Parallel()
+	s := setupTestServer(t)
+	h := s.Handler()
+
+	body, _ := json.Marshal(map[string]interface{}{"title": "Flight", "due_date": "2026-12-01T10:00:00Z"})
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("POST", "/api/todos", bytes.NewBuffer(body)))
+	var created map[string]int
+	json.Unmarshal(rr.Body.Bytes(), &created)
+
+	for _, tc := range []struct {
+		path string
+		body string
+		want int
+	}{
+		{fmt.Sprintf("/api/todos/%d/reminders", created["id"]), `{"before_due_minutes": 1440}`, http.StatusOK},
+		{fmt.Sprintf("/api/todos/%d/reminders", created["id"]), `{"remind_at": "2026-11-30T18:00:00Z"}`, http.StatusOK},
+		{fmt.Sprintf("/api/todos/%d/reminders", created["id"]), `{}`, http.StatusUnprocessableEntity},
+		{"/api/todos/99/reminders", `{"before_due_minutes": 10}`, http.StatusNotFound},
+	} {
+		rr = httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest("POST", tc.path, bytes.NewBufferString(tc.body)))
+		if rr.Code != tc.want {
+			t.Errorf("POST %s %s returned %d, want %d: %s", tc.path, tc.body, rr.Code, tc.want, rr.Body.String())
+		}
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", fmt.Sprintf("/api/todos/%d/reminders", created["id"]), nil))
+	var reminders []db.Reminder
+	json.Unmarshal(rr.Body.Bytes(), &reminders)
+	if len(reminders) != 2 || reminders[0].FireAt == nil || reminders[0].FireAt.Format("2006-01-02T15:04") != "2026-11-30T10:00" {
+		t.Fatalf("Unexpected reminders: %s", rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("DELETE", fmt.Sprintf("/api/reminders/%d", reminders[0].ID), nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("DeleteReminderHandler returned %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/api/notifications", nil))
+	if rr.Code != http.StatusOK || rr.Body.String() != "[]\n" {
+		t.Errorf("Expected no active notifications, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("POST", "/api/notifications/1/snooze", bytes.NewBufferString(`{"for": "1h"}`)))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 snoozing an unknown notification, got %d", rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("POST", "/api/notifications/1/dismiss", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 dismissing an unknown notification, got %d", rr.Code)
+	}
+}
+
+func TestAgendaHandler(t *testing.T) {
+	t.Parallel()
+	s := setupTestServer(t)
+	h := s.Handler()
+
+	body, _ := json.Marshal(map[string]interface{}{"title": "Pay rent", "due_date": "2026-04-01T09:00:00Z"})
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("POST", "/api/todos", bytes.NewBuffer(body)))
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/api/agenda?date=2026-04-01", nil))
+	var agenda service.Agenda
+	json.Unmarshal(rr.Body.Bytes(), &agenda)
+	if rr.Code != http.StatusOK || agenda.DueToday != 1 || agenda.Projects[0].DueToday[0].Title != "Pay rent" {
+		t.Fatalf("Unexpected agenda %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/api/agenda?date=2026-04-01&tz=America/New_York&format=markdown", nil))
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/markdown") || !strings.Contains(rr.Body.String(), "- Pay rent (05:00)") {
+		t.Errorf("Unexpected Markdown agenda (%s): %s", ct, rr.Body.String())
+	}
+
+	for _, query := range []string{"date=someday", "tz=Nowhere", "format=pdf"} {
+		rr = httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest("GET", "/api/agenda?"+query, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", query, rr.Code)
+		}
+	}
+}
+
+func TestStatsHandler(t *testing.T) {
+	t.Parallel()
+	s := setupTestServer(t)
+	h := s.Handler()
+
+	body, _ := json.Marshal(map[string]interface{}{"title": "Pay rent", "tags": []string{"home"}, "priority": "high"})
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("POST", "/api/todos", bytes.NewBuffer(body)))
+	var created idResponse
+	json.Unmarshal(rr.Body.Bytes(), &created)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("PUT", fmt.Sprintf("/api/todos/%d", created.ID), strings.NewReader(`{"completed": true}`)))
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/api/stats", nil))
+	var stats service.Stats
+	json.Unmarshal(rr.Body.Bytes(), &stats)
+	if rr.Code != http.StatusOK || stats.Completed != 1 || len(stats.Days) != 30 || stats.Days[29].Completed != 1 {
+		t.Fatalf("Unexpected stats %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(stats.Tags) != 1 || stats.Tags[0].Name != "home" || stats.Priorities[0].Name != "high" || stats.Streak.Current != 1 {
+		t.Errorf("Unexpected breakdowns: %s", rr.Body.String())
+	}
+
+	for _, query := range []string{"from=someday", "tz=Nowhere", "from=2020-01-01&to=2026-01-01"} {
+		rr = httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest("GET", "/api/stats?"+query, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", query, rr.Code)
+		}
+	}
+}
+
 func TestUpdateDeleteTodoHandler(t *testing.T) {
-	setupTestDB(t)
-	defer db.DB.Close()
+	t.Parallel()
+	s := setupTestServer(t)
 
 	// Create Todo
 	todo := map[string]interface{}{"title": "To Update"}
 	body, _ := json.Marshal(todo)
 	req, _ := http.NewRequest("POST", "/api/todos", bytes.NewBuffer(body))
 	rr := httptest.NewRecorder()
-	http.HandlerFunc(CreateTodoHandler).ServeHTTP(rr, req)
+	http.HandlerFunc(s.CreateTodoHandler).ServeHTTP(rr, req)
 
 	// Mux for path values
 	mux := http.NewServeMux()
-	mux.HandleFunc("PUT /api/todos/{id}", UpdateTodoHandler)
-	mux.HandleFunc("DELETE /api/todos/{id}", DeleteTodoHandler)
+	mux.HandleFunc("PUT /api/todos/{id}", s.UpdateTodoHandler)
+	mux.HandleFunc("DELETE /api/todos/{id}", s.DeleteTodoHandler)
 
 	// Update
 	update := map[string]interface{}{"completed": true}
@@ -249,3 +452,981 @@ func TestUpdateDeleteTodoHandler(t *testing.T) {
 		t.Errorf("DeleteTodoHandler returned wrong status: %v", rr.Code)
 	}
 }
+
+func TestPatchHandlers(t *testing.T) {
+	t.Parallel()
+	s := setupTestServer(t)
+	h := s.Handler()
+
+	projectID, _ := s.svc.CreateProject("Home", "", "")
+	pid := int(projectID)
+	due := time.Date(2026, 11, 1, 9, 0, 0, 0, time.UTC)
+	id, _ := s.svc.CreateTodo("Pay rent", "Before the 1st", "high", service.Schedule{DueDate: &due}, service.Repeat{}, []string{"bills"}, &pid)
+	subtaskID, _ := s.svc.CreateSubtask(int(id), "Log in to the bank")
+
+	patch := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PATCH", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+	get := func() db.Todo {
+		todos, _ := s.svc.GetTodos(service.TodoListOptions{})
+		return todos[0]
+	}
+
+	// Only the fields sent change
+	if rr := patch(fmt.Sprintf("/api/todos/%d", id), `{"priority": "low"}`); rr.Code != http.StatusOK {
+		t.Fatalf("PATCH returned %d: %s", rr.Code, rr.Body.String())
+	}
+	todo := get()
+	if todo.Priority != "low" || todo.Title != "Pay rent" || todo.Description != "Before the 1st" ||
+		todo.DueDate == nil || todo.ProjectID == nil || len(todo.Tags) != 1 {
+		t.Errorf("Expected only the priority to change, got %+v", todo)
+	}
+
+	// null clears due_date and project_id and resets other fields
+	if rr := patch(fmt.Sprintf("/api/todos/%d", id), `{"due_date": null, "project_id": null, "description": null, "tags": null}`); rr.Code != http.StatusOK {
+		t.Fatalf("PATCH returned %d: %s", rr.Code, rr.Body.String())
+	}
+	todo = get()
+	if todo.DueDate != nil || todo.ProjectID != nil || todo.Description != "" || len(todo.Tags) != 0 || todo.Priority != "low" {
+		t.Errorf("Expected due date, project, description and tags cleared, got %+v", todo)
+	}
+
+	// Completing keeps the details
+	if rr := patch(fmt.Sprintf("/api/todos/%d", id), `{"completed": true}`); rr.Code != http.StatusOK {
+		t.Fatalf("PATCH returned %d: %s", rr.Code, rr.Body.String())
+	}
+	if todo = get(); !todo.Completed || todo.Title != "Pay rent" || todo.Priority != "low" {
+		t.Errorf("Expected only completed to change, got %+v", todo)
+	}
+
+	for _, tc := range []struct {
+		path, body string
+		status     int
+	}{
+		{fmt.Sprintf("/api/todos/%d", id), `{"title": null}`, http.StatusUnprocessableEntity},
+		{fmt.Sprintf("/api/todos/%d", id), `{"priority": "urgent"}`, http.StatusUnprocessableEntity},
+		{fmt.Sprintf("/api/todos/%d", id), `{"project_id": 999}`, http.StatusUnprocessableEntity},
+		{fmt.Sprintf("/api/todos/%d", id), `{"project_id": "home"}`, http.StatusBadRequest},
+		{fmt.Sprintf("/api/todos/%d", id), `[]`, http.StatusBadRequest},
+		{"/api/todos/999", `{"title": "x"}`, http.StatusNotFound},
+		{fmt.Sprintf("/api/subtasks/%d", subtaskID), `{"title": ""}`, http.StatusUnprocessableEntity},
+		{"/api/subtasks/999", `{"completed": true}`, http.StatusNotFound},
+	} {
+		if rr := patch(tc.path, tc.body); rr.Code != tc.status {
+			t.Errorf("PATCH %s %s: got %d, want %d: %s", tc.path, tc.body, rr.Code, tc.status, rr.Body.String())
+		}
+	}
+
+	// Renaming a subtask keeps it checked off
+	patch(fmt.Sprintf("/api/subtasks/%d", subtaskID), `{"completed": true}`)
+	if rr := patch(fmt.Sprintf("/api/subtasks/%d", subtaskID), `{"title": "Log in"}`); rr.Code != http.StatusOK {
+		t.Fatalf("PATCH returned %d: %s", rr.Code, rr.Body.String())
+	}
+	if st, _ := s.svc.GetSubtask(int(subtaskID)); !st.Completed || st.Title != "Log in" {
+		t.Errorf("Expected the renamed subtask to stay checked off, got %+v", st)
+	}
+}
+
+func TestBatchHandler(t *testing.T) {
+	t.Parallel()
+	s := setupTestServer(t)
+	h := s.Handler()
+
+	projectID, _ := s.svc.CreateProject("Home", "", "")
+	a, _ := s.svc.CreateTodo("Water plants", "", "", service.Schedule{}, service.Repeat{}, nil, nil)
+	b, _ := s.svc.CreateTodo("Fix sink", "", "", service.Schedule{}, service.Repeat{}, []string{"diy"}, nil)
+
+	batch := func(body string) (*httptest.ResponseRecorder, batchResponse) {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest("POST", "/api/todos/batch", strings.NewReader(body)))
+		var res batchResponse
+		json.Unmarshal(rr.Body.Bytes(), &res)
+		return rr, res
+	}
+	get := func(id int64) db.Todo {
+		todos, _ := s.svc.GetTodos(service.TodoListOptions{})
+		for _, todo := range todos {
+			if todo.ID == int(id) {
+				return todo
+			}
+		}
+		t.Fatalf("Todo %d not found", id)
+		return db.Todo{}
+	}
+
+	// Without atomic, failed operations are reported and the rest applied
+	rr, res := batch(fmt.Sprintf(`{"operations": [
+		{"op": "move", "id": %d, "project_id": %d},
+		{"op": "move", "id": %d, "project_id": 999},
+		{"op": "remove_tag", "id": %d, "tag": "diy"},
+		{"op": "complete", "id": 999}
+	]}`, a, projectID, b, b))
+	if rr.Code != http.StatusOK || len(res.Results) != 4 {
+		t.Fatalf("Unexpected batch response: %d %s", rr.Code, rr.Body.String())
+	}
+	for i, want := range []struct {
+		ok   bool
+		code string
+	}{{true, ""}, {false, codeValidationFailed}, {true, ""}, {false, codeNotFound}} {
+		got := res.Results[i]
+		if got.OK != want.ok || (got.Error == nil) != want.ok || (got.Error != nil && got.Error.Code != want.code) {
+			t.Errorf("Result %d: got %+v, want ok=%v %s", i, got, want.ok, want.code)
+		}
+	}
+	if todo := get(a); todo.ProjectID == nil || *todo.ProjectID != int(projectID) {
+		t.Errorf("Expected todo %d moved, got %v", a, todo.ProjectID)
+	}
+	if todo := get(b); todo.ProjectID != nil || len(todo.Tags) != 0 {
+		t.Errorf("Expected todo %d untagged and not moved, got %+v", b, todo)
+	}
+
+	// With atomic, one failure rolls everything back
+	rr, _ = batch(fmt.Sprintf(`{"operations": [{"op": "complete", "id": %d}, {"op": "delete", "id": 999}], "atomic": true}`, a))
+	var e apiError
+	json.Unmarshal(rr.Body.Bytes(), &e)
+	if rr.Code != http.StatusNotFound || len(e.Details) != 1 || e.Details[0].Field != "operations[1]" {
+		t.Errorf("Expected 404 naming operations[1], got %d %s", rr.Code, rr.Body.String())
+	}
+	if get(a).Completed {
+		t.Error("Expected the atomic batch to be rolled back")
+	}
+	rr, _ = batch(fmt.Sprintf(`{"operations": [{"op": "shift_due", "id": %d, "days": 1}], "atomic": true}`, a))
+	json.Unmarshal(rr.Body.Bytes(), &e)
+	if rr.Code != http.StatusConflict || len(e.Details) != 1 || e.Details[0].Field != "operations[0]" {
+		t.Errorf("Expected 409 for shifting a todo without due date, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr, _ = batch(fmt.Sprintf(`{"operations": [{"op": "complete", "id": %d}, {"op": "delete", "id": %d}], "atomic": true}`, a, b))
+	if rr.Code != http.StatusOK || !get(a).Completed {
+		t.Errorf("Expected the atomic batch to apply, got %d %s", rr.Code, rr.Body.String())
+	}
+	if todos, _ := s.svc.GetTodos(service.TodoListOptions{}); len(todos) != 1 {
+		t.Errorf("Expected todo %d deleted, got %d todos", b, len(todos))
+	}
+
+	// Malformed operations are rejected before any is run
+	for body, field := range map[string]string{
+		`{"operations": []}`: "operations",
+		fmt.Sprintf(`{"operations": [{"op": "reopen", "id": %d}, {"op": "archive", "id": 1}]}`, a):             "operations[1].op",
+		fmt.Sprintf(`{"operations": [{"op": "reopen", "id": %d}, {"op": "set_priority", "id": 1}]}`, a):        "operations[1].priority",
+		fmt.Sprintf(`{"operations": [{"op": "reopen", "id": %d}, {"op": "add_tag", "id": 1, "tag": " "}]}`, a): "operations[1].tag",
+	} {
+		rr, _ := batch(body)
+		json.Unmarshal(rr.Body.Bytes(), &e)
+		if rr.Code != http.StatusUnprocessableEntity || len(e.Details) == 0 || e.Details[0].Field != field {
+			t.Errorf("%s: expected 422 naming %s, got %d %s", body, field, rr.Code, rr.Body.String())
+		}
+	}
+	if !get(a).Completed {
+		t.Error("Expected a rejected batch to change nothing")
+	}
+}
+
+func TestTrashHandlers(t *testing.T) {
+	t.Parallel()
+	s := setupTestServer(t)
+	h := s.Handler()
+
+	projectID, _ := s.svc.CreateProject("Garden", "", "")
+	pid := int(projectID)
+	todoID, _ := s.svc.CreateTodo("Plant tulips", "", "", service.Schedule{}, service.Repeat{}, nil, &pid)
+	subtaskID, _ := s.svc.CreateSubtask(int(todoID), "Buy bulbs")
+
+	do := func(method, target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(method, target, nil))
+		return rr
+	}
+	count := func(target string) int {
+		var items []json.RawMessage
+		json.Unmarshal(do("GET", target).Body.Bytes(), &items)
+		return len(items)
+	}
+
+	if rr := do("DELETE", fmt.Sprintf("/api/projects/%d", projectID)); rr.Code != http.StatusOK {
+		t.Fatalf("DeleteProjectHandler returned %d", rr.Code)
+	}
+	for _, target := range []string{"/api/todos", "/api/projects", "/api/search?q=tulips", "/api/search?q=bulbs"} {
+		if n := count(target); n != 0 {
+			t.Errorf("GET %s: expected trashed rows hidden, got %d", target, n)
+		}
+	}
+	if rr := do("GET", fmt.Sprintf("/api/todos/%d/subtasks", todoID)); rr.Body.String() != "[]\n" {
+		t.Errorf("Expected no subtasks of a trashed todo, got %s", rr.Body.String())
+	}
+
+	rr := do("GET", "/api/trash")
+	var trash db.Trash
+	json.Unmarshal(rr.Body.Bytes(), &trash)
+	if rr.Code != http.StatusOK || len(trash.Projects) != 1 || trash.Projects[0].DeletedAt == nil || len(trash.Todos) != 0 || len(trash.Subtasks) != 0 {
+		t.Errorf("Expected only the project in the trash, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	if rr := do("POST", fmt.Sprintf("/api/trash/projects/%d/restore", projectID)); rr.Code != http.StatusOK {
+		t.Fatalf("RestoreProjectHandler returned %d: %s", rr.Code, rr.Body.String())
+	}
+	if n := count("/api/todos"); n != 1 {
+		t.Errorf("Expected the todo restored with its project, got %d todos", n)
+	}
+	if n := count("/api/search?q=bulbs"); n != 1 {
+		t.Errorf("Expected the subtask searchable again, got %d hits", n)
+	}
+
+	do("DELETE", fmt.Sprintf("/api/subtasks/%d", subtaskID))
+	json.Unmarshal(do("GET", "/api/trash").Body.Bytes(), &trash)
+	if len(trash.Subtasks) != 1 || trash.Subtasks[0].ID != int(subtaskID) {
+		t.Errorf("Expected the subtask in the trash, got %+v", trash.Subtasks)
+	}
+	if rr := do("POST", fmt.Sprintf("/api/trash/subtasks/%d/restore", subtaskID)); rr.Code != http.StatusOK {
+		t.Errorf("RestoreSubtaskHandler returned %d", rr.Code)
+	}
+
+	// Only what is in the trash can be restored
+	for _, target := range []string{
+		fmt.Sprintf("/api/trash/todos/%d/restore", todoID),
+		fmt.Sprintf("/api/trash/subtasks/%d/restore", subtaskID),
+		"/api/trash/projects/999/restore",
+	} {
+		if rr := do("POST", target); rr.Code != http.StatusNotFound {
+			t.Errorf("POST %s: expected 404, got %d", target, rr.Code)
+		}
+	}
+}
+
+func TestUndoHandlers(t *testing.T) {
+	t.Parallel()
+	s := setupTestServer(t)
+	h := s.Handler()
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rr
+	}
+	get := func(id int64) *db.Todo {
+		todos, _ := s.svc.GetTodos(service.TodoListOptions{})
+		for _, todo := range todos {
+			if todo.ID == int(id) {
+				return &todo
+			}
+		}
+		return nil
+	}
+
+	if rr := do("POST", "/api/undo", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 with nothing to undo, got %d", rr.Code)
+	}
+
+	var created idResponse
+	json.Unmarshal(do("POST", "/api/todos", `{"title": "Water plants", "repeat": "daily", "due_date": "2026-10-20T09:00:00Z"}`).Body.Bytes(), &created)
+
+	// Editing and completing in one PUT is one command, spawn included
+	target := fmt.Sprintf("/api/todos/%d", created.ID)
+	if rr := do("PUT", target, `{"title": "Water the plants", "repeat": "daily", "due_date": "2026-10-20T09:00:00Z", "completed": true}`); rr.Code != http.StatusOK {
+		t.Fatalf("PUT returned %d: %s", rr.Code, rr.Body.String())
+	}
+	if todos, _ := s.svc.GetTodos(service.TodoListOptions{}); len(todos) != 2 {
+		t.Fatalf("Expected the next occurrence, got %d todos", len(todos))
+	}
+	rr := do("POST", "/api/undo", "")
+	var c db.Command
+	json.Unmarshal(rr.Body.Bytes(), &c)
+	if rr.Code != http.StatusOK || c.Name != "Update a todo's details or status" || c.Status != db.CommandUndone {
+		t.Fatalf("Unexpected undo response: %d %s", rr.Code, rr.Body.String())
+	}
+	if todos, _ := s.svc.GetTodos(service.TodoListOptions{}); len(todos) != 1 || todos[0].Title != "Water plants" || todos[0].Completed {
+		t.Errorf("Expected the todo as it was created, got %+v", todos)
+	}
+
+	if rr := do("POST", "/api/redo", ""); rr.Code != http.StatusOK {
+		t.Fatalf("Redo returned %d: %s", rr.Code, rr.Body.String())
+	}
+	if todo := get(created.ID); todo == nil || !todo.Completed || todo.Title != "Water the plants" {
+		t.Errorf("Expected the edit redone, got %+v", todo)
+	}
+
+	// Reads and changes made outside the API aren't commands
+	do("GET", "/api/todos", "")
+	low := "low"
+	if err := s.svc.PatchTodo(int(created.ID), "", service.TodoPatch{Priority: &low}); err != nil {
+		t.Fatalf("PatchTodo failed: %v", err)
+	}
+	if rr := do("POST", "/api/undo", ""); rr.Code != http.StatusConflict {
+		t.Errorf("Expected 409 undoing a todo changed since, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if todo := get(created.ID); todo == nil || !todo.Completed {
+		t.Errorf("Expected the todo left alone, got %+v", todo)
+	}
+}
+
+func TestTodoHistoryHandler(t *testing.T) {
+	t.Parallel()
+	s := setupTestServer(t)
+	h := s.Handler()
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rr
+	}
+
+	var created idResponse
+	json.Unmarshal(do("POST", "/api/todos", `{"title": "Water plants", "priority": "low"}`).Body.Bytes(), &created)
+	target := fmt.Sprintf("/api/todos/%d", created.ID)
+	do("PATCH", target, `{"priority": "high", "completed": true}`)
+
+	rr := do("GET", target+"/history", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GetTodoHistoryHandler returned %d: %s", rr.Code, rr.Body.String())
+	}
+	var events []map[string]any
+	json.Unmarshal(rr.Body.Bytes(), &events)
+	if len(events) != 3 || events[0]["action"] != "created" {
+		t.Fatalf("Expected creation and two changes, got %s", rr.Body.String())
+	}
+	if e := events[1]; e["field"] != "priority" || e["old"] != "low" || e["new"] != "high" {
+		t.Errorf("Expected the priority change, got %v", e)
+	}
+	if e := events[2]; e["field"] != "completed" || e["old"] != false || e["new"] != true {
+		t.Errorf("Expected the completion, got %v", e)
+	}
+
+	var todos []db.Todo
+	json.Unmarshal(do("GET", "/api/todos", "").Body.Bytes(), &todos)
+	if len(todos) != 1 || todos[0].CompletedAt == nil || todos[0].UpdatedAt.IsZero() {
+		t.Errorf("Expected completed_at and updated_at, got %+v", todos)
+	}
+}
+
+func TestSearchHandler(t *testing.T) {
+	t.Parallel()
+	s := setupTestServer(t)
+	handler := s.Handler()
+
+	body, _ := json.Marshal(map[string]interface{}{"title": "Quarterly report", "tags": []string{"finance"}})
+	req, _ := http.NewRequest("POST", "/api/todos", bytes.NewBuffer(body))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	req, _ = http.NewRequest("GET", "/api/search?q=quart", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("SearchHandler returned wrong status: %v", rr.Code)
+	}
+	var results []map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &results)
+	if len(results) != 1 || results[0]["kind"] != "todo" {
+		t.Errorf("Expected one todo hit, got %v", rr.Body.String())
+	}
+
+	req, _ = http.NewRequest("GET", "/api/search?q=x&limit=abc", nil)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for invalid limit, got %v", rr.Code)
+	}
+}
+
+func TestGetTodosHandlerFilter(t *testing.T) {
+	t.Parallel()
+	s := setupTestServer(t)
+	handler := s.Handler()
+
+	for _, todo := range []map[string]interface{}{
+		{"title": "Urgent one", "priority": "high"},
+		{"title": "Someday", "priority": "low"},
+	} {
+		body, _ := json.Marshal(todo)
+		req, _ := http.NewRequest("POST", "/api/todos", bytes.NewBuffer(body))
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	req, _ := http.NewRequest("GET", "/api/todos?filter=priority:high&sort=title&order=asc", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GetTodosHandler returned wrong status: %v", rr.Code)
+	}
+	var todos []map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &todos)
+	if len(todos) != 1 || todos[0]["title"] != "Urgent one" {
+		t.Errorf("Expected only the high priority todo, got %v", rr.Body.String())
+	}
+
+	for _, query := range []string{"filter=priority:urgent", "sort=color", "order=sideways"} {
+		req, _ = http.NewRequest("GET", "/api/todos?"+query, nil)
+		rr = httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %q, got %v", query, rr.Code)
+		}
+	}
+}
+
+func TestGetTodosHandlerPagination(t *testing.T) {
+	t.Parallel()
+	s := setupTestServer(t)
+	handler := s.Handler()
+
+	for i := 0; i < 3; i++ {
+		body, _ := json.Marshal(map[string]interface{}{"title": "Todo"})
+		req, _ := http.NewRequest("POST", "/api/todos", bytes.NewBuffer(body))
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	var page struct {
+		Items      []map[string]interface{} `json:"items"`
+		NextCursor string                   `json:"next_cursor"`
+	}
+	req, _ := http.NewRequest("GET", "/api/todos?limit=2", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	json.Unmarshal(rr.Body.Bytes(), &page)
+	if rr.Code != http.StatusOK || len(page.Items) != 2 || page.NextCursor == "" {
+		t.Fatalf("Unexpected first page: %v %s", rr.Code, rr.Body.String())
+	}
+
+	req, _ = http.NewRequest("GET", "/api/todos?limit=2&cursor="+page.NextCursor, nil)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	page.NextCursor = ""
+	json.Unmarshal(rr.Body.Bytes(), &page)
+	if rr.Code != http.StatusOK || len(page.Items) != 1 || page.NextCursor != "" {
+		t.Errorf("Unexpected last page: %v %s", rr.Code, rr.Body.String())
+	}
+
+	for _, query := range []string{"limit=0", "limit=100000", "cursor=bogus"} {
+		req, _ = http.NewRequest("GET", "/api/todos?"+query, nil)
+		rr = httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %q, got %v", query, rr.Code)
+		}
+	}
+}
+
+func TestAuthHandlers(t *testing.T) {
+	t.Parallel()
+	s := setupTestServer(t)
+	s.RequireAuth()
+	h := s.Handler()
+	s.svc.CreateUser("alice", "correct horse")
+	s.svc.CreateUser("bob", "battery staple")
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+	login := func(username, password string) string {
+		rr := do("POST", "/api/auth/login", "", fmt.Sprintf(`{"username": %q, "password": %q}`, username, password))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Login as %s returned %d: %s", username, rr.Code, rr.Body.String())
+		}
+		var res struct {
+			Token string  `json:"token"`
+			User  db.User `json:"user"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &res)
+		if res.Token == "" || res.User.Username != username || strings.Contains(rr.Body.String(), "password") {
+			t.Fatalf("Unexpected login response: %s", rr.Body.String())
+		}
+		return res.Token
+	}
+
+	if rr := do("GET", "/api/todos", "", ""); rr.Code != http.StatusUnauthorized || rr.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("Expected 401 with a challenge without a token, got %d", rr.Code)
+	}
+	if rr := do("GET", "/api/todos", "todo_forged", ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for an unknown token, got %d", rr.Code)
+	}
+	if rr := do("POST", "/api/auth/login", "", `{"username": "alice", "password": "nope"}`); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for a wrong password, got %d", rr.Code)
+	}
+	if rr := do("OPTIONS", "/api/todos", "", ""); rr.Code != http.StatusOK {
+		t.Errorf("Expected CORS preflight to pass without a token, got %d", rr.Code)
+	}
+
+	alice, bob := login("alice", "correct horse"), login("bob", "battery staple")
+	if rr := do("GET", "/api/auth/me", alice, ""); !strings.Contains(rr.Body.String(), `"username":"alice"`) {
+		t.Errorf("Unexpected /api/auth/me response: %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr := do("POST", "/api/todos", alice, `{"title": "Alice's todo"}`)
+	var created map[string]int
+	json.Unmarshal(rr.Body.Bytes(), &created)
+	if rr := do("GET", "/api/todos", bob, ""); rr.Body.String() != "[]\n" {
+		t.Errorf("Expected bob to see no todos, got %s", rr.Body.String())
+	}
+	if rr := do("PUT", fmt.Sprintf("/api/todos/%d", created["id"]), bob, `{"title": "Bob's now"}`); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 when bob edits alice's todo, got %d", rr.Code)
+	}
+	if rr := do("POST", fmt.Sprintf("/api/todos/%d/subtasks", created["id"]), bob, `{"title": "x"}`); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 when bob adds a subtask to alice's todo, got %d", rr.Code)
+	}
+
+	// API tokens: created once with their secret, listed without it
+	rr = do("POST", "/api/tokens", alice, `{"name": "cron"}`)
+	var token struct {
+		ID    int    `json:"id"`
+		Name  string `json:"name"`
+		Token string `json:"token"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &token)
+	if rr.Code != http.StatusOK || token.Name != "cron" || token.Token == "" {
+		t.Fatalf("Unexpected token response: %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := do("GET", "/api/todos", token.Token, ""); !strings.Contains(rr.Body.String(), "Alice's todo") {
+		t.Errorf("Expected the API token to act as alice, got %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := do("GET", "/api/tokens", alice, ""); strings.Contains(rr.Body.String(), token.Token) || !strings.Contains(rr.Body.String(), "cron") {
+		t.Errorf("Expected the token listed without its secret, got %s", rr.Body.String())
+	}
+	if rr := do("DELETE", fmt.Sprintf("/api/tokens/%d", token.ID), bob, ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 when bob revokes alice's token, got %d", rr.Code)
+	}
+	if rr := do("DELETE", fmt.Sprintf("/api/tokens/%d", token.ID), alice, ""); rr.Code != http.StatusOK {
+		t.Errorf("Expected alice to revoke her token, got %d", rr.Code)
+	}
+	if rr := do("GET", "/api/todos", token.Token, ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected the revoked token to be rejected, got %d", rr.Code)
+	}
+
+	if rr := do("POST", "/api/auth/logout", alice, ""); rr.Code != http.StatusOK {
+		t.Errorf("Logout returned %d", rr.Code)
+	}
+	if rr := do("GET", "/api/todos", alice, ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected the session to end with logout, got %d", rr.Code)
+	}
+}
+
+func TestSecretAndOrigins(t *testing.T) {
+	t.Parallel()
+	s := setupTestServer(t)
+	s.RequireSecret("s3cret")
+	s.AllowOrigins("wails://", "http://localhost:5173")
+	h := s.Handler()
+
+	do := func(method, origin, secret string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/todos", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if secret != "" {
+			req.Header.Set(SecretHeader, secret)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := do("GET", "", ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without the secret, got %d", rr.Code)
+	}
+	if rr := do("GET", "", "wrong"); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for a wrong secret, got %d", rr.Code)
+	}
+	if rr := do("GET", "", "s3cret"); rr.Code != http.StatusOK {
+		t.Errorf("Expected 200 with the secret and no origin, got %d", rr.Code)
+	}
+
+	for _, origin := range []string{"wails://wails", "wails://wails.localhost:34115", "http://localhost:5173"} {
+		rr := do("GET", origin, "s3cret")
+		if rr.Code != http.StatusOK || rr.Header().Get("Access-Control-Allow-Origin") != origin {
+			t.Errorf("Expected %s to be allowed, got %d %q", origin, rr.Code, rr.Header().Get("Access-Control-Allow-Origin"))
+		}
+		if rr := do("OPTIONS", origin, ""); rr.Code != http.StatusOK || !strings.Contains(rr.Header().Get("Access-Control-Allow-Headers"), SecretHeader) {
+			t.Errorf("Expected the preflight from %s to pass without the secret, got %d", origin, rr.Code)
+		}
+	}
+	for _, origin := range []string{"https://evil.example", "http://localhost:8080", "null"} {
+		for _, method := range []string{"GET", "OPTIONS"} {
+			rr := do(method, origin, "s3cret")
+			if rr.Code != http.StatusForbidden || rr.Header().Get("Access-Control-Allow-Origin") != "" {
+				t.Errorf("Expected %s from %s to be refused, got %d", method, origin, rr.Code)
+			}
+		}
+	}
+}
+
+func TestErrorResponses(t *testing.T) {
+	t.Parallel()
+	conn, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open in-memory database: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if _, err := db.MigrateUp(conn, 0); err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+	s := New(service.NewSQLite(conn))
+	h := s.Handler()
+
+	todoID, _ := s.svc.CreateTodo("Call mom", "", "", service.Schedule{}, service.Repeat{}, nil, nil)
+	remindedID, _ := s.svc.CreateTodo("Pay rent", "", "", service.Schedule{}, service.Repeat{}, nil, nil)
+	past := time.Now().Add(-time.Minute)
+	s.svc.AddReminder(int(remindedID), &past, nil)
+	queue := db.NewNotificationStore(conn, 0)
+	queue.Enqueue(time.Now())
+	if pending, _ := queue.ListPending(time.Now(), 1); len(pending) == 1 {
+		queue.Claim(pending[0].ID, time.Now(), time.Now().Add(time.Minute))
+		queue.MarkSent(pending[0].ID, time.Now())
+	}
+	notifications, _ := s.svc.GetActiveNotifications()
+	if len(notifications) != 1 {
+		t.Fatalf("Expected 1 notification, got %d", len(notifications))
+	}
+	s.svc.DismissNotification(notifications[0].ID)
+
+	for _, tc := range []struct {
+		method, path, body string
+		status             int
+		code, field        string
+	}{
+		// Malformed ids, bodies and query parameters
+		{"PUT", "/api/todos/abc", `{"completed": true}`, 400, "bad_request", "id"},
+		{"DELETE", "/api/todos/0", ``, 400, "bad_request", "id"},
+		{"DELETE", "/api/projects/x", ``, 400, "bad_request", "id"},
+		{"PUT", "/api/subtasks/-1", `{}`, 400, "bad_request", "id"},
+		{"GET", "/api/series/1.5", ``, 400, "bad_request", "id"},
+		{"POST", "/api/todos", `{"title": `, 400, "bad_request", ""},
+		{"POST", "/api/todos", `{"title": 7}`, 400, "bad_request", "title"},
+		{"GET", "/api/todos?limit=0", ``, 400, "bad_request", "limit"},
+		{"GET", "/api/todos?filter=due:", ``, 400, "bad_request", "filter"},
+		{"GET", "/api/todos?sort=color", ``, 400, "bad_request", "sort"},
+		{"GET", "/api/search?q=x&limit=many", ``, 400, "bad_request", "limit"},
+		{"GET", "/api/agenda?format=pdf", ``, 400, "bad_request", "format"},
+		{"GET", "/api/agenda?tz=Nowhere/Land", ``, 400, "bad_request", "tz"},
+		{"GET", "/api/agenda?date=someday", ``, 400, "bad_request", "date"},
+		{"PUT", fmt.Sprintf("/api/todos/%d?scope=all", todoID), `{"title": "x"}`, 400, "bad_request", "scope"},
+
+		// Missing resources, including updates and deletes
+		{"PUT", "/api/todos/999", `{"completed": true}`, 404, "not_found", ""},
+		{"PUT", "/api/todos/999", `{"title": "x"}`, 404, "not_found", ""},
+		{"DELETE", "/api/todos/999", ``, 404, "not_found", ""},
+		{"PUT", "/api/projects/999", `{"name": "x"}`, 404, "not_found", ""},
+		{"DELETE", "/api/projects/999", ``, 404, "not_found", ""},
+		{"POST", "/api/todos/999/subtasks", `{"title": "x"}`, 404, "not_found", ""},
+		{"PUT", "/api/subtasks/999", `{"completed": true}`, 404, "not_found", ""},
+		{"DELETE", "/api/subtasks/999", ``, 404, "not_found", ""},
+		{"POST", "/api/todos/999/reminders", `{"before_due_minutes": 5}`, 404, "not_found", ""},
+		{"DELETE", "/api/reminders/999", ``, 404, "not_found", ""},
+		{"GET", "/api/series/999", ``, 404, "not_found", ""},
+		{"POST", "/api/notifications/999/dismiss", ``, 404, "not_found", ""},
+
+		// State conflicts
+		{"POST", fmt.Sprintf("/api/notifications/%d/snooze", notifications[0].ID), `{"for": "10m"}`, 409, "conflict", ""},
+
+		// Invalid field values
+		{"POST", "/api/todos", `{"title": "  "}`, 422, "validation_failed", "title"},
+		{"POST", "/api/todos", `{"title": "x", "priority": "urgent"}`, 422, "validation_failed", "priority"},
+		{"POST", "/api/todos", `{"title": "x", "repeat": "sometimes"}`, 422, "validation_failed", "repeat"},
+		{"POST", "/api/todos", `{"title": "x", "timezone": "Nowhere/Land"}`, 422, "validation_failed", "timezone"},
+		{"POST", "/api/todos", `{"title": "x", "project_id": 999}`, 422, "validation_failed", "project_id"},
+		{"PUT", fmt.Sprintf("/api/todos/%d", todoID), `{}`, 422, "validation_failed", "title"},
+		{"PUT", fmt.Sprintf("/api/todos/%d", todoID), `{"title": ""}`, 422, "validation_failed", "title"},
+		{"PUT", fmt.Sprintf("/api/todos/%d", todoID), `{"title": "x", "priority": "urgent"}`, 422, "validation_failed", "priority"},
+		{"POST", "/api/projects", `{"name": ""}`, 422, "validation_failed", "name"},
+		{"POST", fmt.Sprintf("/api/todos/%d/subtasks", todoID), `{"title": ""}`, 422, "validation_failed", "title"},
+		{"POST", fmt.Sprintf("/api/todos/%d/reminders", todoID), `{}`, 422, "validation_failed", "remind_at"},
+		{"POST", fmt.Sprintf("/api/notifications/%d/snooze", notifications[0].ID), `{"for": "forever"}`, 422, "validation_failed", "for"},
+	} {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body)))
+		var body apiError
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil || rr.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("%s %s: expected a JSON error, got %q (%v)", tc.method, tc.path, rr.Body.String(), err)
+			continue
+		}
+		if rr.Code != tc.status || body.Code != tc.code || body.Message == "" {
+			t.Errorf("%s %s %s: got %d %+v, want %d %s", tc.method, tc.path, tc.body, rr.Code, body, tc.status, tc.code)
+		}
+		if tc.field != "" && (len(body.Details) == 0 || body.Details[0].Field != tc.field) {
+			t.Errorf("%s %s %s: expected details about %s, got %+v", tc.method, tc.path, tc.body, tc.field, body.Details)
+		}
+	}
+
+	// Database errors are logged, not shown
+	conn.Close()
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/api/projects", nil))
+	if rr.Code != http.StatusInternalServerError || rr.Body.String() != `{"code":"internal","message":"internal server error"}`+"\n" {
+		t.Errorf("Expected an opaque 500, got %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	t.Parallel()
+	conn, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open in-memory database: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if _, err := db.MigrateUp(conn, 0); err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+	s := New(service.NewSQLite(conn))
+	s.RequireAuth()
+	s.RequireSecret("s3cret")
+	h := s.Handler()
+
+	// Something of every kind for the routes to return
+	userID, _ := s.svc.CreateUser("alice", "correct horse")
+	session, _, _ := s.svc.Login("alice", "correct horse")
+	svc := s.svc.ForUser(int(userID))
+	projectID, _ := svc.CreateProject("Home", "Chores", "#3B82F6")
+	pid := int(projectID)
+	due := time.Now().Add(time.Hour)
+	todoID, _ := svc.CreateTodo("Pay rent", "Before the 1st", "high", service.Schedule{DueDate: &due}, service.Repeat{Rule: "monthly"}, []string{"bills"}, &pid)
+	subtaskID, _ := svc.CreateSubtask(int(todoID), "Log in to the bank")
+	past := time.Now().Add(-time.Minute)
+	reminderID, _ := svc.AddReminder(int(todoID), &past, nil)
+	queue := db.NewNotificationStore(conn, int(userID))
+	queue.Enqueue(time.Now())
+	if pending, _ := queue.ListPending(time.Now(), 1); len(pending) == 1 {
+		queue.Claim(pending[0].ID, time.Now(), time.Now().Add(time.Minute))
+		queue.MarkSent(pending[0].ID, time.Now())
+	}
+	notifications, _ := svc.GetActiveNotifications()
+	if len(notifications) != 1 {
+		t.Fatalf("Expected 1 notification, got %d", len(notifications))
+	}
+	apiToken, _ := s.svc.CreateAPIToken(int(userID), "cron")
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/openapi.json", nil)
+	req.Header.Set(SecretHeader, "s3cret")
+	h.ServeHTTP(rr, req)
+	var doc map[string]any
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("Unexpected OpenAPI response: %d %s", rr.Code, rr.Body.String())
+	}
+	if doc["openapi"] != "3.1.0" {
+		t.Errorf("Expected OpenAPI 3.1.0, got %v", doc["openapi"])
+	}
+	paths, _ := doc["paths"].(map[string]any)
+
+	// One request per route, deletes and restores last, each checked against the document
+	samples := []struct {
+		route, target, body string
+		status              int
+	}{
+		{"POST /api/auth/login", "/api/auth/login", `{"username": "alice", "password": "correct horse"}`, 200},
+		{"GET /api/auth/me", "/api/auth/me", ``, 200},
+		{"GET /api/tokens", "/api/tokens", ``, 200},
+		{"POST /api/tokens", "/api/tokens", `{"name": "backup"}`, 200},
+		{"GET /api/todos", "/api/todos", ``, 200},
+		{"GET /api/todos", "/api/todos?limit=1", ``, 200},
+		{"GET /api/todos", "/api/todos?filter=due:", ``, 400},
+		{"POST /api/todos", "/api/todos", `{"title": "Water plants", "due_date": "2026-10-20", "all_day": true, "tags": ["home"], "project_id": 1}`, 200},
+		{"POST /api/todos", "/api/todos", `{"title": ""}`, 422},
+		{"PUT /api/todos/{id}", fmt.Sprintf("/api/todos/%d", todoID), `{"title": "Pay the rent", "priority": "medium", "due_date": null}`, 200},
+		{"PUT /api/todos/{id}", "/api/todos/999", `{"completed": true}`, 404},
+		{"PATCH /api/todos/{id}", fmt.Sprintf("/api/todos/%d", todoID), `{"priority": "low", "due_date": "2026-11-01T09:00:00Z", "project_id": null}`, 200},
+		{"PATCH /api/todos/{id}", fmt.Sprintf("/api/todos/%d", todoID), `{"title": null}`, 422},
+		{"POST /api/todos/batch", "/api/todos/batch", fmt.Sprintf(`{"operations": [{"op": "add_tag", "id": %d, "tag": "urgent"}, {"op": "complete", "id": 999}]}`, todoID), 200},
+		{"POST /api/todos/batch", "/api/todos/batch", fmt.Sprintf(`{"operations": [{"op": "shift_due", "id": %d, "days": 1}, {"op": "set_priority", "id": %d, "priority": "urgent"}], "atomic": true}`, todoID, todoID), 422},
+		{"GET /api/todos/{id}/history", fmt.Sprintf("/api/todos/%d/history", todoID), ``, 200},
+		{"GET /api/todos/{id}/history", "/api/todos/999/history", ``, 404},
+		{"GET /api/filters", "/api/filters", ``, 200},
+		{"GET /api/projects", "/api/projects", ``, 200},
+		{"POST /api/projects", "/api/projects", `{"name": "Work"}`, 200},
+		{"PUT /api/projects/{id}", fmt.Sprintf("/api/projects/%d", projectID), `{"name": "House", "color": "#10B981"}`, 200},
+		{"GET /api/todos/{id}/subtasks", fmt.Sprintf("/api/todos/%d/subtasks", todoID), ``, 200},
+		{"POST /api/todos/{id}/subtasks", fmt.Sprintf("/api/todos/%d/subtasks", todoID), `{"title": "Transfer"}`, 200},
+		{"PUT /api/subtasks/{id}", fmt.Sprintf("/api/subtasks/%d", subtaskID), `{"completed": true}`, 200},
+		{"PATCH /api/subtasks/{id}", fmt.Sprintf("/api/subtasks/%d", subtaskID), `{"title": "Transfer rent"}`, 200},
+		{"GET /api/todos/{id}/reminders", fmt.Sprintf("/api/todos/%d/reminders", todoID), ``, 200},
+		{"POST /api/todos/{id}/reminders", fmt.Sprintf("/api/todos/%d/reminders", todoID), `{"before_due_minutes": 30}`, 200},
+		{"GET /api/notifications", "/api/notifications", ``, 200},
+		{"POST /api/notifications/{id}/snooze", fmt.Sprintf("/api/notifications/%d/snooze", notifications[0].ID), `{"for": "10m"}`, 200},
+		{"POST /api/notifications/{id}/dismiss", fmt.Sprintf("/api/notifications/%d/dismiss", notifications[0].ID), ``, 200},
+		{"GET /api/agenda", "/api/agenda?date=tomorrow&tz=Europe/Berlin", ``, 200},
+		{"GET /api/stats", "/api/stats?from=-6d&tz=Europe/Berlin", ``, 200},
+		{"GET /api/stats", "/api/stats?from=tomorrow&to=today", ``, 400},
+		{"GET /api/series/{id}", fmt.Sprintf("/api/series/%d", todoID), ``, 200},
+		{"PUT /api/series/{id}", fmt.Sprintf("/api/series/%d", todoID), `{"copy_subtasks": true}`, 200},
+		{"GET /api/search", "/api/search?q=rent", ``, 200},
+		{"GET /api/openapi.json", "/api/openapi.json", ``, 200},
+		{"DELETE /api/reminders/{id}", fmt.Sprintf("/api/reminders/%d", reminderID), ``, 200},
+		{"DELETE /api/subtasks/{id}", fmt.Sprintf("/api/subtasks/%d", subtaskID), ``, 200},
+		{"GET /api/trash", "/api/trash", ``, 200},
+		{"DELETE /api/todos/{id}", fmt.Sprintf("/api/todos/%d", todoID), ``, 200},
+		{"DELETE /api/projects/{id}", fmt.Sprintf("/api/projects/%d", projectID), ``, 200},
+		{"GET /api/trash", "/api/trash", ``, 200},
+		{"POST /api/trash/subtasks/{id}/restore", fmt.Sprintf("/api/trash/subtasks/%d/restore", subtaskID), ``, 404},
+		{"POST /api/trash/todos/{id}/restore", fmt.Sprintf("/api/trash/todos/%d/restore", todoID), ``, 200},
+		{"POST /api/trash/subtasks/{id}/restore", fmt.Sprintf("/api/trash/subtasks/%d/restore", subtaskID), ``, 200},
+		{"POST /api/trash/projects/{id}/restore", fmt.Sprintf("/api/trash/projects/%d/restore", projectID), ``, 200},
+		{"POST /api/undo", "/api/undo", ``, 200},
+		{"POST /api/redo", "/api/redo", ``, 200},
+		{"POST /api/redo", "/api/redo", ``, 404},
+		{"DELETE /api/tokens/{id}", fmt.Sprintf("/api/tokens/%d", apiToken.ID), ``, 200},
+		{"POST /api/auth/logout", "/api/auth/logout", ``, 200},
+	}
+
+	sampled := map[string]bool{}
+	for _, sample := range samples {
+		sampled[sample.route] = true
+		method, path, _ := strings.Cut(sample.route, " ")
+		op, _ := paths[path].(map[string]any)[strings.ToLower(method)].(map[string]any)
+		if op == nil {
+			t.Errorf("%s: not in the OpenAPI document", sample.route)
+			continue
+		}
+		contentType := "application/json"
+		if method == "PATCH" {
+			contentType = "application/merge-patch+json"
+		}
+		if sample.body != "" {
+			schema := mediaSchema(op["requestBody"], contentType)
+			if schema == nil {
+				t.Errorf("%s: sent a body, but the document has none", sample.route)
+			} else if errs := matchSchema(doc, schema, decodeAny(t, sample.body), "body"); errs != nil {
+				t.Errorf("%s: request %s doesn't match the document: %v", sample.route, sample.body, errs)
+			}
+		}
+
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(method, sample.target, strings.NewReader(sample.body))
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set(SecretHeader, "s3cret")
+		req.Header.Set("Authorization", "Bearer "+session.Secret)
+		h.ServeHTTP(rr, req)
+		if rr.Code != sample.status {
+			t.Errorf("%s %s: got %d, want %d: %s", method, sample.target, rr.Code, sample.status, rr.Body.String())
+			continue
+		}
+
+		responses, _ := op["responses"].(map[string]any)
+		response := responses["default"]
+		if rr.Code == http.StatusOK {
+			response = responses["200"]
+		}
+		schema := mediaSchema(response, "application/json")
+		if schema == nil {
+			if rr.Body.Len() != 0 {
+				t.Errorf("%s %s: documented without a body, got %s", method, sample.target, rr.Body.String())
+			}
+			continue
+		}
+		if errs := matchSchema(doc, schema, decodeAny(t, rr.Body.String()), "response"); errs != nil {
+			t.Errorf("%s %s: response %s doesn't match the document: %v", method, sample.target, rr.Body.String(), errs)
+		}
+	}
+
+	for _, rt := range s.routes() {
+		if !sampled[rt.method+" "+rt.path] {
+			t.Errorf("%s %s: no sample request checks it", rt.method, rt.path)
+		}
+	}
+}
+
+func decodeAny(t *testing.T, s string) any {
+	var v any
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatalf("Invalid JSON %q: %v", s, err)
+	}
+	return v
+}
+
+// mediaSchema returns the schema of a request body or response object for
+// the media type, or nil if there is none.
+func mediaSchema(object any, mediaType string) any {
+	content, _ := object.(map[string]any)["content"].(map[string]any)
+	media, _ := content[mediaType].(map[string]any)
+	return media["schema"]
+}
+
+// matchSchema checks v against the subset of JSON Schema the generated
+// document uses, returning a description of each mismatch.
+func matchSchema(doc map[string]any, schema any, v any, at string) []string {
+	sch, _ := schema.(map[string]any)
+	if ref, ok := sch["$ref"].(string); ok {
+		var target any = doc
+		for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			target = target.(map[string]any)[part]
+		}
+		return matchSchema(doc, target, v, at)
+	}
+	if alts, ok := sch["oneOf"].([]any); ok {
+		matched := 0
+		for _, alt := range alts {
+			if matchSchema(doc, alt, v, at) == nil {
+				matched++
+			}
+		}
+		if matched != 1 {
+			return []string{fmt.Sprintf("%s: matches %d of oneOf", at, matched)}
+		}
+		return nil
+	}
+
+	if typ, ok := sch["type"]; ok {
+		types := []any{typ}
+		if list, ok := typ.([]any); ok {
+			types = list
+		}
+		matched := false
+		for _, typ := range types {
+			matched = matched || jsonType(v, typ.(string))
+		}
+		if !matched {
+			return []string{fmt.Sprintf("%s: %v is not %v", at, v, typ)}
+		}
+	}
+
+	var errs []string
+	switch v := v.(type) {
+	case map[string]any:
+		props, _ := sch["properties"].(map[string]any)
+		for name, value := range v {
+			if prop, ok := props[name]; ok {
+				errs = append(errs, matchSchema(doc, prop, value, at+"."+name)...)
+			} else if extra, ok := sch["additionalProperties"]; ok {
+				if extra == false {
+					errs = append(errs, fmt.Sprintf("%s.%s: not documented", at, name))
+				} else if extra != true {
+					errs = append(errs, matchSchema(doc, extra, value, at+"."+name)...)
+				}
+			}
+		}
+		required, _ := sch["required"].([]any)
+		for _, name := range required {
+			if _, ok := v[name.(string)]; !ok {
+				errs = append(errs, fmt.Sprintf("%s.%s: missing", at, name))
+			}
+		}
+	case []any:
+		for i, item := range v {
+			errs = append(errs, matchSchema(doc, sch["items"], item, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+	}
+	return errs
+}
+
+func jsonType(v any, typ string) bool {
+	switch v := v.(type) {
+	case nil:
+		return typ == "null"
+	case bool:
+		return typ == "boolean"
+	case float64:
+		return typ == "number" || typ == "integer" && v == float64(int64(v))
+	case string:
+		return typ == "string"
+	case []any:
+		return typ == "array"
+	case map[string]any:
+		return typ == "object"
+	}
+	return false
+}
@@ -0,0 +1,25 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"todo/backend/service"
+)
+
+// GetStatsHandler serves the statistics of the days ?from= to ?to= (the 30
+// days up to today by default) in ?tz= (default UTC).
+func (s *Server) GetStatsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	stats, err := s.service(r).GetStats(q.Get("from"), q.Get("to"), q.Get("tz"))
+	switch {
+	case errors.Is(err, service.ErrInvalidTimeZone):
+		badRequest(w, "tz", err.Error())
+	case errors.Is(err, service.ErrInvalidRange):
+		badRequest(w, "", err.Error())
+	case err != nil:
+		writeError(w, err, "statistics")
+	default:
+		json.NewEncoder(w).Encode(stats)
+	}
+}
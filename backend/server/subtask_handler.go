@@ -3,81 +3,106 @@ package server
 import (
 	"encoding/json"
 	"net/http"
-	"strconv"
+	"todo/backend/db"
 	"todo/backend/service"
 )
 
-func CreateSubtaskHandler(w http.ResponseWriter, r *http.Request) {
-	idStr := r.PathValue("id") // todo_id
-	todoID, _ := strconv.Atoi(idStr)
+type createSubtaskRequest struct {
+	Title string `json:"title"`
+}
+
+type updateSubtaskRequest struct {
+	Title     *string `json:"title,omitempty"`
+	Completed *bool   `json:"completed,omitempty"`
+}
 
-	var req struct {
-		Title string `json:"title"`
+type patchSubtaskRequest struct {
+	Title     optional[string] `json:"title,omitempty"`
+	Completed optional[bool]   `json:"completed,omitempty"`
+}
+
+func (s *Server) CreateSubtaskHandler(w http.ResponseWriter, r *http.Request) {
+	todoID, ok := pathID(w, r)
+	if !ok {
+		return
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+
+	var req createSubtaskRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	id, err := s.service(r).CreateSubtask(todoID, req.Title)
+	if err != nil {
+		writeError(w, err, "todo")
+		return
+	}
+	json.NewEncoder(w).Encode(idResponse{ID: id})
+}
+
+func (s *Server) GetSubtasksHandler(w http.ResponseWriter, r *http.Request) {
+	todoID, ok := pathID(w, r)
+	if !ok {
 		return
 	}
-	id, err := service.CreateSubtask(todoID, req.Title)
+
+	subtasks, err := s.service(r).GetSubtasks(todoID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err, "todo")
 		return
 	}
-	json.NewEncoder(w).Encode(map[string]int64{"id": id})
+	if subtasks == nil {
+		subtasks = []db.Subtask{}
+	}
+	json.NewEncoder(w).Encode(subtasks)
 }
 
-func UpdateSubtaskHandler(w http.ResponseWriter, r *http.Request) {
-	idStr := r.PathValue("id")
-	id, _ := strconv.Atoi(idStr)
+// UpdateSubtaskHandler renames a subtask or checks it off. Fields left out
+// of the request keep their current value.
+func (s *Server) UpdateSubtaskHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
 
-	var req struct {
-		Title     *string `json:"title"`
-		Completed *bool   `json:"completed"`
+	var req updateSubtaskRequest
+	if !decodeJSON(w, r, &req) {
+		return
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if err := s.service(r).PatchSubtask(id, service.SubtaskPatch{Title: req.Title, Completed: req.Completed}); err != nil {
+		writeError(w, err, "subtask")
 		return
 	}
+	w.WriteHeader(http.StatusOK)
+}
 
-	// We need to fetch current state if partial update, but for simplicity let's require both or handle carefully
-	// Actually, usually we might just toggle completion or rename. 
-	// Since service UpdateSubtask takes both, we ideally should fetch existing subtask first.
-	// But `GetSubtasks` returns a list. 
-	// Let's assume the frontend sends the full object or we implement partial update in service.
-	// For now, let's assume the frontend sends the current state for fields not changing, OR we fetch it.
-	// BUT `GetSubtask(id)` is not implemented in service.
-	// Let's implement a simple "toggle" or "rename" logic in service? 
-	// No, let's just make the service `UpdateSubtask` more robust or assume the frontend provides what's needed.
-	// Wait, I implemented `UpdateSubtask(id, title, completed)` in service.
-	// I should probably allow passing empty title to mean "keep existing" if I want partial updates, but SQL doesn't work that way easily without dynamic query.
-	// Let's rely on frontend sending both for now, or just default to empty/false which is risky.
-	
-	// Better approach:
-	// If I want to support partial updates properly, I should add `GetSubtask(id)` to service.
-	// But to save time, I will assume the frontend sends valid data.
-	
-	title := ""
-	if req.Title != nil {
-		title = *req.Title
+// PatchSubtaskHandler applies a JSON Merge Patch to a subtask. A null
+// completed unchecks it.
+func (s *Server) PatchSubtaskHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
 	}
-	completed := false
-	if req.Completed != nil {
-		completed = *req.Completed
+
+	var req patchSubtaskRequest
+	if !decodeJSON(w, r, &req) {
+		return
 	}
-	
-	if err := service.UpdateSubtask(id, title, completed); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	p := service.SubtaskPatch{Title: req.Title.orZero(), Completed: req.Completed.orZero()}
+	if err := s.service(r).PatchSubtask(id, p); err != nil {
+		writeError(w, err, "subtask")
 		return
 	}
 	w.WriteHeader(http.StatusOK)
 }
 
-func DeleteSubtaskHandler(w http.ResponseWriter, r *http.Request) {
-	idStr := r.PathValue("id")
-	id, _ := strconv.Atoi(idStr)
+func (s *Server) DeleteSubtaskHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
 
-	if err := service.DeleteSubtask(id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := s.service(r).DeleteSubtask(id); err != nil {
+		writeError(w, err, "subtask")
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+func (s *Server) GetTrashHandler(w http.ResponseWriter, r *http.Request) {
+	trash, err := s.service(r).GetTrash()
+	if err != nil {
+		writeError(w, err, "trash")
+		return
+	}
+	json.NewEncoder(w).Encode(trash)
+}
+
+func (s *Server) RestoreTodoHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.service(r).RestoreTodo(id); err != nil {
+		writeError(w, err, "deleted todo")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) RestoreProjectHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.service(r).RestoreProject(id); err != nil {
+		writeError(w, err, "deleted project")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// RestoreSubtaskHandler answers 404 for a subtask whose todo is in the
+// trash: restoring the todo brings the subtask back with it.
+func (s *Server) RestoreSubtaskHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.service(r).RestoreSubtask(id); err != nil {
+		writeError(w, err, "deleted subtask")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
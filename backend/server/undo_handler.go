@@ -0,0 +1,50 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"todo/backend/service"
+)
+
+// recorderKey is the context key of the request's service.Recorder.
+type recorderKey struct{}
+
+// recorded makes the changes h makes through s.service one command that
+// POST /api/undo reverts, named after the route.
+func recorded(name string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rec := &service.Recorder{SessionID: sessionID(r), Name: name}
+		h(w, r.WithContext(context.WithValue(r.Context(), recorderKey{}, rec)))
+	}
+}
+
+// sessionID is what the undo history is kept per: the token of the
+// request, or 0 when auth isn't required.
+func sessionID(r *http.Request) int {
+	if sess := requestSession(r); sess != nil {
+		return sess.token.ID
+	}
+	return 0
+}
+
+// UndoHandler reverts the latest change of the session and returns it. It
+// answers 409 if what the change touched has been changed since.
+func (s *Server) UndoHandler(w http.ResponseWriter, r *http.Request) {
+	c, err := s.service(r).Undo(sessionID(r))
+	if err != nil {
+		writeError(w, err, "change to undo")
+		return
+	}
+	json.NewEncoder(w).Encode(c)
+}
+
+// RedoHandler makes again the change of the session undone last.
+func (s *Server) RedoHandler(w http.ResponseWriter, r *http.Request) {
+	c, err := s.service(r).Redo(sessionID(r))
+	if err != nil {
+		writeError(w, err, "change to redo")
+		return
+	}
+	json.NewEncoder(w).Encode(c)
+}
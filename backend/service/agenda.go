@@ -0,0 +1,205 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"todo/backend/db"
+	"todo/backend/filter"
+)
+
+// ErrInvalidDate is returned by GetAgenda for a date that isn't a day.
+var ErrInvalidDate = errors.New("invalid date: use YYYY-MM-DD, today, tomorrow or +Nd")
+
+// Agenda summarizes the open todos that need attention on a day, grouped by
+// project.
+type Agenda struct {
+	Date         string          `json:"date"` // YYYY-MM-DD
+	TimeZone     string          `json:"timezone"`
+	Overdue      int             `json:"overdue"`
+	DueToday     int             `json:"due_today"`
+	HighPriority int             `json:"high_priority"`
+	Projects     []AgendaProject `json:"projects"`
+
+	day time.Time // midnight of Date in TimeZone
+}
+
+// AgendaProject is one project's share of an Agenda. Todos without a project
+// are grouped under a nil ProjectID.
+type AgendaProject struct {
+	ProjectID *int   `json:"project_id"`
+	Name      string `json:"name"`
+	// Overdue were due before the day, DueToday are due on it, and
+	// HighPriority are the other high-priority todos.
+	Overdue      []db.Todo `json:"overdue"`
+	DueToday     []db.Todo `json:"due_today"`
+	HighPriority []db.Todo `json:"high_priority"`
+}
+
+// noProjectName labels the group of todos without a project.
+const noProjectName = "No project"
+
+// Empty reports whether nothing needs attention.
+func (a *Agenda) Empty() bool {
+	return a.Overdue+a.DueToday+a.HighPriority == 0
+}
+
+// GetAgenda returns the agenda for date, which is anything filter.ResolveDate
+// accepts as a day ("" means today), in the IANA zone tz ("" means UTC).
+func (s *Service) GetAgenda(date, tz string) (*Agenda, error) {
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidTimeZone, tz)
+	}
+	if date == "" {
+		date = "today"
+	}
+	start, end, err := filter.ResolveDate(date, time.Now().In(loc))
+	if err != nil || start.Equal(end) {
+		return nil, ErrInvalidDate
+	}
+	return s.agenda(start)
+}
+
+// agenda builds the agenda for the day starting at midnight day.
+func (s *Service) agenda(day time.Time) (*Agenda, error) {
+	active, err := filter.Parse("is:active")
+	if err != nil {
+		return nil, err
+	}
+	todos, _, err := s.repos.Todos.List(db.TodoQuery{Filter: active, Sort: "due", Now: day})
+	if err != nil {
+		return nil, err
+	}
+	projects, err := s.repos.Projects.List()
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[int]string, len(projects))
+	for _, p := range projects {
+		names[p.ID] = p.Name
+	}
+
+	a := &Agenda{Date: day.Format(time.DateOnly), TimeZone: day.Location().String(), Projects: []AgendaProject{}, day: day}
+	end := day.AddDate(0, 0, 1)
+	groups := map[int]*AgendaProject{}
+	group := func(projectID *int) *AgendaProject {
+		key := -1
+		if projectID != nil {
+			key = *projectID
+		}
+		if g, ok := groups[key]; ok {
+			return g
+		}
+		g := &AgendaProject{ProjectID: projectID, Name: noProjectName, Overdue: []db.Todo{}, DueToday: []db.Todo{}, HighPriority: []db.Todo{}}
+		if projectID != nil {
+			g.Name = names[*projectID]
+		}
+		groups[key] = g
+		return g
+	}
+	for _, t := range todos {
+		switch {
+		case t.DueDate != nil && t.DueDate.Before(day):
+			g := group(t.ProjectID)
+			g.Overdue = append(g.Overdue, t)
+			a.Overdue++
+		case t.DueDate != nil && t.DueDate.Before(end):
+			g := group(t.ProjectID)
+			g.DueToday = append(g.DueToday, t)
+			a.DueToday++
+		case t.Priority == "high":
+			g := group(t.ProjectID)
+			g.HighPriority = append(g.HighPriority, t)
+			a.HighPriority++
+		}
+	}
+
+	for _, g := range groups {
+		a.Projects = append(a.Projects, *g)
+	}
+	// Named projects alphabetically, todos without a project last
+	sort.Slice(a.Projects, func(i, j int) bool {
+		pi, pj := a.Projects[i], a.Projects[j]
+		if (pi.ProjectID == nil) != (pj.ProjectID == nil) {
+			return pj.ProjectID == nil
+		}
+		if pi.Name != pj.Name {
+			return pi.Name < pj.Name
+		}
+		return pi.ProjectID != nil && *pi.ProjectID < *pj.ProjectID
+	})
+	return a, nil
+}
+
+// Title is the heading of the agenda, e.g. "Agenda for Saturday, October 17".
+func (a *Agenda) Title() string {
+	return "Agenda for " + a.day.Format("Monday, January 2")
+}
+
+// Text renders the agenda as plain text, or as Markdown when markdown is set.
+func (a *Agenda) Text(markdown bool) string {
+	var b strings.Builder
+	heading := func(level int, s string) {
+		if markdown {
+			fmt.Fprintf(&b, "%s %s\n\n", strings.Repeat("#", level), s)
+		} else {
+			fmt.Fprintf(&b, "%s\n", s)
+		}
+	}
+
+	heading(1, a.Title())
+	if a.Empty() {
+		b.WriteString("Nothing due.\n")
+		return b.String()
+	}
+	for _, p := range a.Projects {
+		if !markdown {
+			b.WriteString("\n")
+		}
+		heading(2, p.Name)
+		for _, section := range []struct {
+			name  string
+			todos []db.Todo
+		}{{"Overdue", p.Overdue}, {"Due today", p.DueToday}, {"High priority", p.HighPriority}} {
+			if len(section.todos) == 0 {
+				continue
+			}
+			if markdown {
+				fmt.Fprintf(&b, "**%s**\n\n", section.name)
+			} else {
+				fmt.Fprintf(&b, "  %s:\n", section.name)
+			}
+			for _, t := range section.todos {
+				if !markdown {
+					b.WriteString("  ")
+				}
+				fmt.Fprintf(&b, "- %s%s\n", t.Title, a.when(&t))
+			}
+			if markdown {
+				b.WriteString("\n")
+			}
+		}
+	}
+	return b.String()
+}
+
+// when describes a todo's due date relative to the agenda's day.
+func (a *Agenda) when(t *db.Todo) string {
+	if t.DueDate == nil {
+		return ""
+	}
+	due := t.DueDate.In(a.day.Location())
+	sameDay := due.Year() == a.day.Year() && due.YearDay() == a.day.YearDay()
+	switch {
+	case t.AllDay && sameDay:
+		return ""
+	case t.AllDay:
+		return " (due " + t.DueDate.In(t.Location()).Format("Jan 2") + ")"
+	case sameDay:
+		return " (" + due.Format("15:04") + ")"
+	}
+	return " (due " + due.Format("Jan 2 15:04") + ")"
+}
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"todo/backend/db"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrInvalidCredentials is returned by Login for an unknown user or a wrong
+// password; which one isn't revealed.
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
+// ErrInvalidToken is returned by Authenticate for a token that doesn't
+// exist, was revoked or has expired.
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// ErrInvalidUsername is returned for a username with characters other than
+// letters, digits, '.', '-' and '_', or longer than 64.
+var ErrInvalidUsername = errors.New("invalid username: use 1-64 letters, digits, '.', '-' or '_'")
+
+// ErrWeakPassword is returned for a password shorter than MinPasswordLength.
+var ErrWeakPassword = fmt.Errorf("password must be at least %d characters", MinPasswordLength)
+
+// ErrUserExists is returned by CreateUser for a username that is taken,
+// ignoring case.
+var ErrUserExists = errors.New("username is taken")
+
+const (
+	// MinPasswordLength is the shortest password CreateUser and SetPassword
+	// accept.
+	MinPasswordLength = 8
+
+	// SessionTTL is how long a token issued by Login is valid.
+	SessionTTL = 30 * 24 * time.Hour
+
+	// tokenPrefix starts every token, so leaked ones are easy to spot.
+	tokenPrefix = "todo_"
+
+	// touchInterval limits how often a token's last use is written.
+	touchInterval = time.Minute
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// dummyHash is compared against when logging in as an unknown user, so the
+// response takes as long as for a wrong password.
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("not a password"), bcrypt.DefaultCost)
+	return hash
+})
+
+// IssuedToken is a token that was just created, with its secret. The
+// secret is shown this once: only its hash is stored.
+type IssuedToken struct {
+	db.Token
+	Secret string `json:"token"`
+}
+
+func hashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func hashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength {
+		return "", ErrWeakPassword
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+// CreateUser adds an account. The first account created takes over the
+// todos and projects that were made before there were any.
+func (s *Service) CreateUser(username, password string) (int64, error) {
+	if !usernamePattern.MatchString(username) {
+		return 0, ErrInvalidUsername
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return 0, err
+	}
+	var id int64
+	err = s.atomically(func(tx *Service) error {
+		users, err := tx.repos.Users.List()
+		if err != nil {
+			return err
+		}
+		for _, u := range users {
+			if strings.EqualFold(u.Username, username) {
+				return ErrUserExists
+			}
+		}
+		id, err = tx.repos.Users.Create(&db.User{Username: username, PasswordHash: hash})
+		if err != nil || len(users) > 0 {
+			return err
+		}
+		return tx.repos.Users.ClaimUnowned(int(id))
+	})
+	return id, err
+}
+
+func (s *Service) GetUsers() ([]db.User, error) {
+	return s.repos.Users.List()
+}
+
+// SetPassword changes a user's password and signs out their sessions. API
+// tokens stay valid.
+func (s *Service) SetPassword(username, password string) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	return s.atomically(func(tx *Service) error {
+		u, err := tx.repos.Users.GetByName(username)
+		if err != nil {
+			return err
+		}
+		if err := tx.repos.Users.UpdatePassword(u.ID, hash); err != nil {
+			return err
+		}
+		return tx.repos.Tokens.DeleteByUser(u.ID, db.TokenSession)
+	})
+}
+
+// Login checks a user's password and starts a session that lasts
+// SessionTTL.
+func (s *Service) Login(username, password string) (*IssuedToken, *db.User, error) {
+	u, err := s.repos.Users.GetByName(username)
+	if errors.Is(err, sql.ErrNoRows) {
+		bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+		return nil, nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) != nil {
+		return nil, nil, ErrInvalidCredentials
+	}
+
+	now := time.Now()
+	if _, err := s.repos.Tokens.DeleteExpired(now); err != nil {
+		log.Printf("Failed to delete expired tokens: %v", err)
+	}
+	expires := now.Add(SessionTTL)
+	t, err := s.issueToken(&db.Token{UserID: u.ID, Kind: db.TokenSession, ExpiresAt: &expires})
+	if err != nil {
+		return nil, nil, err
+	}
+	return t, u, nil
+}
+
+// CreateAPIToken issues a personal API token that doesn't expire until it
+// is revoked.
+func (s *Service) CreateAPIToken(userID int, name string) (*IssuedToken, error) {
+	return s.issueToken(&db.Token{UserID: userID, Kind: db.TokenAPI, Name: name})
+}
+
+func (s *Service) issueToken(t *db.Token) (*IssuedToken, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	secret := tokenPrefix + base64.RawURLEncoding.EncodeToString(raw)
+	t.Hash = hashToken(secret)
+	id, err := s.repos.Tokens.Create(t)
+	if err != nil {
+		return nil, err
+	}
+	t.ID = int(id)
+	t.CreatedAt = time.Now().UTC()
+	return &IssuedToken{Token: *t, Secret: secret}, nil
+}
+
+// GetAPITokens lists a user's API tokens, without their secrets.
+func (s *Service) GetAPITokens(userID int) ([]db.Token, error) {
+	tokens, err := s.repos.Tokens.ListByUser(userID, db.TokenAPI)
+	if tokens == nil {
+		tokens = []db.Token{}
+	}
+	return tokens, err
+}
+
+// RevokeToken deletes one of a user's tokens, signing a session out. It
+// returns sql.ErrNoRows if the user has no such token.
+func (s *Service) RevokeToken(userID, tokenID int) error {
+	ok, err := s.repos.Tokens.Delete(tokenID, userID)
+	if err == nil && !ok {
+		err = sql.ErrNoRows
+	}
+	return err
+}
+
+// Authenticate returns the user a session or API token belongs to, and the
+// token itself.
+func (s *Service) Authenticate(secret string) (*db.User, *db.Token, error) {
+	if !strings.HasPrefix(secret, tokenPrefix) {
+		return nil, nil, ErrInvalidToken
+	}
+	t, err := s.repos.Tokens.GetByHash(hashToken(secret))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	if t.ExpiresAt != nil && !now.Before(*t.ExpiresAt) {
+		return nil, nil, ErrInvalidToken
+	}
+	u, err := s.repos.Users.Get(t.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if t.LastUsedAt == nil || now.Sub(*t.LastUsedAt) >= touchInterval {
+		if err := s.repos.Tokens.Touch(t.ID, now); err != nil {
+			log.Printf("Failed to record use of token %d: %v", t.ID, err)
+		}
+	}
+	return u, t, nil
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"todo/backend/filter"
+)
+
+// ErrNoDueDate is returned when shifting the due date of a todo that has
+// none.
+var ErrNoDueDate = errors.New("todo has no due date")
+
+// MaxBatchSize caps the number of operations in one Batch.
+const MaxBatchSize = 1000
+
+// BatchAction is what a BatchOp does to its todo.
+type BatchAction string
+
+const (
+	BatchComplete    BatchAction = "complete"
+	BatchReopen      BatchAction = "reopen"
+	BatchDelete      BatchAction = "delete"
+	BatchMove        BatchAction = "move"         // to ProjectID, or out of its project if nil
+	BatchAddTag      BatchAction = "add_tag"      // Tag
+	BatchRemoveTag   BatchAction = "remove_tag"   // Tag
+	BatchSetPriority BatchAction = "set_priority" // Priority
+	BatchShiftDue    BatchAction = "shift_due"    // by Days, keeping the time of day
+)
+
+// BatchOp is one operation of a Batch.
+type BatchOp struct {
+	Action    BatchAction `json:"op"`
+	ID        int         `json:"id"` // of the todo
+	ProjectID *int        `json:"project_id,omitempty"`
+	Tag       string      `json:"tag,omitempty"`
+	Priority  string      `json:"priority,omitempty"`
+	Days      int         `json:"days,omitempty"` // negative moves the due date earlier
+}
+
+// BatchError is returned by an atomic Batch when the operation at Index
+// failed, after the whole batch was rolled back.
+type BatchError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("operation %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// Batch runs ops in order in one transaction and returns the error of each,
+// nil for those that succeeded. With atomic, the first failing operation
+// rolls the whole batch back and Batch returns a *BatchError. Otherwise a
+// failing operation is undone on its own and the others are kept.
+func (s *Service) Batch(ops []BatchOp, atomic bool) ([]error, error) {
+	var v validation
+	v.check(len(ops) > 0, "operations", "must not be empty")
+	v.check(len(ops) <= MaxBatchSize, "operations", fmt.Sprintf("must have at most %d items", MaxBatchSize))
+	for i, op := range ops {
+		v.batchOp(fmt.Sprintf("operations[%d].", i), op)
+	}
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+
+	results := make([]error, len(ops))
+	err := s.atomically(func(tx *Service) error {
+		for i, op := range ops {
+			err := tx.atomically(func(tx *Service) error {
+				return tx.apply(op)
+			})
+			if err != nil && atomic {
+				return &BatchError{Index: i, Err: err}
+			}
+			results[i] = err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// apply runs one operation of a batch, which has been validated.
+func (s *Service) apply(op BatchOp) error {
+	switch op.Action {
+	case BatchComplete, BatchReopen:
+		completed := op.Action == BatchComplete
+		return s.PatchTodo(op.ID, "", TodoPatch{Completed: &completed})
+	case BatchDelete:
+		return s.DeleteTodo(op.ID)
+	case BatchMove:
+		return s.PatchTodo(op.ID, "", TodoPatch{ProjectID: Nullable[int]{Set: true, Value: op.ProjectID}})
+	case BatchSetPriority:
+		return s.PatchTodo(op.ID, "", TodoPatch{Priority: &op.Priority})
+	}
+
+	t, err := s.repos.Todos.Get(op.ID)
+	if err != nil {
+		return err
+	}
+	switch op.Action {
+	case BatchAddTag:
+		if slices.Contains(t.Tags, op.Tag) {
+			return nil
+		}
+		return s.PatchTodo(op.ID, "", TodoPatch{Tags: append(slices.Clip(t.Tags), op.Tag)})
+	case BatchRemoveTag:
+		return s.PatchTodo(op.ID, "", TodoPatch{Tags: slices.DeleteFunc(slices.Clone(t.Tags), func(tag string) bool { return tag == op.Tag })})
+	default: // BatchShiftDue
+		if t.DueDate == nil {
+			return fmt.Errorf("%w: todo %d", ErrNoDueDate, op.ID)
+		}
+		// Days are counted in the todo's zone, so 9:00 stays 9:00 across DST
+		loc := t.Location()
+		p := TodoPatch{DueDate: Nullable[time.Time]{Set: true, Value: shiftDays(t.DueDate, loc, op.Days)}}
+		if t.RemindAt != nil {
+			p.RemindAt = Nullable[time.Time]{Set: true, Value: shiftDays(t.RemindAt, loc, op.Days)}
+		}
+		return s.PatchTodo(op.ID, "", p)
+	}
+}
+
+func shiftDays(t *time.Time, loc *time.Location, days int) *time.Time {
+	shifted := t.In(loc).AddDate(0, 0, days)
+	return &shifted
+}
+
+// batchOp checks the fields of op that don't depend on its todo, naming
+// them with prefix.
+func (v *validation) batchOp(prefix string, op BatchOp) {
+	switch op.Action {
+	case BatchComplete, BatchReopen, BatchDelete, BatchMove, BatchShiftDue:
+	case BatchAddTag, BatchRemoveTag:
+		v.check(strings.TrimSpace(op.Tag) != "", prefix+"tag", "is required")
+	case BatchSetPriority:
+		v.check(slices.Contains(filter.Priorities, op.Priority), prefix+"priority", "must be low, medium or high")
+	default:
+		v.check(false, prefix+"op", "must be complete, reopen, delete, move, add_tag, remove_tag, set_priority or shift_due")
+	}
+	v.check(op.ID > 0, prefix+"id", "must be a positive integer")
+}
@@ -0,0 +1,10 @@
+package service
+
+import "todo/backend/db"
+
+// GetTodoHistory returns what has been done to a todo and its subtasks,
+// oldest first: when each was created and every field changed since, with
+// who changed it. It returns sql.ErrNoRows if there is no such todo.
+func (s *Service) GetTodoHistory(id int) ([]db.Event, error) {
+	return s.repos.Events.ListByTodo(id)
+}
@@ -1,54 +1,244 @@
 package service
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"time"
 	"todo/backend/db"
-
-	"github.com/gen2brain/beeep"
 )
 
-func StartNotificationScheduler() {
-	// Check immediately on start
-	go checkReminders()
+// SchedulerOptions tunes reminder delivery. Zero values pick the defaults.
+type SchedulerOptions struct {
+	Interval      time.Duration // how often to look for due reminders; default 1m
+	CatchUpCutoff time.Duration // reminders missed by longer than this are dropped; default 24h
+	MaxAttempts   int           // deliveries tried before giving up; default 5
+	RetryBackoff  time.Duration // delay before the first retry, doubling after each; default 1m
+	SendTimeout   time.Duration // how long one delivery may take; default 30s
+	Digest        DigestOptions
+}
+
+// DigestOptions schedules the daily digest, a summary of overdue, due and
+// high-priority todos. It is off while At is empty.
+type DigestOptions struct {
+	At       string `json:"time"`     // local time of day as HH:MM, e.g. "08:00"
+	TimeZone string `json:"timezone"` // IANA zone At is in; "" means UTC
+}
+
+// next returns when the digest for the day of now is due, and that day.
+func (o DigestOptions) next(now time.Time) (sendAt, day time.Time, err error) {
+	at, err := time.Parse("15:04", o.At)
+	if err != nil {
+		return sendAt, day, fmt.Errorf("digest time %q: want HH:MM", o.At)
+	}
+	loc, err := time.LoadLocation(o.TimeZone)
+	if err != nil {
+		return sendAt, day, fmt.Errorf("%w: %q", ErrInvalidTimeZone, o.TimeZone)
+	}
+	local := now.In(loc)
+	day = time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	sendAt = time.Date(local.Year(), local.Month(), local.Day(), at.Hour(), at.Minute(), 0, 0, loc)
+	return sendAt, day, nil
+}
+
+// Validate reports whether the options describe a usable schedule.
+func (o DigestOptions) Validate() error {
+	if o.At == "" {
+		return nil
+	}
+	_, _, err := o.next(time.Now())
+	return err
+}
 
-	ticker := time.NewTicker(1 * time.Minute)
-	go func() {
-		for range ticker.C {
-			checkReminders()
+func (o SchedulerOptions) withDefaults() SchedulerOptions {
+	if o.Interval <= 0 {
+		o.Interval = time.Minute
+	}
+	if o.CatchUpCutoff <= 0 {
+		o.CatchUpCutoff = 24 * time.Hour
+	}
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = 5
+	}
+	if o.RetryBackoff <= 0 {
+		o.RetryBackoff = time.Minute
+	}
+	if o.SendTimeout <= 0 {
+		o.SendTimeout = 30 * time.Second
+	}
+	return o
+}
+
+// pendingBatch caps how many notifications one check delivers.
+const pendingBatch = 100
+
+// StartNotificationScheduler runs the scheduler in the background for the
+// life of the process.
+func (s *Service) StartNotificationScheduler(opts SchedulerOptions) {
+	go s.RunNotificationScheduler(context.Background(), opts)
+}
+
+// RunNotificationScheduler delivers reminders and digests until ctx is
+// done. It returns once any check in progress has finished, so callers can
+// wait for it before closing the database.
+func (s *Service) RunNotificationScheduler(ctx context.Context, opts SchedulerOptions) {
+	opts = opts.withDefaults()
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+
+	// Check immediately on start, which also catches up on reminders that
+	// came due while the app wasn't running
+	now := time.Now()
+	for {
+		s.checkReminders(now, opts)
+		s.checkDigest(now, opts)
+		select {
+		case <-ctx.Done():
+			return
+		case now = <-ticker.C:
 		}
-	}()
+	}
 }
 
-func checkReminders() {
-	// Use UTC because backend stores times in UTC (from ISO strings)
-	now := time.Now().UTC()
-	// Check for reminders in the current minute window (or slightly past if we missed it)
-	// Let's look for anything scheduled in the [now, now+1m) window.
-	// But to be safe against slight skews, maybe [now-30s, now+30s]?
-	// Actually, best is [now, now+1m) for forward looking.
-	
-	start := now
-	end := now.Add(1 * time.Minute)
+// checkReminders queues every reminder due by now and delivers the queue.
+// Each notification is claimed before it is sent, so overlapping checks
+// don't both send it, and marked sent only once it has been delivered. A
+// claim lapses after twice the send timeout, so a reminder whose delivery
+// was cut short by a crash is sent again rather than lost. A retry after a
+// delivery that failed on some channels only goes to those.
+func (s *Service) checkReminders(now time.Time, opts SchedulerOptions) {
+	err := s.atomically(func(tx *Service) error {
+		if _, err := tx.repos.Notifications.Enqueue(now); err != nil {
+			return err
+		}
+		_, err := tx.repos.Notifications.Expire(now.Add(-opts.CatchUpCutoff))
+		return err
+	})
+	if err != nil {
+		log.Println("Error queueing reminders:", err)
+		return
+	}
 
-	rows, err := db.DB.Query("SELECT title, description FROM todos WHERE completed = false AND remind_at >= ? AND remind_at < ?", start, end)
+	pending, err := s.repos.Notifications.ListPending(now, pendingBatch)
 	if err != nil {
 		log.Println("Error checking reminders:", err)
 		return
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var title, description string
-		if err := rows.Scan(&title, &description); err != nil {
+	for _, n := range pending {
+		claimed, err := s.repos.Notifications.Claim(n.ID, now, now.Add(2*opts.SendTimeout))
+		if err != nil {
+			log.Println("Error claiming notification:", err)
 			continue
 		}
-		
-		// Send notification
-		log.Printf("Sending notification for task: %s", title)
-		err := beeep.Notify("Todo Reminder", title, "")
-		if err != nil {
+		if !claimed {
+			continue
+		}
+
+		log.Printf("Sending notification for task: %s", n.Title)
+		msg := Message{Title: "Todo Reminder", Body: n.Title, TodoID: n.TodoID, ProjectID: n.ProjectID, UserID: n.OwnerID, NotificationID: n.ID, Delivered: n.Delivered}
+		if err := s.send(msg, opts.SendTimeout); err != nil {
 			log.Println("Error sending notification:", err)
+			var retryAt *time.Time
+			if attempts := n.Attempts + 1; attempts < opts.MaxAttempts {
+				t := now.Add(opts.RetryBackoff << (attempts - 1))
+				retryAt = &t
+			}
+			if err := s.repos.Notifications.MarkFailed(n.ID, err.Error(), delivered(msg, err), retryAt); err != nil {
+				log.Println("Error recording failed notification:", err)
+			}
+			continue
 		}
+		if err := s.repos.Notifications.MarkSent(n.ID, now); err != nil {
+			log.Println("Error recording sent notification:", err)
+		}
+	}
+}
+
+// checkDigest sends the day's digest once its time has come: one per user,
+// of that user's todos and to that user's channels, or a single one of all
+// todos while there are no users. A digest that is missed because the app
+// wasn't running goes out when it starts, as long as it is still the same
+// day; a day with nothing to report is skipped.
+func (s *Service) checkDigest(now time.Time, opts SchedulerOptions) {
+	if opts.Digest.At == "" {
+		return
+	}
+	sendAt, day, err := opts.Digest.next(now)
+	if err != nil {
+		log.Println("Error scheduling digest:", err)
+		return
+	}
+	if now.Before(sendAt) {
+		return
+	}
+
+	var users []db.User
+	if s.forUser != nil {
+		if users, err = s.repos.Users.List(); err != nil {
+			log.Println("Error listing digest recipients:", err)
+			return
+		}
+	}
+	if len(users) == 0 {
+		s.sendDigest(day, now, nil, opts)
+		return
+	}
+	for _, u := range users {
+		s.ForUser(u.ID).sendDigest(day, now, &u.ID, opts)
+	}
+}
+
+// sendDigest claims, builds and delivers the digest for day of the todos s
+// sees, addressed to userID. A failed delivery is retried with backoff like
+// a reminder, and only on the channels that failed.
+func (s *Service) sendDigest(day, now time.Time, userID *int, opts SchedulerOptions) {
+	date := day.Format(time.DateOnly)
+	d, err := s.repos.Digests.Claim(date, now, now.Add(2*opts.SendTimeout))
+	if err != nil || d == nil {
+		if err != nil {
+			log.Println("Error claiming digest:", err)
+		}
+		return
+	}
+	fail := func(err error, delivered []int) {
+		var retryAt *time.Time
+		if d.Attempts < opts.MaxAttempts {
+			t := now.Add(opts.RetryBackoff << (d.Attempts - 1))
+			retryAt = &t
+		}
+		if err := s.repos.Digests.MarkFailed(date, err.Error(), delivered, retryAt); err != nil {
+			log.Println("Error recording failed digest:", err)
+		}
+	}
+
+	agenda, err := s.agenda(day)
+	if err != nil {
+		log.Println("Error building digest:", err)
+		fail(err, d.Delivered)
+		return
+	}
+	if !agenda.Empty() {
+		log.Printf("Sending digest for %s", date)
+		msg := Message{Title: agenda.Title(), Body: agenda.Text(false), UserID: userID, Delivered: d.Delivered}
+		if err := s.send(msg, opts.SendTimeout); err != nil {
+			log.Println("Error sending digest:", err)
+			fail(err, delivered(msg, err))
+			return
+		}
+	}
+	if err := s.repos.Digests.MarkSent(date, now); err != nil {
+		log.Println("Error recording sent digest:", err)
+	}
+}
+
+// send delivers a message through the configured notifier.
+func (s *Service) send(m Message, timeout time.Duration) error {
+	n := s.notifier
+	if n == nil {
+		n = DesktopNotifier{}
 	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return n.Notify(ctx, m)
 }
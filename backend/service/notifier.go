@@ -0,0 +1,308 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gen2brain/beeep"
+)
+
+// Message is a notification handed to a Notifier.
+type Message struct {
+	Title     string `json:"title"`
+	Body      string `json:"body"`
+	TodoID    int    `json:"todo_id,omitempty"`
+	ProjectID *int   `json:"project_id,omitempty"`
+	UserID    *int   `json:"user_id,omitempty"` // whose todo or digest it is
+	// NotificationID lets receivers snooze or dismiss the reminder through
+	// the API.
+	NotificationID int `json:"notification_id,omitempty"`
+	// Delivered lists the channels, by position among those the message is
+	// routed to, that already have it from an earlier attempt.
+	// MultiNotifier skips them.
+	Delivered []int `json:"-"`
+}
+
+// Notifier delivers messages over one channel.
+type Notifier interface {
+	Notify(ctx context.Context, m Message) error
+}
+
+// NotifierFunc adapts a function to the Notifier interface.
+type NotifierFunc func(ctx context.Context, m Message) error
+
+func (f NotifierFunc) Notify(ctx context.Context, m Message) error {
+	return f(ctx, m)
+}
+
+// DesktopNotifier shows a native desktop notification.
+type DesktopNotifier struct{}
+
+func (DesktopNotifier) Notify(ctx context.Context, m Message) error {
+	return beeep.Notify(m.Title, m.Body, "")
+}
+
+// LogNotifier writes each message as a line to W, or to the standard logger
+// when W is nil. It suits headless servers without any other channel.
+type LogNotifier struct {
+	W  io.Writer
+	mu sync.Mutex
+}
+
+func (n *LogNotifier) Notify(ctx context.Context, m Message) error {
+	if n.W == nil {
+		log.Printf("[%s] %s", m.Title, m.Body)
+		return nil
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	_, err := fmt.Fprintf(n.W, "%s [%s] %s\n", time.Now().UTC().Format(time.RFC3339), m.Title, m.Body)
+	return err
+}
+
+// RecordingNotifier keeps every message it is given, failing with Err when
+// set. It stands in for real channels in tests.
+type RecordingNotifier struct {
+	mu       sync.Mutex
+	Err      error
+	messages []Message
+}
+
+func (n *RecordingNotifier) Notify(ctx context.Context, m Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.Err != nil {
+		return n.Err
+	}
+	n.messages = append(n.messages, m)
+	return nil
+}
+
+// Messages returns the messages delivered so far.
+func (n *RecordingNotifier) Messages() []Message {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]Message(nil), n.messages...)
+}
+
+// MultiNotifier delivers to every notifier in the list not in the message's
+// Delivered, reporting all failures in a *DeliveryError.
+type MultiNotifier []Notifier
+
+func (ns MultiNotifier) Notify(ctx context.Context, m Message) error {
+	var errs []error
+	delivered := slices.Clone(m.Delivered)
+	for i, n := range ns {
+		if slices.Contains(m.Delivered, i) {
+			continue
+		}
+		if err := n.Notify(ctx, m); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		delivered = append(delivered, i)
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return &DeliveryError{Delivered: delivered, Err: errors.Join(errs...)}
+}
+
+// DeliveryError is a delivery that failed on some channels of a
+// MultiNotifier. Delivered lists the channels that have the message, so a
+// retry can leave them out.
+type DeliveryError struct {
+	Delivered []int
+	Err       error
+}
+
+func (e *DeliveryError) Error() string { return e.Err.Error() }
+func (e *DeliveryError) Unwrap() error { return e.Err }
+
+// delivered returns the channels that have m after a delivery failed with
+// err: those err reports, or those m already had.
+func delivered(m Message, err error) []int {
+	var de *DeliveryError
+	if errors.As(err, &de) {
+		return de.Delivered
+	}
+	return m.Delivered
+}
+
+// ChannelConfig configures one delivery channel. Which fields apply depends
+// on Type:
+//
+//	desktop                 native notification (default)
+//	log                     Path to append to, or the server log when empty
+//	smtp                    Addr, From, To, and optionally Username/Password
+//	webhook                 URL receiving a JSON POST of the Message
+//	ntfy                    URL of the topic, optional Token
+//	gotify                  URL of the server, Token of the application
+type ChannelConfig struct {
+	Type     string   `json:"type"`
+	Path     string   `json:"path,omitempty"`
+	URL      string   `json:"url,omitempty"`
+	Token    string   `json:"token,omitempty"`
+	Addr     string   `json:"addr,omitempty"`
+	From     string   `json:"from,omitempty"`
+	To       []string `json:"to,omitempty"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+}
+
+// NotificationConfig selects the channels reminders go out on. Todos in a
+// project listed in Projects (keyed by project ID) use that project's
+// channels; otherwise messages for a user listed in Users (keyed by user
+// ID), that user's digest included, use that user's channels. Messages of
+// other users are dropped, so they don't end up in a shared inbox, unless
+// SharedDefault is set. Messages of no user use Default.
+type NotificationConfig struct {
+	Default       []ChannelConfig            `json:"default"`
+	Projects      map[string][]ChannelConfig `json:"projects,omitempty"`
+	Users         map[string][]ChannelConfig `json:"users,omitempty"`
+	SharedDefault bool                       `json:"shared_default,omitempty"`
+	Digest        DigestOptions              `json:"digest"`
+}
+
+// LoadNotificationConfig reads a JSON NotificationConfig. A missing file
+// yields an empty config, which NewNotifierRouter fills with fallback.
+func LoadNotificationConfig(path string) (NotificationConfig, error) {
+	var cfg NotificationConfig
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return cfg, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := cfg.Digest.Validate(); err != nil {
+		return cfg, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// NewNotifier builds the notifier for a single channel.
+func NewNotifier(c ChannelConfig) (Notifier, error) {
+	switch c.Type {
+	case "", "desktop":
+		return DesktopNotifier{}, nil
+	case "log":
+		if c.Path == "" {
+			return &LogNotifier{}, nil
+		}
+		f, err := os.OpenFile(c.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		return &LogNotifier{W: f}, nil
+	case "smtp":
+		if c.Addr == "" || c.From == "" || len(c.To) == 0 {
+			return nil, fmt.Errorf("smtp channel needs addr, from and to")
+		}
+		return &SMTPNotifier{Addr: c.Addr, From: c.From, To: c.To, Username: c.Username, Password: c.Password}, nil
+	case "webhook":
+		if c.URL == "" {
+			return nil, fmt.Errorf("webhook channel needs a url")
+		}
+		return &WebhookNotifier{URL: c.URL}, nil
+	case "ntfy":
+		if c.URL == "" {
+			return nil, fmt.Errorf("ntfy channel needs a url")
+		}
+		return &NtfyNotifier{URL: c.URL, Token: c.Token}, nil
+	case "gotify":
+		if c.URL == "" || c.Token == "" {
+			return nil, fmt.Errorf("gotify channel needs a url and token")
+		}
+		return &GotifyNotifier{URL: c.URL, Token: c.Token}, nil
+	}
+	return nil, fmt.Errorf("unknown notification channel %q", c.Type)
+}
+
+// NotifierRouter sends each message to the channels configured for its
+// todo's project, failing that for its user. A message of a user without
+// channels is dropped unless SharedDefault is set; it and a message of no
+// user go to Default.
+type NotifierRouter struct {
+	Default       Notifier
+	Projects      map[int]Notifier
+	Users         map[int]Notifier
+	SharedDefault bool
+}
+
+// NewNotifierRouter builds the channels in cfg. fallback is used when cfg
+// has no default channels.
+func NewNotifierRouter(cfg NotificationConfig, fallback Notifier) (*NotifierRouter, error) {
+	r := &NotifierRouter{Default: fallback, Projects: map[int]Notifier{}, Users: map[int]Notifier{}, SharedDefault: cfg.SharedDefault}
+	build := func(channels []ChannelConfig) (Notifier, error) {
+		var ns MultiNotifier
+		for _, c := range channels {
+			n, err := NewNotifier(c)
+			if err != nil {
+				return nil, err
+			}
+			ns = append(ns, n)
+		}
+		return ns, nil
+	}
+
+	if len(cfg.Default) > 0 {
+		n, err := build(cfg.Default)
+		if err != nil {
+			return nil, err
+		}
+		r.Default = n
+	}
+	for key, channels := range cfg.Projects {
+		id, err := strconv.Atoi(key)
+		if err != nil {
+			return nil, fmt.Errorf("project %q: not a project ID", key)
+		}
+		if r.Projects[id], err = build(channels); err != nil {
+			return nil, fmt.Errorf("project %d: %w", id, err)
+		}
+	}
+	for key, channels := range cfg.Users {
+		id, err := strconv.Atoi(key)
+		if err != nil {
+			return nil, fmt.Errorf("user %q: not a user ID", key)
+		}
+		if r.Users[id], err = build(channels); err != nil {
+			return nil, fmt.Errorf("user %d: %w", id, err)
+		}
+	}
+	return r, nil
+}
+
+func (r *NotifierRouter) Notify(ctx context.Context, m Message) error {
+	if m.ProjectID != nil {
+		if n, ok := r.Projects[*m.ProjectID]; ok {
+			return n.Notify(ctx, m)
+		}
+	}
+	if m.UserID != nil {
+		if n, ok := r.Users[*m.UserID]; ok {
+			return n.Notify(ctx, m)
+		}
+		if !r.SharedDefault {
+			log.Printf("No notification channels for user %d, dropping %q", *m.UserID, m.Title)
+			return nil
+		}
+	}
+	if r.Default == nil {
+		return nil
+	}
+	return r.Default.Notify(ctx, m)
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strings"
+)
+
+// SMTPNotifier emails each message.
+type SMTPNotifier struct {
+	Addr     string // host:port of the mail server
+	From     string
+	To       []string
+	Username string // PLAIN auth is used when set
+	Password string
+}
+
+func (n *SMTPNotifier) Notify(ctx context.Context, m Message) error {
+	host, _, err := net.SplitHostPort(n.Addr)
+	if err != nil {
+		return err
+	}
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", n.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", m.Title)
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(m.Body)
+	msg.WriteString("\r\n")
+
+	// smtp.SendMail has no deadline, so dial with ctx and close the
+	// connection when it is done to cut short a server that stalls
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", n.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if n.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", n.Username, n.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(n.From); err != nil {
+		return err
+	}
+	for _, to := range n.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(msg.String())); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// WebhookNotifier POSTs each message as JSON.
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client // http.DefaultClient when nil
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, m Message) error {
+	body, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return post(ctx, n.Client, n.URL, "application/json", body, nil)
+}
+
+// NtfyNotifier publishes each message to an ntfy topic.
+type NtfyNotifier struct {
+	URL    string // topic URL, e.g. https://ntfy.sh/my-todos
+	Token  string // access token for protected topics
+	Client *http.Client
+}
+
+func (n *NtfyNotifier) Notify(ctx context.Context, m Message) error {
+	headers := map[string]string{"Title": m.Title}
+	if n.Token != "" {
+		headers["Authorization"] = "Bearer " + n.Token
+	}
+	return post(ctx, n.Client, n.URL, "text/plain; charset=utf-8", []byte(m.Body), headers)
+}
+
+// GotifyNotifier pushes each message to a Gotify server.
+type GotifyNotifier struct {
+	URL    string // server base URL
+	Token  string // application token
+	Client *http.Client
+}
+
+func (n *GotifyNotifier) Notify(ctx context.Context, m Message) error {
+	body, err := json.Marshal(map[string]any{"title": m.Title, "message": m.Body, "priority": 5})
+	if err != nil {
+		return err
+	}
+	target := strings.TrimRight(n.URL, "/") + "/message"
+	return post(ctx, n.Client, target, "application/json", body, map[string]string{"X-Gotify-Key": n.Token})
+}
+
+// post sends body to target and treats any non-2xx response as a failure.
+// Errors name the target without its query or user info, since they end up
+// in logs and in the notification's last error.
+func post(ctx context.Context, client *http.Client, target, contentType string, body []byte, headers map[string]string) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return errors.New("invalid notification URL")
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	where := (&url.URL{Scheme: req.URL.Scheme, Host: req.URL.Host, Path: req.URL.Path}).String()
+	resp, err := client.Do(req)
+	if err != nil {
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			err = uerr.Err
+		}
+		return fmt.Errorf("%s: %w", where, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s: %s", where, resp.Status)
+	}
+	return nil
+}
@@ -1,44 +1,48 @@
 package service
 
 import (
+	"time"
 	"todo/backend/db"
 )
 
-func CreateProject(name, description, color string) (int64, error) {
+func (s *Service) CreateProject(name, description, color string) (int64, error) {
+	if err := validateProject(name); err != nil {
+		return 0, err
+	}
 	if color == "" {
 		color = "#64748B"
 	}
-	res, err := db.DB.Exec("INSERT INTO projects (name, description, color) VALUES (?, ?, ?)", name, description, color)
-	if err != nil {
-		return 0, err
-	}
-	return res.LastInsertId()
+	var id int64
+	err := s.atomically(func(tx *Service) error {
+		var err error
+		id, err = tx.repos.Projects.Create(&db.Project{Name: name, Description: description, Color: color, UpdatedAt: time.Now()})
+		return err
+	})
+	return id, err
 }
 
-func GetProjects() ([]db.Project, error) {
-	rows, err := db.DB.Query("SELECT id, name, description, color, created_at FROM projects ORDER BY created_at ASC")
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+func (s *Service) GetProjects() ([]db.Project, error) {
+	return s.repos.Projects.List()
+}
 
-	var projects []db.Project
-	for rows.Next() {
-		var p db.Project
-		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.Color, &p.CreatedAt); err != nil {
-			return nil, err
-		}
-		projects = append(projects, p)
+func (s *Service) UpdateProject(id int, name, description, color string) error {
+	if err := validateProject(name); err != nil {
+		return err
 	}
-	return projects, nil
+	return s.atomically(func(tx *Service) error {
+		return tx.repos.Projects.Update(&db.Project{ID: id, Name: name, Description: description, Color: color, UpdatedAt: time.Now()})
+	})
 }
 
-func UpdateProject(id int, name, description, color string) error {
-	_, err := db.DB.Exec("UPDATE projects SET name = ?, description = ?, color = ? WHERE id = ?", name, description, color, id)
-	return err
+// DeleteProject moves a project to the trash, taking its todos with it.
+func (s *Service) DeleteProject(id int) error {
+	return s.atomically(func(tx *Service) error {
+		return tx.repos.Projects.Delete(id)
+	})
 }
 
-func DeleteProject(id int) error {
-	_, err := db.DB.Exec("DELETE FROM projects WHERE id = ?", id)
-	return err
+func validateProject(name string) error {
+	var v validation
+	v.title("name", name)
+	return v.err()
 }
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"errors"
+	"time"
+	"todo/backend/db"
+)
+
+// ErrInvalidReminder is returned for a reminder that isn't exactly one of an
+// absolute time or a non-negative offset before the due date.
+var ErrInvalidReminder = errors.New("invalid reminder: give either remind_at or before_due_minutes >= 0")
+
+// ErrInvalidSnooze is returned for an unknown snooze preset or a snooze time
+// that isn't in the future.
+var ErrInvalidSnooze = errors.New("invalid snooze: use 10m, 1h, tomorrow or a future time")
+
+// ErrNotificationClosed is returned when snoozing or dismissing a
+// notification that has already been dismissed or has expired.
+var ErrNotificationClosed = errors.New("notification was dismissed or has expired")
+
+// SnoozePresets are the durations accepted by SnoozeNotification, besides
+// "tomorrow".
+var SnoozePresets = map[string]time.Duration{
+	"10m": 10 * time.Minute,
+	"1h":  time.Hour,
+}
+
+// tomorrowHour is the local hour a reminder snoozed until tomorrow goes off.
+const tomorrowHour = 9
+
+// activeLimit caps how many notifications GetActiveNotifications returns.
+const activeLimit = 100
+
+// AddReminder adds a reminder to a todo, at a fixed time or beforeDueMinutes
+// before it is due.
+func (s *Service) AddReminder(todoID int, remindAt *time.Time, beforeDueMinutes *int) (int64, error) {
+	if (remindAt == nil) == (beforeDueMinutes == nil) || (beforeDueMinutes != nil && *beforeDueMinutes < 0) {
+		return 0, ErrInvalidReminder
+	}
+	var id int64
+	err := s.atomically(func(tx *Service) error {
+		t, err := tx.repos.Todos.Get(todoID)
+		if err != nil {
+			return err
+		}
+		r := &db.Reminder{TodoID: todoID, RemindAt: remindAt, BeforeDueMinutes: beforeDueMinutes}
+		r.FireAt = fireAt(r, t.DueDate)
+		id, err = tx.repos.Reminders.Create(r)
+		return err
+	})
+	return id, err
+}
+
+func (s *Service) GetReminders(todoID int) ([]db.Reminder, error) {
+	return s.repos.Reminders.ListByTodo(todoID)
+}
+
+func (s *Service) DeleteReminder(id int) error {
+	return s.atomically(func(tx *Service) error {
+		return tx.repos.Reminders.Delete(id)
+	})
+}
+
+// fireAt is when r goes off for a todo due at dueDate.
+func fireAt(r *db.Reminder, dueDate *time.Time) *time.Time {
+	if r.BeforeDueMinutes == nil {
+		return r.RemindAt
+	}
+	if dueDate == nil {
+		return nil
+	}
+	at := dueDate.Add(-time.Duration(*r.BeforeDueMinutes) * time.Minute)
+	return &at
+}
+
+// rescheduleReminders moves the relative reminders of t to follow its due
+// date.
+func (s *Service) rescheduleReminders(t *db.Todo) error {
+	reminders, err := s.repos.Reminders.ListByTodo(t.ID)
+	if err != nil {
+		return err
+	}
+	for i := range reminders {
+		r := &reminders[i]
+		if r.BeforeDueMinutes == nil {
+			continue
+		}
+		r.FireAt = fireAt(r, t.DueDate)
+		if err := s.repos.Reminders.UpdateFireAt(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyReminders gives the next occurrence of a repeating todo the reminders
+// of the previous one. Absolute reminders move by as much as the due date
+// did, and are dropped if either occurrence has no due date.
+func (s *Service) copyReminders(from *db.Todo, toID int, toDue *time.Time) error {
+	reminders, err := s.repos.Reminders.ListByTodo(from.ID)
+	if err != nil {
+		return err
+	}
+	for _, r := range reminders {
+		next := &db.Reminder{TodoID: toID, BeforeDueMinutes: r.BeforeDueMinutes}
+		if r.RemindAt != nil {
+			if from.DueDate == nil || toDue == nil {
+				continue
+			}
+			at := r.RemindAt.Add(toDue.Sub(*from.DueDate))
+			next.RemindAt = &at
+		}
+		next.FireAt = fireAt(next, toDue)
+		if _, err := s.repos.Reminders.Create(next); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetActiveNotifications lists the reminders that have gone off and can
+// still be snoozed or dismissed.
+func (s *Service) GetActiveNotifications() ([]db.Notification, error) {
+	notifications, err := s.repos.Notifications.ListActive(activeLimit)
+	if notifications == nil {
+		notifications = []db.Notification{}
+	}
+	return notifications, err
+}
+
+// SnoozeNotification delivers a notification again later: after one of the
+// SnoozePresets, at 9:00 tomorrow in the todo's timezone for "tomorrow", or
+// at until when preset is empty.
+func (s *Service) SnoozeNotification(id int, preset string, until *time.Time) error {
+	now := time.Now()
+	return s.atomically(func(tx *Service) error {
+		n, err := tx.repos.Notifications.Get(id)
+		if err != nil {
+			return err
+		}
+		var at time.Time
+		switch d, ok := SnoozePresets[preset]; {
+		case ok:
+			at = now.Add(d)
+		case preset == "tomorrow":
+			t, err := tx.repos.Todos.Get(n.TodoID)
+			if err != nil {
+				return err
+			}
+			local := now.In(t.Location())
+			at = time.Date(local.Year(), local.Month(), local.Day()+1, tomorrowHour, 0, 0, 0, local.Location())
+		case preset == "" && until != nil && until.After(now):
+			at = *until
+		default:
+			return ErrInvalidSnooze
+		}
+		ok, err := tx.repos.Notifications.Snooze(id, at)
+		if err == nil && !ok {
+			err = ErrNotificationClosed
+		}
+		return err
+	})
+}
+
+// DismissNotification stops a notification from being delivered again.
+func (s *Service) DismissNotification(id int) error {
+	return s.atomically(func(tx *Service) error {
+		if _, err := tx.repos.Notifications.Get(id); err != nil {
+			return err
+		}
+		ok, err := tx.repos.Notifications.Dismiss(id)
+		if err == nil && !ok {
+			err = ErrNotificationClosed
+		}
+		return err
+	})
+}
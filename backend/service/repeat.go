@@ -0,0 +1,125 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"todo/backend/db"
+	"todo/backend/rrule"
+)
+
+// ErrInvalidRepeat is returned when a todo's repeat rule is neither a legacy
+// keyword nor a valid RRULE, or its anchor is unknown.
+var ErrInvalidRepeat = errors.New("invalid repeat rule")
+
+// RepeatAnchor is what the next occurrence of a repeating todo is scheduled
+// from.
+type RepeatAnchor string
+
+const (
+	// AnchorDue follows the rule from the previous due date, skipping
+	// occurrences that are already past unless CatchUp is set.
+	AnchorDue RepeatAnchor = "due"
+	// AnchorCompletion follows the rule from the day the previous occurrence
+	// was completed, e.g. "water plants 3 days after I last did it".
+	AnchorCompletion RepeatAnchor = "completion"
+)
+
+// Repeat describes how a todo recurs.
+type Repeat struct {
+	Rule    string // RRULE or legacy keyword, see package rrule; "" for a one-off todo
+	Anchor  RepeatAnchor
+	CatchUp bool // with AnchorDue, schedule the next occurrence even if it is overdue
+}
+
+// normalizeRepeat validates a repeat rule and fills in the default anchor.
+// Legacy keywords are kept as they are; an RRULE with COUNT gets a DTSTART
+// pinned to the first due date, or the first reminder of a todo without
+// one, so later occurrences keep counting from the start of the series.
+func normalizeRepeat(repeat Repeat, when Schedule) (Repeat, error) {
+	switch repeat.Anchor {
+	case "":
+		repeat.Anchor = AnchorDue
+	case AnchorDue, AnchorCompletion:
+	default:
+		return repeat, fmt.Errorf("%w: anchor must be due or completion", ErrInvalidRepeat)
+	}
+	if repeat.Rule == "" || rrule.IsAlias(repeat.Rule) {
+		return repeat, nil
+	}
+	r, err := rrule.Parse(repeat.Rule)
+	if err != nil {
+		return repeat, fmt.Errorf("%w: %v", ErrInvalidRepeat, err)
+	}
+	start := when.DueDate
+	if start == nil {
+		start = when.RemindAt
+	}
+	if r.Count > 0 && r.DTStart.IsZero() && start != nil {
+		r.DTStart = *start
+		repeat.Rule = r.String()
+	}
+	return repeat, nil
+}
+
+// nextOccurrence computes the due and reminder times of the todo following
+// t in its series, which was completed at now. The rule is evaluated in the
+// todo's zone, so "daily at 9:00" stays at 9:00 local time across DST
+// changes. The reminder keeps its offset from the due date; a todo with only
+// a reminder repeats on the reminder itself. It reports false when the rule
+// has no further occurrences.
+func nextOccurrence(t *db.Todo, now time.Time) (dueDate, remindAt *time.Time, ok bool) {
+	r, err := rrule.Parse(t.Repeat)
+	if err != nil {
+		return nil, nil, false
+	}
+	loc := t.Location()
+	var base time.Time
+	switch {
+	case t.DueDate != nil:
+		base = t.DueDate.In(loc)
+	case t.RemindAt != nil:
+		base = t.RemindAt.In(loc)
+	default:
+		return nil, nil, true
+	}
+	if !r.DTStart.IsZero() {
+		r.DTStart = r.DTStart.In(loc)
+	}
+
+	var next time.Time
+	if RepeatAnchor(t.RepeatAnchor) == AnchorCompletion {
+		// Count occurrences by position, as each one restarts the rule
+		if r.Count > 0 && t.SeriesIndex+1 >= r.Count {
+			return nil, nil, false
+		}
+		r.DTStart, r.Count = time.Time{}, 0
+		// Keep the time of day of the previous occurrence
+		local := now.In(loc)
+		from := time.Date(local.Year(), local.Month(), local.Day(), base.Hour(), base.Minute(), base.Second(), 0, loc)
+		next, ok = r.After(from)
+	} else {
+		// Anchoring on the due date keeps e.g. "every Friday" on Fridays
+		// when skipping ahead from now
+		if r.DTStart.IsZero() {
+			r.DTStart = base
+		}
+		from := base
+		if !t.RepeatCatchUp && now.After(from) {
+			from = now
+		}
+		next, ok = r.After(from)
+	}
+	if !ok {
+		return nil, nil, false
+	}
+
+	if t.DueDate == nil {
+		return nil, &next, true
+	}
+	if t.RemindAt != nil {
+		remind := next.Add(t.RemindAt.Sub(*t.DueDate))
+		remindAt = &remind
+	}
+	return &next, remindAt, true
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"time"
+	"todo/backend/db"
+)
+
+// TodoRepository persists todos.
+type TodoRepository interface {
+	Create(t *db.Todo) (int64, error)
+	Get(id int) (*db.Todo, error)
+	List(q db.TodoQuery) ([]db.Todo, *db.TodoCursor, error)
+	ListBySeries(seriesID int) ([]db.Todo, error)
+	UpdateStatus(id int, completed bool, at time.Time) error
+	Update(t *db.Todo) error
+	Delete(id int) error
+}
+
+// ProjectRepository persists projects.
+type ProjectRepository interface {
+	Create(p *db.Project) (int64, error)
+	Get(id int) (*db.Project, error)
+	List() ([]db.Project, error)
+	Update(p *db.Project) error
+	Delete(id int) error
+}
+
+// SubtaskRepository persists subtasks.
+type SubtaskRepository interface {
+	Create(s *db.Subtask) (int64, error)
+	Get(id int) (*db.Subtask, error)
+	ListByTodo(todoID int) ([]db.Subtask, error)
+	ListByTodos(todoIDs []int) (map[int][]db.Subtask, error)
+	Update(s *db.Subtask) error
+	Delete(id int) error
+}
+
+// ReminderRepository persists the additional reminders of todos.
+type ReminderRepository interface {
+	Create(r *db.Reminder) (int64, error)
+	Get(id int) (*db.Reminder, error)
+	ListByTodo(todoID int) ([]db.Reminder, error)
+	ListByTodos(todoIDs []int) (map[int][]db.Reminder, error)
+	UpdateFireAt(r *db.Reminder) error
+	Delete(id int) error
+}
+
+// SeriesRepository persists the series linking repeating todos.
+type SeriesRepository interface {
+	Create(s *db.Series) (int64, error)
+	Get(id int) (*db.Series, error)
+	Update(s *db.Series) error
+}
+
+// NotificationRepository persists the reminder delivery queue.
+type NotificationRepository interface {
+	Enqueue(now time.Time) (int, error)
+	Expire(cutoff time.Time) (int, error)
+	ListPending(now time.Time, limit int) ([]db.Notification, error)
+	Claim(id int, now, leaseUntil time.Time) (bool, error)
+	MarkSent(id int, at time.Time) error
+	MarkFailed(id int, deliveryErr string, delivered []int, retryAt *time.Time) error
+	Snooze(id int, until time.Time) (bool, error)
+	Dismiss(id int) (bool, error)
+	ListActive(limit int) ([]db.Notification, error)
+	Get(id int) (*db.Notification, error)
+}
+
+// DigestRepository keeps track of the delivery of daily digests to the user
+// the repository is scoped to.
+type DigestRepository interface {
+	Claim(date string, now, leaseUntil time.Time) (*db.Digest, error)
+	MarkSent(date string, at time.Time) error
+	MarkFailed(date, deliveryErr string, delivered []int, retryAt *time.Time) error
+}
+
+// UserRepository persists the accounts of the HTTP API.
+type UserRepository interface {
+	Create(u *db.User) (int64, error)
+	Get(id int) (*db.User, error)
+	GetByName(username string) (*db.User, error)
+	List() ([]db.User, error)
+	UpdatePassword(id int, hash string) error
+	ClaimUnowned(id int) error
+}
+
+// TokenRepository persists session and API tokens.
+type TokenRepository interface {
+	Create(t *db.Token) (int64, error)
+	GetByHash(hash string) (*db.Token, error)
+	ListByUser(userID int, kind string) ([]db.Token, error)
+	Touch(id int, at time.Time) error
+	Delete(id, userID int) (bool, error)
+	DeleteByUser(userID int, kind string) error
+	DeleteExpired(now time.Time) (int, error)
+}
+
+// SearchRepository runs full-text queries over todos, subtasks and projects.
+type SearchRepository interface {
+	Search(match string, limit int) ([]db.SearchResult, error)
+}
+
+// TrashRepository lists, restores and purges deleted todos, projects and
+// subtasks. The other repositories delete by moving rows to the trash.
+type TrashRepository interface {
+	List() (*db.Trash, error)
+	RestoreTodo(id int) error
+	RestoreProject(id int) error
+	RestoreSubtask(id int) error
+	Purge(before time.Time) (int, error)
+}
+
+// CommandRepository records the changes made by commands and undoes and
+// redoes them, per session.
+type CommandRepository interface {
+	Begin(sessionID int, name string, resume int) (int, error)
+	End(id, keep int) error
+	Undo(sessionID int) (*db.Command, error)
+	Redo(sessionID int) (*db.Command, error)
+}
+
+// EventRepository reads the change history recorded for todos, subtasks
+// and projects.
+type EventRepository interface {
+	ListByTodo(todoID int) ([]db.Event, error)
+}
+
+// StatsRepository aggregates todos into the statistics of a period.
+type StatsRepository interface {
+	Totals(from, to, now time.Time) (*db.StatsTotals, error)
+	Completions(bounds []time.Time) ([]int, error)
+	ByProject(from, to time.Time) ([]db.ProjectStats, error)
+	ByTag(from, to time.Time) ([]db.Breakdown, error)
+	ByPriority(from, to time.Time) ([]db.Breakdown, error)
+}
+
+// Repositories bundles the storage backends a Service is built on.
+type Repositories struct {
+	Todos         TodoRepository
+	Projects      ProjectRepository
+	Subtasks      SubtaskRepository
+	Reminders     ReminderRepository
+	Series        SeriesRepository
+	Notifications NotificationRepository
+	Digests       DigestRepository
+	Search        SearchRepository
+	Trash         TrashRepository
+	Commands      CommandRepository
+	Events        EventRepository
+	Stats         StatsRepository
+	Users         UserRepository
+	Tokens        TokenRepository
+}
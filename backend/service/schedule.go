@@ -0,0 +1,45 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"todo/backend/db"
+
+	// Desktop builds can't rely on the OS shipping a zoneinfo database
+	_ "time/tzdata"
+)
+
+// ErrInvalidTimeZone is returned for a timezone that isn't a known IANA zone.
+var ErrInvalidTimeZone = errors.New("invalid timezone")
+
+// Schedule is when a todo is due and when to be reminded of it.
+type Schedule struct {
+	DueDate  *time.Time
+	RemindAt *time.Time
+	TimeZone string // IANA zone the todo lives in; "" means UTC
+	// AllDay makes DueDate a calendar date: only its year, month and day
+	// (in DueDate's own location) are kept, and it is due from midnight in
+	// TimeZone. The date stays put if the todo moves to another zone.
+	AllDay bool
+}
+
+// normalizeSchedule validates the zone and pins all-day due dates to
+// midnight in it.
+func normalizeSchedule(when Schedule) (Schedule, error) {
+	loc, err := time.LoadLocation(when.TimeZone)
+	if err != nil {
+		return when, fmt.Errorf("%w: %q", ErrInvalidTimeZone, when.TimeZone)
+	}
+	if when.AllDay && when.DueDate != nil {
+		y, m, d := when.DueDate.Date()
+		due := time.Date(y, m, d, 0, 0, 0, 0, loc)
+		when.DueDate = &due
+	}
+	return when, nil
+}
+
+func applySchedule(t *db.Todo, when Schedule) {
+	t.DueDate, t.RemindAt = when.DueDate, when.RemindAt
+	t.TimeZone, t.AllDay = when.TimeZone, when.AllDay
+}
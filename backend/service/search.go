@@ -0,0 +1,74 @@
+package service
+
+import (
+	"strings"
+	"todo/backend/db"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// Search runs a full-text query over todos, subtasks and projects.
+// Bare words match as prefixes ("mee" finds "meeting") and double-quoted
+// text matches as an exact phrase. All parts must match.
+func (s *Service) Search(query string, limit int) ([]db.SearchResult, error) {
+	match := buildMatchQuery(query)
+	if match == "" {
+		return []db.SearchResult{}, nil
+	}
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+	results, err := s.repos.Search.Search(match, limit)
+	if err != nil {
+		return nil, err
+	}
+	if results == nil {
+		results = []db.SearchResult{}
+	}
+	return results, nil
+}
+
+// buildMatchQuery turns user input into an FTS5 MATCH expression. Every term
+// is quoted so FTS5 operators and punctuation in the input are never
+// interpreted as query syntax.
+func buildMatchQuery(input string) string {
+	var parts []string
+	rest := strings.TrimSpace(input)
+	for rest != "" {
+		if rest[0] == '"' {
+			end := strings.IndexByte(rest[1:], '"')
+			var phrase string
+			if end < 0 {
+				phrase, rest = rest[1:], ""
+			} else {
+				phrase, rest = rest[1:end+1], rest[end+2:]
+			}
+			if phrase = strings.TrimSpace(phrase); phrase != "" {
+				parts = append(parts, quoteFTS(phrase))
+			}
+		} else {
+			end := strings.IndexAny(rest, " \t\n\"")
+			var word string
+			if end < 0 {
+				word, rest = rest, ""
+			} else {
+				word, rest = rest[:end], rest[end:]
+			}
+			if word = strings.TrimRight(word, "*"); word != "" {
+				parts = append(parts, quoteFTS(word)+"*")
+			}
+		}
+		rest = strings.TrimSpace(rest)
+	}
+	return strings.Join(parts, " ")
+}
+
+func quoteFTS(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"errors"
+	"time"
+	"todo/backend/db"
+)
+
+// EditScope selects which occurrences of a repeating todo an edit applies to.
+type EditScope string
+
+const (
+	// ScopeThis changes only the edited occurrence.
+	ScopeThis EditScope = "this"
+	// ScopeFuture changes the edited occurrence, every later occurrence that
+	// is still open, and the template new occurrences are created from.
+	ScopeFuture EditScope = "future"
+)
+
+// ErrInvalidScope is returned for an EditScope other than this or future.
+var ErrInvalidScope = errors.New("invalid scope: must be this or future")
+
+// SeriesHistory is a series together with all of its occurrences.
+type SeriesHistory struct {
+	Series      db.Series `json:"series"`
+	Occurrences []db.Todo `json:"occurrences"`
+}
+
+// GetSeries returns a series and its occurrences, oldest first.
+func (s *Service) GetSeries(id int) (*SeriesHistory, error) {
+	series, err := s.repos.Series.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	todos, err := s.repos.Todos.ListBySeries(id)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int, len(todos))
+	for i := range todos {
+		ids[i] = todos[i].ID
+	}
+	subtasks, err := s.repos.Subtasks.ListByTodos(ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range todos {
+		todos[i].Subtasks = subtasks[todos[i].ID]
+	}
+	if todos == nil {
+		todos = []db.Todo{}
+	}
+	return &SeriesHistory{Series: *series, Occurrences: todos}, nil
+}
+
+// UpdateSeriesSettings controls whether new occurrences of a series get a
+// copy of the previous occurrence's subtasks.
+func (s *Service) UpdateSeriesSettings(id int, copySubtasks bool) error {
+	return s.atomically(func(tx *Service) error {
+		series, err := tx.repos.Series.Get(id)
+		if err != nil {
+			return err
+		}
+		series.CopySubtasks = copySubtasks
+		return tx.repos.Series.Update(series)
+	})
+}
+
+// startSeries creates a series with t as its first occurrence.
+func (s *Service) startSeries(t *db.Todo) error {
+	id, err := s.repos.Series.Create(&db.Series{
+		Title:         t.Title,
+		Description:   t.Description,
+		Priority:      t.Priority,
+		Repeat:        t.Repeat,
+		RepeatAnchor:  t.RepeatAnchor,
+		RepeatCatchUp: t.RepeatCatchUp,
+		Tags:          t.Tags,
+		ProjectID:     t.ProjectID,
+	})
+	if err != nil {
+		return err
+	}
+	seriesID := int(id)
+	t.SeriesID, t.SeriesIndex = &seriesID, 0
+	return nil
+}
+
+// materializeNext creates the occurrence following the todo id. It does
+// nothing when the todo doesn't repeat, the series has ended, or a later
+// occurrence already exists, so completing a todo twice yields one successor.
+func (s *Service) materializeNext(id int) error {
+	t, err := s.repos.Todos.Get(id)
+	if err != nil || t.SeriesID == nil {
+		return nil
+	}
+	series, err := s.repos.Series.Get(*t.SeriesID)
+	if err != nil {
+		return err
+	}
+	if series.Repeat == "" {
+		return nil
+	}
+	occurrences, err := s.repos.Todos.ListBySeries(series.ID)
+	if err != nil {
+		return err
+	}
+	if n := len(occurrences); n > 0 && occurrences[n-1].SeriesIndex > t.SeriesIndex {
+		return nil
+	}
+
+	t.Repeat, t.RepeatAnchor, t.RepeatCatchUp = series.Repeat, series.RepeatAnchor, series.RepeatCatchUp
+	dueDate, remindAt, ok := nextOccurrence(t, time.Now())
+	if !ok {
+		return nil // the series has ended (COUNT or UNTIL)
+	}
+	next := &db.Todo{SeriesID: t.SeriesID, SeriesIndex: t.SeriesIndex + 1, OwnerID: t.OwnerID, UpdatedAt: time.Now()}
+	applySchedule(next, Schedule{DueDate: dueDate, RemindAt: remindAt, TimeZone: t.TimeZone, AllDay: t.AllDay})
+	applyTemplate(next, series)
+	nextID, err := s.repos.Todos.Create(next)
+	if err != nil {
+		return err
+	}
+	if err := s.copyReminders(t, int(nextID), dueDate); err != nil {
+		return err
+	}
+	if !series.CopySubtasks {
+		return nil
+	}
+
+	subtasks, err := s.repos.Subtasks.ListByTodo(t.ID)
+	if err != nil {
+		return err
+	}
+	for _, st := range subtasks {
+		if _, err := s.repos.Subtasks.Create(&db.Subtask{TodoID: int(nextID), Title: st.Title, UpdatedAt: next.UpdatedAt}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateInSeries saves an edited occurrence t of a series. The repeat rule
+// and anchor belong to the series, so a change to them reaches later
+// occurrences whatever the scope.
+func (s *Service) updateInSeries(t *db.Todo, scope EditScope) error {
+	series, err := s.repos.Series.Get(*t.SeriesID)
+	if err != nil {
+		return err
+	}
+	repeatChanged := t.Repeat != series.Repeat || t.RepeatAnchor != series.RepeatAnchor || t.RepeatCatchUp != series.RepeatCatchUp
+	if scope == ScopeFuture {
+		series.Title, series.Description, series.Priority = t.Title, t.Description, t.Priority
+		series.Tags, series.ProjectID = t.Tags, t.ProjectID
+	}
+	series.Repeat, series.RepeatAnchor, series.RepeatCatchUp = t.Repeat, t.RepeatAnchor, t.RepeatCatchUp
+	if err := s.repos.Series.Update(series); err != nil {
+		return err
+	}
+	if err := s.repos.Todos.Update(t); err != nil {
+		return err
+	}
+	if scope != ScopeFuture && !repeatChanged {
+		return nil
+	}
+
+	occurrences, err := s.repos.Todos.ListBySeries(series.ID)
+	if err != nil {
+		return err
+	}
+	for i := range occurrences {
+		o := &occurrences[i]
+		if o.SeriesIndex <= t.SeriesIndex || o.Completed {
+			continue
+		}
+		if scope == ScopeFuture {
+			applyTemplate(o, series)
+		}
+		o.Repeat, o.RepeatAnchor, o.RepeatCatchUp = series.Repeat, series.RepeatAnchor, series.RepeatCatchUp
+		o.UpdatedAt = t.UpdatedAt
+		if err := s.repos.Todos.Update(o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyTemplate(t *db.Todo, series *db.Series) {
+	t.Title, t.Description, t.Priority = series.Title, series.Description, series.Priority
+	t.Repeat, t.RepeatAnchor, t.RepeatCatchUp = series.Repeat, series.RepeatAnchor, series.RepeatCatchUp
+	t.Tags, t.ProjectID = series.Tags, series.ProjectID
+}
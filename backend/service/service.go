@@ -0,0 +1,128 @@
+package service
+
+import (
+	"database/sql"
+	"todo/backend/db"
+)
+
+// Service holds the business logic on top of a set of repositories.
+type Service struct {
+	repos Repositories
+
+	// runInTx, when set, runs fn against a Service whose repositories share a
+	// single transaction. Services without it run fn directly.
+	runInTx func(fn func(tx *Service) error) error
+
+	// notifier delivers reminders; the desktop notifier when nil
+	notifier Notifier
+
+	// forUser, when set, creates a Service over the same backend whose
+	// repositories only see one user's data
+	forUser func(userID int) *Service
+
+	// recorder, when set, records the changes of each transaction into a
+	// command that can be undone
+	recorder *Recorder
+}
+
+// New creates a Service on top of the given repositories.
+func New(repos Repositories) *Service {
+	return &Service{repos: repos}
+}
+
+// SetNotifier sets where reminders are delivered. It must be called before
+// the notification scheduler is started.
+func (s *Service) SetNotifier(n Notifier) {
+	s.notifier = n
+}
+
+// ForUser returns a Service that only sees and changes the todos and
+// projects of the given user. The Service it is called on sees everyone's.
+// It panics for a Service created with New, which has no way to tell users'
+// data apart.
+func (s *Service) ForUser(userID int) *Service {
+	if s.forUser == nil {
+		panic("service: ForUser needs a Service created by NewSQLite")
+	}
+	u := s.forUser(userID)
+	u.notifier = s.notifier
+	return u
+}
+
+// NewSQLite creates a Service backed by the SQLite stores in package db.
+func NewSQLite(conn *sql.DB) *Service {
+	return newSQLite(conn, 0)
+}
+
+// newSQLite creates a Service whose stores are limited to the user owner,
+// or see every user's rows when owner is 0.
+func newSQLite(conn *sql.DB, owner int) *Service {
+	s := New(sqliteRepositories(conn, owner))
+	s.runInTx = func(fn func(tx *Service) error) error {
+		tx, err := conn.Begin()
+		if err != nil {
+			return err
+		}
+		txSvc := New(sqliteRepositories(tx, owner))
+		txSvc.runInTx = savepoint(tx, txSvc)
+		if err := fn(txSvc); err != nil {
+			tx.Rollback()
+			return err
+		}
+		return tx.Commit()
+	}
+	s.forUser = func(userID int) *Service {
+		return newSQLite(conn, userID)
+	}
+	return s
+}
+
+// savepoint runs fn for atomically calls nested in the transaction q. fn
+// runs in a SAVEPOINT, so when it fails only its own changes are undone and
+// the caller may carry on with the transaction.
+func savepoint(q db.Querier, tx *Service) func(fn func(tx *Service) error) error {
+	return func(fn func(tx *Service) error) error {
+		if _, err := q.Exec("SAVEPOINT nested"); err != nil {
+			return err
+		}
+		if err := fn(tx); err != nil {
+			q.Exec("ROLLBACK TO nested")
+			q.Exec("RELEASE nested")
+			return err
+		}
+		_, err := q.Exec("RELEASE nested")
+		return err
+	}
+}
+
+func sqliteRepositories(q db.Querier, owner int) Repositories {
+	return Repositories{
+		Todos:         db.NewTodoStore(q, owner),
+		Projects:      db.NewProjectStore(q, owner),
+		Subtasks:      db.NewSubtaskStore(q, owner),
+		Reminders:     db.NewReminderStore(q, owner),
+		Series:        db.NewSeriesStore(q, owner),
+		Notifications: db.NewNotificationStore(q, owner),
+		Digests:       db.NewDigestStore(q, owner),
+		Search:        db.NewSearchStore(q, owner),
+		Trash:         db.NewTrashStore(q, owner),
+		Commands:      db.NewCommandStore(q),
+		Events:        db.NewEventStore(q, owner),
+		Stats:         db.NewStatsStore(q, owner),
+		Users:         db.NewUserStore(q),
+		Tokens:        db.NewTokenStore(q),
+	}
+}
+
+// atomically runs fn in a transaction when the backend supports one. Called
+// within a transaction, it runs fn in a savepoint of it. A recording
+// Service records what the transaction changes.
+func (s *Service) atomically(fn func(tx *Service) error) error {
+	if s.runInTx == nil {
+		return fn(s)
+	}
+	if s.recorder != nil {
+		return s.runInTx(s.recorder.record(fn))
+	}
+	return s.runInTx(fn)
+}
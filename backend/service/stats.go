@@ -0,0 +1,154 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"todo/backend/db"
+	"todo/backend/filter"
+)
+
+const (
+	defaultStatsDays = 30
+	maxStatsDays     = 366
+)
+
+// ErrInvalidRange is returned by GetStats for a period it can't cover.
+var ErrInvalidRange = errors.New("invalid range: from and to are days (YYYY-MM-DD, today or -Nd), from no later than to and at most 366 days before it")
+
+// Stats sums up how todos were created and completed over a period of
+// days, From to To inclusive, in TimeZone.
+type Stats struct {
+	From     string `json:"from"` // YYYY-MM-DD
+	To       string `json:"to"`
+	TimeZone string `json:"timezone"`
+	db.StatsTotals
+	OverdueRate float64           `json:"overdue_rate"` // Late / Due, 0 when nothing was due
+	Streak      Streak            `json:"streak"`
+	Days        []PeriodCount     `json:"days"`
+	Weeks       []PeriodCount     `json:"weeks"` // from Monday; the first and last may be partial
+	Projects    []db.ProjectStats `json:"projects"`
+	Tags        []db.Breakdown    `json:"tags"`
+	Priorities  []db.Breakdown    `json:"priorities"`
+}
+
+// PeriodCount is the number of todos completed in the day or week starting
+// on Date.
+type PeriodCount struct {
+	Date      string `json:"date"` // YYYY-MM-DD
+	Completed int    `json:"completed"`
+}
+
+// Streak counts runs of consecutive days with at least one completion in
+// a period. Current is the run reaching its last day, or the day before it
+// while that day has no completion yet.
+type Streak struct {
+	Current int `json:"current"`
+	Longest int `json:"longest"`
+}
+
+// GetStats returns the statistics of the days from to to, each anything
+// filter.ResolveDate accepts as a day, in the IANA zone tz ("" means UTC).
+// to defaults to today and from to 30 days ending on to.
+func (s *Service) GetStats(from, to, tz string) (*Stats, error) {
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidTimeZone, tz)
+	}
+	now := time.Now()
+	if to == "" {
+		to = "today"
+	}
+	last, end, err := filter.ResolveDate(to, now.In(loc))
+	if err != nil || last.Equal(end) {
+		return nil, fmt.Errorf("%w: to %q", ErrInvalidRange, to)
+	}
+	start := last.AddDate(0, 0, 1-defaultStatsDays)
+	if from != "" {
+		var fromEnd time.Time
+		start, fromEnd, err = filter.ResolveDate(from, now.In(loc))
+		if err != nil || start.Equal(fromEnd) {
+			return nil, fmt.Errorf("%w: from %q", ErrInvalidRange, from)
+		}
+	}
+	if start.After(last) || start.AddDate(0, 0, maxStatsDays).Before(end) {
+		return nil, ErrInvalidRange
+	}
+
+	st := &Stats{From: start.Format(time.DateOnly), To: last.Format(time.DateOnly), TimeZone: loc.String()}
+	totals, err := s.repos.Stats.Totals(start, end, now)
+	if err != nil {
+		return nil, err
+	}
+	st.StatsTotals = *totals
+	if st.Due > 0 {
+		st.OverdueRate = float64(st.Late) / float64(st.Due)
+	}
+
+	days := periodStarts(start, end, func(d time.Time) time.Time { return d.AddDate(0, 0, 1) })
+	if st.Days, err = s.completions(days); err != nil {
+		return nil, err
+	}
+	st.Streak = streak(st.Days)
+	weeks := periodStarts(start, end, func(d time.Time) time.Time {
+		return d.AddDate(0, 0, 7-(int(d.Weekday())+6)%7)
+	})
+	if st.Weeks, err = s.completions(weeks); err != nil {
+		return nil, err
+	}
+
+	if st.Projects, err = s.repos.Stats.ByProject(start, end); err != nil {
+		return nil, err
+	}
+	if st.Tags, err = s.repos.Stats.ByTag(start, end); err != nil {
+		return nil, err
+	}
+	if st.Priorities, err = s.repos.Stats.ByPriority(start, end); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// periodStarts returns the starts of the periods from start to end, each
+// beginning where next says the one before ends, followed by end.
+func periodStarts(start, end time.Time, next func(time.Time) time.Time) []time.Time {
+	var bounds []time.Time
+	for d := start; d.Before(end); d = next(d) {
+		bounds = append(bounds, d)
+	}
+	return append(bounds, end)
+}
+
+// completions counts the todos completed in the periods between bounds.
+func (s *Service) completions(bounds []time.Time) ([]PeriodCount, error) {
+	counts, err := s.repos.Stats.Completions(bounds)
+	if err != nil {
+		return nil, err
+	}
+	periods := make([]PeriodCount, len(counts))
+	for i, n := range counts {
+		periods[i] = PeriodCount{Date: bounds[i].Format(time.DateOnly), Completed: n}
+	}
+	return periods, nil
+}
+
+// streak finds the runs of days with completions.
+func streak(days []PeriodCount) Streak {
+	var st Streak
+	run := 0
+	for _, d := range days {
+		if d.Completed == 0 {
+			run = 0
+			continue
+		}
+		run++
+		st.Longest = max(st.Longest, run)
+	}
+	st.Current = run
+	if n := len(days); run == 0 && n > 1 {
+		for i := n - 2; i >= 0 && days[i].Completed > 0; i-- {
+			st.Current++
+		}
+	}
+	return st
+}
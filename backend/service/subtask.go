@@ -1,41 +1,79 @@
 package service
 
 import (
+	"time"
 	"todo/backend/db"
 )
 
-func CreateSubtask(todoID int, title string) (int64, error) {
-	res, err := db.DB.Exec("INSERT INTO subtasks (todo_id, title) VALUES (?, ?)", todoID, title)
-	if err != nil {
+// CreateSubtask adds a subtask to a todo. It returns sql.ErrNoRows if there
+// is no such todo.
+func (s *Service) CreateSubtask(todoID int, title string) (int64, error) {
+	if err := validateSubtask(title); err != nil {
 		return 0, err
 	}
-	return res.LastInsertId()
+	var id int64
+	err := s.atomically(func(tx *Service) error {
+		if _, err := tx.repos.Todos.Get(todoID); err != nil {
+			return err
+		}
+		var err error
+		id, err = tx.repos.Subtasks.Create(&db.Subtask{TodoID: todoID, Title: title, UpdatedAt: time.Now()})
+		return err
+	})
+	return id, err
+}
+
+func (s *Service) GetSubtasks(todoID int) ([]db.Subtask, error) {
+	return s.repos.Subtasks.ListByTodo(todoID)
+}
+
+func (s *Service) GetSubtask(id int) (*db.Subtask, error) {
+	return s.repos.Subtasks.Get(id)
 }
 
-func GetSubtasks(todoID int) ([]db.Subtask, error) {
-	rows, err := db.DB.Query("SELECT id, todo_id, title, completed, created_at FROM subtasks WHERE todo_id = ? ORDER BY created_at ASC", todoID)
-	if err != nil {
-		return nil, err
+func (s *Service) UpdateSubtask(id int, title string, completed bool) error {
+	if err := validateSubtask(title); err != nil {
+		return err
 	}
-	defer rows.Close()
+	return s.atomically(func(tx *Service) error {
+		return tx.repos.Subtasks.Update(&db.Subtask{ID: id, Title: title, Completed: completed, UpdatedAt: time.Now()})
+	})
+}
+
+// SubtaskPatch is a partial update of a subtask. Nil fields keep their
+// current value.
+type SubtaskPatch struct {
+	Title     *string
+	Completed *bool
+}
 
-	var subtasks []db.Subtask
-	for rows.Next() {
-		var s db.Subtask
-		if err := rows.Scan(&s.ID, &s.TodoID, &s.Title, &s.Completed, &s.CreatedAt); err != nil {
-			return nil, err
+// PatchSubtask applies a partial update to a subtask, merging it with the
+// current row in one transaction.
+func (s *Service) PatchSubtask(id int, p SubtaskPatch) error {
+	return s.atomically(func(tx *Service) error {
+		st, err := tx.repos.Subtasks.Get(id)
+		if err != nil {
+			return err
 		}
-		subtasks = append(subtasks, s)
-	}
-	return subtasks, nil
+		merge(&st.Title, p.Title)
+		merge(&st.Completed, p.Completed)
+		if err := validateSubtask(st.Title); err != nil {
+			return err
+		}
+		st.UpdatedAt = time.Now()
+		return tx.repos.Subtasks.Update(st)
+	})
 }
 
-func UpdateSubtask(id int, title string, completed bool) error {
-	_, err := db.DB.Exec("UPDATE subtasks SET title = ?, completed = ? WHERE id = ?", title, completed, id)
-	return err
+// DeleteSubtask moves a subtask to the trash.
+func (s *Service) DeleteSubtask(id int) error {
+	return s.atomically(func(tx *Service) error {
+		return tx.repos.Subtasks.Delete(id)
+	})
 }
 
-func DeleteSubtask(id int) error {
-	_, err := db.DB.Exec("DELETE FROM subtasks WHERE id = ?", id)
-	return err
+func validateSubtask(title string) error {
+	var v validation
+	v.title("title", title)
+	return v.err()
 }
@@ -1,122 +1,411 @@
 package service
 
 import (
+	"database/sql"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"slices"
 	"time"
 	"todo/backend/db"
+	"todo/backend/filter"
 )
 
-func CreateTodo(title, description, priority string, dueDate, remindAt *time.Time, repeat string, tags []string, projectID *int) (int64, error) {
+// ErrInvalidSort is returned by GetTodos for an unknown sort field or order.
+var ErrInvalidSort = errors.New("invalid sort")
+
+// ErrInvalidCursor is returned by GetTodosPage for a malformed cursor or one
+// that was issued for a different filter or sort.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ErrInvalidLimit is returned by GetTodosPage for a page size outside
+// 0..MaxPageSize.
+var ErrInvalidLimit = errors.New("invalid limit")
+
+// ErrInvalidProject is returned when a todo is put in a project that
+// doesn't exist or belongs to another user.
+var ErrInvalidProject = errors.New("invalid project")
+
+// MaxPageSize caps TodoListOptions.Limit.
+const MaxPageSize = 500
+
+// TodoListOptions controls which todos GetTodos returns and in what order.
+type TodoListOptions struct {
+	Filter string // expression in the filter language, see package filter
+	Sort   string // created (default), due, priority or title
+	Order  string // asc or desc; defaults to desc for created and priority, asc otherwise
+	Limit  int    // page size for GetTodosPage; 0 returns everything
+	Cursor string // NextCursor of the previous page
+}
+
+// TodoPage is one page of GetTodosPage results.
+type TodoPage struct {
+	Todos      []db.Todo `json:"items"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+// pageCursor is the opaque cursor handed to clients. It remembers a
+// fingerprint of the filter so it can't be replayed against another one.
+type pageCursor struct {
+	db.TodoCursor
+	Filter uint32 `json:"f"`
+}
+
+func filterFingerprint(expr string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(expr))
+	return h.Sum32()
+}
+
+func encodeCursor(c *db.TodoCursor, filterExpr string) string {
+	raw, _ := json.Marshal(pageCursor{TodoCursor: *c, Filter: filterFingerprint(filterExpr)})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(cursor, filterExpr string) (*db.TodoCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c pageCursor
+	if err := json.Unmarshal(raw, &c); err != nil || c.Filter != filterFingerprint(filterExpr) {
+		return nil, ErrInvalidCursor
+	}
+	return &c.TodoCursor, nil
+}
+
+func (s *Service) CreateTodo(title, description, priority string, when Schedule, repeat Repeat, tags []string, projectID *int) (int64, error) {
+	if err := validateTodo(title, priority); err != nil {
+		return 0, err
+	}
 	if priority == "" {
 		priority = "medium"
 	}
-	tagsJSON, _ := json.Marshal(tags)
-	if tags == nil {
-		tagsJSON = []byte("[]")
+	when, err := normalizeSchedule(when)
+	if err != nil {
+		return 0, err
 	}
-
-	res, err := db.DB.Exec("INSERT INTO todos (title, description, priority, due_date, remind_at, repeat, tags, project_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?)", title, description, priority, dueDate, remindAt, repeat, string(tagsJSON), projectID)
+	repeat, err = normalizeRepeat(repeat, when)
 	if err != nil {
 		return 0, err
 	}
-	return res.LastInsertId()
+	t := &db.Todo{
+		Title:         title,
+		Description:   description,
+		Priority:      priority,
+		Repeat:        repeat.Rule,
+		RepeatAnchor:  string(repeat.Anchor),
+		RepeatCatchUp: repeat.CatchUp,
+		Tags:          tags,
+		ProjectID:     projectID,
+		UpdatedAt:     time.Now(),
+	}
+	applySchedule(t, when)
+	var id int64
+	err = s.atomically(func(tx *Service) error {
+		if err := tx.checkProject(projectID); err != nil {
+			return err
+		}
+		if repeat.Rule != "" {
+			if err := tx.startSeries(t); err != nil {
+				return err
+			}
+		}
+		id, err = tx.repos.Todos.Create(t)
+		return err
+	})
+	return id, err
 }
 
-func GetTodos() ([]db.Todo, error) {
-	rows, err := db.DB.Query("SELECT id, title, description, completed, priority, due_date, remind_at, repeat, tags, project_id, created_at FROM todos ORDER BY created_at DESC")
+func (s *Service) GetTodos(opts TodoListOptions) ([]db.Todo, error) {
+	page, err := s.GetTodosPage(opts)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return page.Todos, nil
+}
 
-	var todos []db.Todo
-	for rows.Next() {
-		var t db.Todo
-		var tagsJSON string
-		if err := rows.Scan(&t.ID, &t.Title, &t.Description, &t.Completed, &t.Priority, &t.DueDate, &t.RemindAt, &t.Repeat, &tagsJSON, &t.ProjectID, &t.CreatedAt); err != nil {
+// GetTodosPage returns todos a page at a time using keyset pagination.
+// Subtasks and reminders for the whole page are loaded with one extra query
+// each.
+func (s *Service) GetTodosPage(opts TodoListOptions) (*TodoPage, error) {
+	q := db.TodoQuery{Sort: opts.Sort, Now: time.Now(), Limit: opts.Limit}
+	if q.Sort == "" {
+		q.Sort = "created"
+	}
+	if !slices.Contains(db.TodoSorts, q.Sort) {
+		return nil, fmt.Errorf("%w: unknown sort field %q", ErrInvalidSort, opts.Sort)
+	}
+	switch opts.Order {
+	case "":
+		q.Desc = q.Sort == "created" || q.Sort == "priority"
+	case "asc", "desc":
+		q.Desc = opts.Order == "desc"
+	default:
+		return nil, fmt.Errorf("%w: order must be asc or desc", ErrInvalidSort)
+	}
+	if q.Limit < 0 || q.Limit > MaxPageSize {
+		return nil, fmt.Errorf("%w: must be between 1 and %d", ErrInvalidLimit, MaxPageSize)
+	}
+	if opts.Filter != "" {
+		f, err := filter.Parse(opts.Filter)
+		if err != nil {
 			return nil, err
 		}
-		if tagsJSON != "" {
-			json.Unmarshal([]byte(tagsJSON), &t.Tags)
+		q.Filter = f
+	}
+	if opts.Cursor != "" {
+		after, err := decodeCursor(opts.Cursor, opts.Filter)
+		if err != nil {
+			return nil, err
 		}
-		if t.Tags == nil {
-			t.Tags = []string{}
+		q.After = after
+	}
+
+	todos, next, err := s.repos.Todos.List(q)
+	if errors.Is(err, db.ErrInvalidCursor) {
+		return nil, ErrInvalidCursor
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int, len(todos))
+	for i := range todos {
+		ids[i] = todos[i].ID
+	}
+	subtasks, err := s.repos.Subtasks.ListByTodos(ids)
+	if err != nil {
+		return nil, err
+	}
+	reminders, err := s.repos.Reminders.ListByTodos(ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range todos {
+		todos[i].Subtasks = subtasks[todos[i].ID]
+		todos[i].Reminders = reminders[todos[i].ID]
+	}
+
+	page := &TodoPage{Todos: todos}
+	if page.Todos == nil {
+		page.Todos = []db.Todo{}
+	}
+	if next != nil {
+		page.NextCursor = encodeCursor(next, opts.Filter)
+	}
+	return page, nil
+}
+
+// SavedFilters lists the built-in smart views usable as view:<name>.
+func (s *Service) SavedFilters() []filter.SavedFilter {
+	return filter.SavedFilters
+}
+
+func (s *Service) UpdateTodoStatus(id int, completed bool) error {
+	return s.atomically(func(tx *Service) error {
+		if err := tx.repos.Todos.UpdateStatus(id, completed, time.Now()); err != nil {
+			return err
 		}
-		
-		// Fetch subtasks for this todo
-		subtasks, err := GetSubtasks(t.ID)
-		if err == nil {
-			t.Subtasks = subtasks
-		} else {
-			t.Subtasks = []db.Subtask{}
+		if !completed {
+			return nil
 		}
 
-		todos = append(todos, t)
+		return tx.materializeNext(id)
+	})
+}
+
+// UpdateTodoDetails edits a todo. For an occurrence of a repeating todo,
+// scope decides whether later occurrences change too; it defaults to
+// ScopeFuture.
+func (s *Service) UpdateTodoDetails(id int, scope EditScope, title, description, priority string, when Schedule, repeat Repeat, tags []string, projectID *int) error {
+	scope, err := checkScope(scope)
+	if err != nil {
+		return err
+	}
+	if err := validateTodo(title, priority); err != nil {
+		return err
 	}
-	return todos, nil
+	if priority == "" {
+		priority = "medium"
+	}
+	return s.atomically(func(tx *Service) error {
+		t, err := tx.repos.Todos.Get(id)
+		if err != nil {
+			return err
+		}
+		t.Title, t.Description, t.Priority = title, description, priority
+		t.Tags, t.ProjectID = tags, projectID
+		return tx.saveDetails(t, scope, when, repeat)
+	})
 }
 
-func UpdateTodoStatus(id int, completed bool) error {
-	_, err := db.DB.Exec("UPDATE todos SET completed = ? WHERE id = ?", completed, id)
+// Nullable is a change to a field that can be cleared. The zero value
+// leaves the field alone; Set with a nil Value clears it.
+type Nullable[T any] struct {
+	Set   bool
+	Value *T
+}
+
+// TodoPatch is a partial update of a todo. Nil fields, and Nullable fields
+// that aren't Set, keep their current value.
+type TodoPatch struct {
+	Title         *string
+	Description   *string
+	Priority      *string
+	Completed     *bool
+	DueDate       Nullable[time.Time]
+	RemindAt      Nullable[time.Time]
+	TimeZone      *string
+	AllDay        *bool
+	Repeat        *string
+	RepeatAnchor  *RepeatAnchor
+	RepeatCatchUp *bool
+	Tags          []string // nil keeps the tags; empty removes them all
+	ProjectID     Nullable[int]
+}
+
+// details reports whether p changes anything but the completion status.
+func (p TodoPatch) details() bool {
+	return p.Title != nil || p.Description != nil || p.Priority != nil ||
+		p.DueDate.Set || p.RemindAt.Set || p.TimeZone != nil || p.AllDay != nil ||
+		p.Repeat != nil || p.RepeatAnchor != nil || p.RepeatCatchUp != nil ||
+		p.Tags != nil || p.ProjectID.Set
+}
+
+// PatchTodo applies a partial update to a todo, merging it with the current
+// row in one transaction. Completing a repeating todo creates its next
+// occurrence, as UpdateTodoStatus does. scope is as for UpdateTodoDetails.
+func (s *Service) PatchTodo(id int, scope EditScope, p TodoPatch) error {
+	scope, err := checkScope(scope)
 	if err != nil {
 		return err
 	}
+	return s.atomically(func(tx *Service) error {
+		t, err := tx.repos.Todos.Get(id)
+		if err != nil {
+			return err
+		}
 
-	if completed {
-		// Check for repeat
-		var t db.Todo
-		var tagsJSON string
-		err := db.DB.QueryRow("SELECT title, description, priority, due_date, remind_at, repeat, tags, project_id FROM todos WHERE id = ?", id).Scan(
-			&t.Title, &t.Description, &t.Priority, &t.DueDate, &t.RemindAt, &t.Repeat, &tagsJSON, &t.ProjectID,
-		)
-		if err == nil && t.Repeat != "" {
-			// Calculate next dates
-			nextDueDate := calculateNextDate(t.DueDate, t.Repeat)
-			nextRemindAt := calculateNextDate(t.RemindAt, t.Repeat)
-			
-			if tagsJSON != "" {
-				json.Unmarshal([]byte(tagsJSON), &t.Tags)
+		if p.details() {
+			// All-day dates are kept as a calendar day in the todo's zone,
+			// which must survive a change of zone.
+			when := Schedule{DueDate: t.DueDate, RemindAt: t.RemindAt, TimeZone: t.TimeZone, AllDay: t.AllDay}
+			if t.AllDay && t.DueDate != nil {
+				due := t.DueDate.In(t.Location())
+				when.DueDate = &due
+			}
+			repeat := Repeat{Rule: t.Repeat, Anchor: RepeatAnchor(t.RepeatAnchor), CatchUp: t.RepeatCatchUp}
+
+			merge(&t.Title, p.Title)
+			merge(&t.Description, p.Description)
+			merge(&t.Priority, p.Priority)
+			mergeNullable(&when.DueDate, p.DueDate)
+			mergeNullable(&when.RemindAt, p.RemindAt)
+			merge(&when.TimeZone, p.TimeZone)
+			merge(&when.AllDay, p.AllDay)
+			merge(&repeat.Rule, p.Repeat)
+			merge(&repeat.Anchor, p.RepeatAnchor)
+			merge(&repeat.CatchUp, p.RepeatCatchUp)
+			if p.Tags != nil {
+				t.Tags = p.Tags
+			}
+			mergeNullable(&t.ProjectID, p.ProjectID)
+
+			if err := validateTodo(t.Title, t.Priority); err != nil {
+				return err
+			}
+			if t.Priority == "" {
+				t.Priority = "medium"
+			}
+			if err := tx.saveDetails(t, scope, when, repeat); err != nil {
+				return err
 			}
-			
-			CreateTodo(t.Title, t.Description, t.Priority, nextDueDate, nextRemindAt, t.Repeat, t.Tags, t.ProjectID)
 		}
+
+		if p.Completed != nil && *p.Completed != t.Completed {
+			return tx.UpdateTodoStatus(id, *p.Completed)
+		}
+		return nil
+	})
+}
+
+func merge[T any](field *T, patch *T) {
+	if patch != nil {
+		*field = *patch
 	}
+}
 
-	return nil
+func mergeNullable[T any](field **T, patch Nullable[T]) {
+	if patch.Set {
+		*field = patch.Value
+	}
 }
 
-func calculateNextDate(current *time.Time, repeat string) *time.Time {
-	if current == nil {
-		return nil
+// checkScope defaults an empty scope to ScopeFuture.
+func checkScope(scope EditScope) (EditScope, error) {
+	switch scope {
+	case "":
+		return ScopeFuture, nil
+	case ScopeThis, ScopeFuture:
+		return scope, nil
+	default:
+		return scope, ErrInvalidScope
 	}
-	t := *current
-	switch repeat {
-	case "daily":
-		t = t.AddDate(0, 0, 1)
-	case "weekly":
-		t = t.AddDate(0, 0, 7)
-	case "monthly":
-		t = t.AddDate(0, 1, 0)
-	case "weekdays":
-		// +1 day, then check if Sat/Sun
-		t = t.AddDate(0, 0, 1)
-		for t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
-			t = t.AddDate(0, 0, 1)
+}
+
+// saveDetails validates and applies the schedule and repeat rule to t, whose
+// other fields are already set, and saves it along with its series and
+// reminders. It must run in a transaction.
+func (s *Service) saveDetails(t *db.Todo, scope EditScope, when Schedule, repeat Repeat) error {
+	when, err := normalizeSchedule(when)
+	if err != nil {
+		return err
+	}
+	repeat, err = normalizeRepeat(repeat, when)
+	if err != nil {
+		return err
+	}
+	if err := s.checkProject(t.ProjectID); err != nil {
+		return err
+	}
+	applySchedule(t, when)
+	t.Repeat, t.RepeatAnchor, t.RepeatCatchUp = repeat.Rule, string(repeat.Anchor), repeat.CatchUp
+	t.UpdatedAt = time.Now()
+
+	if t.SeriesID != nil {
+		err = s.updateInSeries(t, scope)
+	} else {
+		if repeat.Rule != "" {
+			if err := s.startSeries(t); err != nil {
+				return err
+			}
 		}
+		err = s.repos.Todos.Update(t)
 	}
-	return &t
+	if err != nil {
+		return err
+	}
+	return s.rescheduleReminders(t)
 }
 
-func UpdateTodoDetails(id int, title, description, priority string, dueDate, remindAt *time.Time, repeat string, tags []string, projectID *int) error {
-	tagsJSON, _ := json.Marshal(tags)
-	if tags == nil {
-		tagsJSON = []byte("[]")
-	}
-	_, err := db.DB.Exec("UPDATE todos SET title = ?, description = ?, priority = ?, due_date = ?, remind_at = ?, repeat = ?, tags = ?, project_id = ? WHERE id = ?", title, description, priority, dueDate, remindAt, repeat, string(tagsJSON), projectID, id)
-	return err
+// DeleteTodo moves a todo and its subtasks to the trash.
+func (s *Service) DeleteTodo(id int) error {
+	return s.atomically(func(tx *Service) error {
+		return tx.repos.Todos.Delete(id)
+	})
 }
 
-func DeleteTodo(id int) error {
-	_, err := db.DB.Exec("DELETE FROM todos WHERE id = ?", id)
+// checkProject makes sure a todo may be put in the project projectID.
+func (s *Service) checkProject(projectID *int) error {
+	if projectID == nil {
+		return nil
+	}
+	_, err := s.repos.Projects.Get(*projectID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: no project %d", ErrInvalidProject, *projectID)
+	}
 	return err
 }
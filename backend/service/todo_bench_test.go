@@ -0,0 +1,92 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+	"todo/backend/db"
+)
+
+const benchTodos = 5000
+
+// seedBenchDB creates a database with benchTodos todos, each with two subtasks.
+func seedBenchDB(b *testing.B) *Service {
+	b.Helper()
+	conn, err := db.Open(":memory:")
+	if err != nil {
+		b.Fatalf("Failed to open in-memory database: %v", err)
+	}
+	b.Cleanup(func() { conn.Close() })
+	if _, err := db.MigrateUp(conn, 0); err != nil {
+		b.Fatalf("Failed to migrate database: %v", err)
+	}
+	svc := NewSQLite(conn)
+
+	due := time.Now()
+	err = svc.atomically(func(tx *Service) error {
+		for i := 0; i < benchTodos; i++ {
+			id, err := tx.CreateTodo(fmt.Sprintf("Todo %d", i), "seeded", "medium", Schedule{DueDate: &due}, Repeat{}, []string{"bench"}, nil)
+			if err != nil {
+				return err
+			}
+			for j := 0; j < 2; j++ {
+				if _, err := tx.CreateSubtask(int(id), fmt.Sprintf("Subtask %d", j)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.Fatalf("Failed to seed database: %v", err)
+	}
+	return svc
+}
+
+// BenchmarkGetTodosNPlusOne reproduces the old GetTodos, which issued one
+// subtask query per todo, as a baseline for BenchmarkGetTodos.
+func BenchmarkGetTodosNPlusOne(b *testing.B) {
+	svc := seedBenchDB(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		todos, _, err := svc.repos.Todos.List(db.TodoQuery{Sort: "created", Desc: true})
+		if err != nil {
+			b.Fatal(err)
+		}
+		for j := range todos {
+			if todos[j].Subtasks, err = svc.repos.Subtasks.ListByTodo(todos[j].ID); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkGetTodos(b *testing.B) {
+	svc := seedBenchDB(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := svc.GetTodos(TodoListOptions{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkGetTodosPage fetches a page from the middle of the list, which
+// costs the same as the first page thanks to keyset pagination.
+func BenchmarkGetTodosPage(b *testing.B) {
+	svc := seedBenchDB(b)
+	cursor := ""
+	for i := 0; i < benchTodos/2/50; i++ {
+		page, err := svc.GetTodosPage(TodoListOptions{Limit: 50, Cursor: cursor})
+		if err != nil {
+			b.Fatal(err)
+		}
+		cursor = page.NextCursor
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := svc.GetTodosPage(TodoListOptions{Limit: 50, Cursor: cursor}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
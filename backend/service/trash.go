@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+	"todo/backend/db"
+)
+
+// DefaultTrashRetention is how long deleted todos, projects and subtasks
+// stay in the trash before they are purged.
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+// trashPurgeInterval is how often the purger looks for expired items.
+const trashPurgeInterval = time.Hour
+
+// GetTrash returns the deleted projects, todos and subtasks that can still
+// be restored.
+func (s *Service) GetTrash() (*db.Trash, error) {
+	trash, err := s.repos.Trash.List()
+	if err != nil {
+		return nil, err
+	}
+	if trash.Projects == nil {
+		trash.Projects = []db.Project{}
+	}
+	if trash.Todos == nil {
+		trash.Todos = []db.Todo{}
+	}
+	if trash.Subtasks == nil {
+		trash.Subtasks = []db.Subtask{}
+	}
+	return trash, nil
+}
+
+// RestoreTodo takes a todo and its subtasks out of the trash. It returns
+// sql.ErrNoRows if the todo isn't in the trash.
+func (s *Service) RestoreTodo(id int) error {
+	return s.atomically(func(tx *Service) error {
+		return tx.repos.Trash.RestoreTodo(id)
+	})
+}
+
+// RestoreProject takes a project out of the trash, with the todos that were
+// deleted along with it.
+func (s *Service) RestoreProject(id int) error {
+	return s.atomically(func(tx *Service) error {
+		return tx.repos.Trash.RestoreProject(id)
+	})
+}
+
+// RestoreSubtask takes a subtask out of the trash. Its todo has to be
+// restored first if it was deleted too.
+func (s *Service) RestoreSubtask(id int) error {
+	return s.atomically(func(tx *Service) error {
+		return tx.repos.Trash.RestoreSubtask(id)
+	})
+}
+
+// PurgeTrash deletes for good what was moved to the trash before the given
+// time and returns how many items it deleted.
+func (s *Service) PurgeTrash(before time.Time) (int, error) {
+	var n int
+	err := s.atomically(func(tx *Service) error {
+		var err error
+		n, err = tx.repos.Trash.Purge(before)
+		return err
+	})
+	return n, err
+}
+
+// StartTrashPurger runs the purger in the background for the life of the
+// process.
+func (s *Service) StartTrashPurger(retention time.Duration) {
+	go s.RunTrashPurger(context.Background(), retention)
+}
+
+// RunTrashPurger empties the trash of items older than retention, on start
+// and then every hour, until ctx is done.
+func (s *Service) RunTrashPurger(ctx context.Context, retention time.Duration) {
+	ticker := time.NewTicker(trashPurgeInterval)
+	defer ticker.Stop()
+
+	now := time.Now()
+	for {
+		n, err := s.PurgeTrash(now.Add(-retention))
+		if err != nil {
+			log.Println("Error purging trash:", err)
+		} else if n > 0 {
+			log.Printf("Purged %d items from the trash", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case now = <-ticker.C:
+		}
+	}
+}
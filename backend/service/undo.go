@@ -0,0 +1,83 @@
+package service
+
+import (
+	"errors"
+	"todo/backend/db"
+)
+
+// MaxUndo is how many of its latest commands a session can undo.
+const MaxUndo = 100
+
+// ErrUndoConflict is returned when a command can't be undone or redone
+// because what it changed has been changed since, e.g. by another session.
+var ErrUndoConflict = errors.New("what the command changed has been changed since")
+
+// A Recorder gathers the changes made through a recording Service into one
+// command of a session, which Undo reverts as a whole. Name says what the
+// command does, e.g. "Create a todo".
+type Recorder struct {
+	SessionID int
+	Name      string
+
+	id int // of the command, once a transaction has been recorded
+}
+
+// Recording returns a copy of s that records every change it makes with
+// rec. Changes made in several transactions, e.g. editing a todo and then
+// completing it, go into the same command. Only SQLite-backed Services
+// record anything.
+func (s *Service) Recording(rec *Recorder) *Service {
+	r := *s
+	r.recorder = rec
+	return &r
+}
+
+// record wraps the transaction fn so its changes are recorded in rec's
+// command.
+func (rec *Recorder) record(fn func(tx *Service) error) func(tx *Service) error {
+	return func(tx *Service) error {
+		id, err := tx.repos.Commands.Begin(rec.SessionID, rec.Name, rec.id)
+		if err != nil {
+			return err
+		}
+		if err := fn(tx); err != nil {
+			return err
+		}
+		if err := tx.repos.Commands.End(id, MaxUndo); err != nil {
+			return err
+		}
+		rec.id = id
+		return nil
+	}
+}
+
+// Undo reverts the latest command of a session that hasn't been undone,
+// including whatever it did on the side, such as the next occurrence a
+// completed repeating todo spawned. It returns sql.ErrNoRows if there is
+// nothing to undo.
+func (s *Service) Undo(sessionID int) (*db.Command, error) {
+	return s.step(CommandRepository.Undo, sessionID)
+}
+
+// Redo makes again the command of a session that was undone last. Any new
+// command clears what can be redone. It returns sql.ErrNoRows if there is
+// nothing to redo.
+func (s *Service) Redo(sessionID int) (*db.Command, error) {
+	return s.step(CommandRepository.Redo, sessionID)
+}
+
+// step runs Undo or Redo in a transaction that isn't itself recorded.
+func (s *Service) step(fn func(CommandRepository, int) (*db.Command, error), sessionID int) (*db.Command, error) {
+	plain := *s
+	plain.recorder = nil
+	var c *db.Command
+	err := plain.atomically(func(tx *Service) error {
+		var err error
+		c, err = fn(tx.repos.Commands, sessionID)
+		return err
+	})
+	if errors.Is(err, db.ErrCommandConflict) {
+		return nil, ErrUndoConflict
+	}
+	return c, err
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"slices"
+	"strings"
+	"todo/backend/filter"
+	"unicode/utf8"
+)
+
+// MaxTitleLength is the longest title, in characters, a todo, subtask or
+// project name may have.
+const MaxTitleLength = 500
+
+// FieldError says what is wrong with one field of a request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError is returned when fields of a request are invalid. It
+// lists every problem, not just the first.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Field + " " + f.Message
+	}
+	return "invalid request: " + strings.Join(msgs, "; ")
+}
+
+// validation collects the problems found while checking a request.
+type validation []FieldError
+
+// check records message against field unless ok.
+func (v *validation) check(ok bool, field, message string) {
+	if !ok {
+		*v = append(*v, FieldError{Field: field, Message: message})
+	}
+}
+
+// title checks a required, length-limited text field.
+func (v *validation) title(field, value string) {
+	v.check(strings.TrimSpace(value) != "", field, "is required")
+	v.check(utf8.RuneCountInString(value) <= MaxTitleLength, field, "is too long")
+}
+
+// err returns a *ValidationError for the problems found, or nil.
+func (v validation) err() error {
+	if len(v) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: v}
+}
+
+func validateTodo(title, priority string) error {
+	var v validation
+	v.title("title", title)
+	v.check(priority == "" || slices.Contains(filter.Priorities, priority), "priority", "must be low, medium or high")
+	return v.err()
+}
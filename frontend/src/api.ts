@@ -0,0 +1,16 @@
+import axios from 'axios'
+import { APISecret, APIURL } from './wailsjs/go/main/App'
+
+// connectAPI points axios at the API server. In the desktop app that is the
+// embedded server, whose loopback port and per-launch secret come from the
+// Go bindings. Outside Wails, e.g. `npm run dev` in a browser, it expects a
+// headless server on localhost:8081.
+export async function connectAPI() {
+  if (!('go' in window)) {
+    axios.defaults.baseURL = 'http://localhost:8081'
+    return
+  }
+  const [url, secret] = await Promise.all([APIURL(), APISecret()])
+  axios.defaults.baseURL = url
+  axios.defaults.headers.common['X-Todo-Secret'] = secret
+}
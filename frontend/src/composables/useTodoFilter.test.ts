@@ -28,6 +28,8 @@ describe('useTodoFilter', () => {
     project_id: null,
     subtasks: [],
     created_at: new Date().toISOString(),
+    updated_at: new Date().toISOString(),
+    completed_at: null,
     ...overrides
   })
 
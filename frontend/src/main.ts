@@ -3,6 +3,7 @@ import { createPinia } from 'pinia'
 import { createI18n } from 'vue-i18n'
 import './style.css'
 import App from './App.vue'
+import { connectAPI } from './api'
 
 import en from './locales/en.json'
 import zh from './locales/zh.json'
@@ -22,4 +23,5 @@ const app = createApp(App)
 
 app.use(pinia)
 app.use(i18n)
-app.mount('#app')
+// Mount once the API is reachable, since components fetch on mount
+connectAPI().finally(() => app.mount('#app'))
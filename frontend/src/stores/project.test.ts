@@ -22,7 +22,7 @@ describe('Project Store', () => {
 
     await store.fetchProjects()
 
-    expect(axios.get).toHaveBeenCalledWith('http://localhost:8081/api/projects')
+    expect(axios.get).toHaveBeenCalledWith('/api/projects')
     expect(store.projects).toEqual(mockProjects)
   })
 
@@ -50,7 +50,7 @@ describe('Project Store', () => {
 
     await store.updateProject(1, { name: 'Updated' })
 
-    expect(axios.put).toHaveBeenCalledWith('http://localhost:8081/api/projects/1', { name: 'Updated' })
+    expect(axios.put).toHaveBeenCalledWith('/api/projects/1', { name: 'Updated' })
   })
 
   it('deletes a project successfully', async () => {
@@ -63,6 +63,6 @@ describe('Project Store', () => {
 
     await store.deleteProject(1)
 
-    expect(axios.delete).toHaveBeenCalledWith('http://localhost:8081/api/projects/1')
+    expect(axios.delete).toHaveBeenCalledWith('/api/projects/1')
   })
 })
@@ -8,11 +8,13 @@ export interface Project {
   description: string
   color: string
   created_at: string
+  updated_at: string
+  deleted_at?: string // only in the trash
 }
 
 export const useProjectStore = defineStore('project', () => {
   const projects = ref<Project[]>([])
-  const API_URL = 'http://localhost:8081/api/projects'
+  const API_URL = '/api/projects'
 
   const fetchProjects = async () => {
     try {
@@ -0,0 +1,38 @@
+import { setActivePinia, createPinia } from 'pinia'
+import { describe, it, expect, beforeEach, vi } from 'vitest'
+import { useStatsStore } from './stats'
+import axios from 'axios'
+
+vi.mock('axios')
+
+describe('Stats Store', () => {
+  beforeEach(() => {
+    setActivePinia(createPinia())
+    vi.clearAllMocks()
+  })
+
+  it('fetches statistics in the browser zone', async () => {
+    const store = useStatsStore()
+    const stats = { from: '2026-10-01', to: '2026-10-07', timezone: 'UTC', completed: 4, days: [], weeks: [], projects: [], tags: [], priorities: [] }
+
+    // @ts-expect-error -- Mocking axios
+    axios.get.mockResolvedValue({ data: stats })
+
+    await store.fetchStats('2026-10-01', '2026-10-07')
+
+    const tz = Intl.DateTimeFormat().resolvedOptions().timeZone
+    expect(axios.get).toHaveBeenCalledWith('/api/stats', { params: { from: '2026-10-01', to: '2026-10-07', tz } })
+    expect(store.stats).toEqual(stats)
+  })
+
+  it('keeps the last statistics when the request fails', async () => {
+    const store = useStatsStore()
+
+    // @ts-expect-error -- Mocking axios
+    axios.get.mockRejectedValue({ response: { status: 400 } })
+
+    await store.fetchStats('someday')
+
+    expect(store.stats).toBeNull()
+  })
+})
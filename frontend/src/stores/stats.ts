@@ -0,0 +1,60 @@
+import { defineStore } from 'pinia'
+import axios from 'axios'
+import { ref } from 'vue'
+
+export interface PeriodCount {
+  date: string
+  completed: number
+}
+
+export interface ProjectStats {
+  project_id: number | null
+  name: string
+  created: number
+  completed: number
+  throughput: number // completed per week
+  lead_time_days: number | null
+}
+
+export interface Breakdown {
+  name: string
+  created: number
+  completed: number
+}
+
+export interface Stats {
+  from: string
+  to: string
+  timezone: string
+  created: number
+  completed: number
+  open: number
+  overdue: number
+  due: number
+  late: number
+  overdue_rate: number
+  streak: { current: number, longest: number }
+  days: PeriodCount[]
+  weeks: PeriodCount[]
+  projects: ProjectStats[]
+  tags: Breakdown[]
+  priorities: Breakdown[]
+}
+
+export const useStatsStore = defineStore('stats', () => {
+  const stats = ref<Stats | null>(null)
+
+  // Days are counted in the browser's zone; from and to default to the 30
+  // days up to today
+  const fetchStats = async (from = '', to = '') => {
+    try {
+      const tz = Intl.DateTimeFormat().resolvedOptions().timeZone
+      const response = await axios.get<Stats>('/api/stats', { params: { from: from || undefined, to: to || undefined, tz } })
+      stats.value = response.data
+    } catch (error) {
+      console.error('Failed to fetch statistics:', error)
+    }
+  }
+
+  return { stats, fetchStats }
+})
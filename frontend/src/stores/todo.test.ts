@@ -22,7 +22,7 @@ describe('Todo Store', () => {
 
     await store.fetchTodos()
 
-    expect(axios.get).toHaveBeenCalledWith('http://localhost:8081/api/todos')
+    expect(axios.get).toHaveBeenCalledWith('/api/todos')
     expect(store.todos).toEqual(mockTodos)
   })
 
@@ -44,13 +44,41 @@ describe('Todo Store', () => {
     const store = useTodoStore()
     
     // @ts-expect-error -- Mocking axios
-    axios.put.mockResolvedValue({})
+    axios.patch.mockResolvedValue({})
     // @ts-expect-error -- Mocking axios
     axios.get.mockResolvedValue({ data: [] })
 
     await store.updateTodo(1, { completed: true })
 
-    expect(axios.put).toHaveBeenCalledWith('http://localhost:8081/api/todos/1', { completed: true })
+    expect(axios.patch).toHaveBeenCalledWith('/api/todos/1', { completed: true })
+  })
+
+  it('updates several todos in one batch', async () => {
+    const store = useTodoStore()
+    const results = [{ id: 1, ok: true }, { id: 2, ok: true }]
+
+    // @ts-expect-error -- Mocking axios
+    axios.post.mockResolvedValue({ data: { results } })
+    // @ts-expect-error -- Mocking axios
+    axios.get.mockResolvedValue({ data: [] })
+
+    const operations = [{ op: 'complete' as const, id: 1 }, { op: 'add_tag' as const, id: 2, tag: 'work' }]
+    expect(await store.batchTodos(operations, true)).toEqual(results)
+    expect(axios.post).toHaveBeenCalledWith('/api/todos/batch', { operations, atomic: true })
+  })
+
+  it('fetches the history of a todo', async () => {
+    const store = useTodoStore()
+    const history = [
+      { id: 1, kind: 'todo', ref_id: 1, todo_id: 1, user_id: 2, username: 'alice', action: 'created', created_at: '2026-10-17 09:00:00' },
+      { id: 2, kind: 'todo', ref_id: 1, todo_id: 1, user_id: 2, username: 'alice', action: 'updated', field: 'priority', old: 'low', new: 'high', created_at: '2026-10-17 09:05:00' }
+    ]
+
+    // @ts-expect-error -- Mocking axios
+    axios.get.mockResolvedValue({ data: history })
+
+    expect(await store.fetchHistory(1)).toEqual(history)
+    expect(axios.get).toHaveBeenCalledWith('/api/todos/1/history')
   })
 
   it('deletes a todo successfully', async () => {
@@ -63,6 +91,6 @@ describe('Todo Store', () => {
 
     await store.deleteTodo(1)
 
-    expect(axios.delete).toHaveBeenCalledWith('http://localhost:8081/api/todos/1')
+    expect(axios.delete).toHaveBeenCalledWith('/api/todos/1')
   })
 })
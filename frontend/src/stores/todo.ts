@@ -8,6 +8,9 @@ export interface Subtask {
   title: string
   completed: boolean
   created_at: string
+  updated_at: string
+  completed_at: string | null
+  deleted_at?: string // only in the trash
 }
 
 export interface Todo {
@@ -23,6 +26,34 @@ export interface Todo {
   project_id: number | null
   subtasks: Subtask[]
   created_at: string
+  updated_at: string
+  completed_at: string | null
+  deleted_at?: string // only in the trash
+}
+
+// TodoEvent is one entry of the history of a todo: its creation or that of
+// a subtask, or a change to one field
+export interface TodoEvent {
+  id: number
+  kind: 'todo' | 'subtask' | 'project'
+  ref_id: number
+  todo_id: number | null
+  user_id: number | null
+  username?: string
+  action: 'created' | 'updated'
+  field?: string
+  old?: unknown
+  new?: unknown
+  created_at: string
+}
+
+export interface BatchOperation {
+  op: 'complete' | 'reopen' | 'delete' | 'move' | 'add_tag' | 'remove_tag' | 'set_priority' | 'shift_due'
+  id: number
+  project_id?: number | null
+  tag?: string
+  priority?: 'high' | 'medium' | 'low'
+  days?: number
 }
 
 export const useTodoStore = defineStore('todo', () => {
@@ -40,9 +71,9 @@ export const useTodoStore = defineStore('todo', () => {
     return Array.from(tags).sort()
   })
 
-  // Assume API is running on localhost:8081 (from main.go)
-  const API_URL = 'http://localhost:8081/api/todos'
-  const SUBTASK_API_URL = 'http://localhost:8081/api/subtasks'
+  // Relative to the server connectAPI set up
+  const API_URL = '/api/todos'
+  const SUBTASK_API_URL = '/api/subtasks'
 
   const fetchTodos = async () => {
     try {
@@ -69,11 +100,14 @@ export const useTodoStore = defineStore('todo', () => {
 
   const updateTodo = async (id: number, updates: Partial<Todo>) => {
     try {
-        // Ensure due_date is null if empty string
+        // Ensure due_date and remind_at are null if empty string, which clears them
         if (updates.due_date === '') {
             updates.due_date = null
         }
-      await axios.put(`${API_URL}/${id}`, updates)
+        if (updates.remind_at === '') {
+            updates.remind_at = null
+        }
+      await axios.patch(`${API_URL}/${id}`, updates)
       await fetchTodos()
     } catch (error) {
       console.error('Failed to update todo:', error)
@@ -100,7 +134,7 @@ export const useTodoStore = defineStore('todo', () => {
 
   const updateSubtask = async (id: number, updates: Partial<Subtask>) => {
     try {
-      await axios.put(`${SUBTASK_API_URL}/${id}`, updates)
+      await axios.patch(`${SUBTASK_API_URL}/${id}`, updates)
       await fetchTodos()
     } catch (error) {
       console.error('Failed to update subtask:', error)
@@ -116,5 +150,29 @@ export const useTodoStore = defineStore('todo', () => {
     }
   }
 
-  return { todos, uniqueTags, fetchTodos, addTodo, updateTodo, deleteTodo, addSubtask, updateSubtask, deleteSubtask }
+  // The history of a todo and its subtasks, oldest first
+  const fetchHistory = async (id: number) => {
+    try {
+      const response = await axios.get<TodoEvent[]>(`${API_URL}/${id}/history`)
+      return response.data
+    } catch (error) {
+      console.error('Failed to fetch history:', error)
+      return []
+    }
+  }
+
+  // Applies operations to several todos in one request; with atomic, either
+  // all of them or none
+  const batchTodos = async (operations: BatchOperation[], atomic = false) => {
+    try {
+      const response = await axios.post(`${API_URL}/batch`, { operations, atomic })
+      await fetchTodos()
+      return response.data.results
+    } catch (error) {
+      console.error('Failed to update todos:', error)
+      return null
+    }
+  }
+
+  return { todos, uniqueTags, fetchTodos, addTodo, updateTodo, deleteTodo, fetchHistory, batchTodos, addSubtask, updateSubtask, deleteSubtask }
 })
@@ -0,0 +1,45 @@
+import { setActivePinia, createPinia } from 'pinia'
+import { describe, it, expect, beforeEach, vi } from 'vitest'
+import { useTrashStore } from './trash'
+import axios from 'axios'
+
+vi.mock('axios')
+
+describe('Trash Store', () => {
+  beforeEach(() => {
+    setActivePinia(createPinia())
+    vi.clearAllMocks()
+  })
+
+  it('fetches the trash', async () => {
+    const store = useTrashStore()
+    const mockTrash = {
+      projects: [{ id: 1, name: 'Garden', description: '', color: '#000', created_at: '', deleted_at: '2026-10-17T09:00:00Z' }],
+      todos: [],
+      subtasks: []
+    }
+
+    // @ts-expect-error -- Mocking axios
+    axios.get.mockResolvedValue({ data: mockTrash })
+
+    await store.fetchTrash()
+
+    expect(axios.get).toHaveBeenCalledWith('/api/trash')
+    expect(store.trash).toEqual(mockTrash)
+  })
+
+  it('restores an item and reloads the lists', async () => {
+    const store = useTrashStore()
+
+    // @ts-expect-error -- Mocking axios
+    axios.post.mockResolvedValue({})
+    // @ts-expect-error -- Mocking axios
+    axios.get.mockResolvedValue({ data: [] })
+
+    await store.restore('projects', 1)
+
+    expect(axios.post).toHaveBeenCalledWith('/api/trash/projects/1/restore')
+    expect(axios.get).toHaveBeenCalledWith('/api/todos')
+    expect(axios.get).toHaveBeenCalledWith('/api/projects')
+  })
+})
@@ -0,0 +1,40 @@
+import { defineStore } from 'pinia'
+import axios from 'axios'
+import { ref } from 'vue'
+import { useTodoStore, type Todo, type Subtask } from './todo'
+import { useProjectStore, type Project } from './project'
+
+export interface Trash {
+  projects: Project[]
+  todos: Todo[]
+  subtasks: Subtask[]
+}
+
+export type TrashKind = 'projects' | 'todos' | 'subtasks'
+
+export const useTrashStore = defineStore('trash', () => {
+  const trash = ref<Trash>({ projects: [], todos: [], subtasks: [] })
+  const API_URL = '/api/trash'
+
+  const fetchTrash = async () => {
+    try {
+      const response = await axios.get<Trash>(API_URL)
+      trash.value = response.data
+    } catch (error) {
+      console.error('Failed to fetch trash:', error)
+    }
+  }
+
+  // Restoring a project brings back the todos deleted with it, and a todo
+  // its subtasks, so the lists are reloaded too
+  const restore = async (kind: TrashKind, id: number) => {
+    try {
+      await axios.post(`${API_URL}/${kind}/${id}/restore`)
+      await Promise.all([fetchTrash(), useTodoStore().fetchTodos(), useProjectStore().fetchProjects()])
+    } catch (error) {
+      console.error('Failed to restore from trash:', error)
+    }
+  }
+
+  return { trash, fetchTrash, restore }
+})
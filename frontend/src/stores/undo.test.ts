@@ -0,0 +1,43 @@
+import { setActivePinia, createPinia } from 'pinia'
+import { describe, it, expect, beforeEach, vi } from 'vitest'
+import { useUndoStore } from './undo'
+import axios from 'axios'
+
+vi.mock('axios')
+
+describe('Undo Store', () => {
+  beforeEach(() => {
+    setActivePinia(createPinia())
+    vi.clearAllMocks()
+  })
+
+  it('undoes the last change and reloads the lists', async () => {
+    const store = useUndoStore()
+    const command = { id: 3, name: 'Create a todo', status: 'undone', created_at: '2026-10-17T09:00:00Z' }
+
+    // @ts-expect-error -- Mocking axios
+    axios.post.mockResolvedValue({ data: command })
+    // @ts-expect-error -- Mocking axios
+    axios.get.mockResolvedValue({ data: [] })
+
+    await store.undo()
+
+    expect(axios.post).toHaveBeenCalledWith('/api/undo')
+    expect(store.lastCommand).toEqual(command)
+    expect(axios.get).toHaveBeenCalledWith('/api/todos')
+    expect(axios.get).toHaveBeenCalledWith('/api/projects')
+  })
+
+  it('keeps the lists when there is nothing to redo', async () => {
+    const store = useUndoStore()
+
+    // @ts-expect-error -- Mocking axios
+    axios.post.mockRejectedValue({ response: { status: 404 } })
+
+    await store.redo()
+
+    expect(axios.post).toHaveBeenCalledWith('/api/redo')
+    expect(store.lastCommand).toBeNull()
+    expect(axios.get).not.toHaveBeenCalled()
+  })
+})
@@ -0,0 +1,35 @@
+import { defineStore } from 'pinia'
+import axios from 'axios'
+import { ref } from 'vue'
+import { useTodoStore } from './todo'
+import { useProjectStore } from './project'
+
+export interface Command {
+  id: number
+  name: string
+  status: 'done' | 'undone'
+  created_at: string
+}
+
+export const useUndoStore = defineStore('undo', () => {
+  // The command undone or redone last, to tell the user what happened
+  const lastCommand = ref<Command | null>(null)
+
+  // A command can touch todos and projects alike, so both lists are
+  // reloaded. 404 means there is nothing to undo or redo, 409 that the
+  // change was overtaken by another one.
+  const step = async (action: 'undo' | 'redo') => {
+    try {
+      const response = await axios.post<Command>(`/api/${action}`)
+      lastCommand.value = response.data
+      await Promise.all([useTodoStore().fetchTodos(), useProjectStore().fetchProjects()])
+    } catch (error) {
+      console.error(`Failed to ${action}:`, error)
+    }
+  }
+
+  const undo = () => step('undo')
+  const redo = () => step('redo')
+
+  return { lastCommand, undo, redo }
+})
@@ -1,4 +1,8 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
+export function APISecret():Promise<string>;
+
+export function APIURL():Promise<string>;
+
 export function Greet(arg1:string):Promise<string>;
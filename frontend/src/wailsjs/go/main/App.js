@@ -2,6 +2,14 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
+export function APISecret() {
+  return window['go']['main']['App']['APISecret']();
+}
+
+export function APIURL() {
+  return window['go']['main']['App']['APIURL']();
+}
+
 export function Greet(arg1) {
   return window['go']['main']['App']['Greet'](arg1);
 }
@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"embed"
+	"encoding/base64"
 	"log"
 	"todo/backend/db"
 	"todo/backend/server"
@@ -16,26 +18,61 @@ import (
 //go:embed all:frontend/dist
 var assets embed.FS
 
+// desktopOrigins are the origins the webview loads the frontend from: the
+// wails:// scheme on macOS and Linux, wails.localhost on Windows, and the
+// Vite dev server during `wails dev`.
+var desktopOrigins = []string{
+	"wails://",
+	"http://wails.localhost",
+	"https://wails.localhost",
+	"http://localhost:5173",
+}
+
 func main() {
 	// Initialize DB
-	if err := db.InitDB("todo.db"); err != nil {
+	conn, err := db.InitDB("todo.db")
+	if err != nil {
 		log.Fatal(err)
 	}
+	defer conn.Close()
+
+	svc := service.NewSQLite(conn)
 
-	// Start HTTP Server
-	// Use a fixed port for now, e.g., 8081
-	server.StartServer("8081")
-	
-	// Start Notification Scheduler
-	service.StartNotificationScheduler()
+	// Start HTTP Server on a free loopback port, reachable only by the
+	// webview: it learns the port and this launch's secret from App bindings
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		log.Fatal(err)
+	}
+	secret := base64.RawURLEncoding.EncodeToString(raw)
+	srv := server.New(svc)
+	srv.RequireSecret(secret)
+	srv.AllowOrigins(desktopOrigins...)
+	if err := srv.Listen("127.0.0.1:0", "", ""); err != nil {
+		log.Fatal(err)
+	}
+
+	// Start Notification Scheduler, delivering to the channels in
+	// notifications.json or else as desktop notifications
+	cfg, err := service.LoadNotificationConfig("notifications.json")
+	if err != nil {
+		log.Fatal(err)
+	}
+	notifier, err := service.NewNotifierRouter(cfg, service.DesktopNotifier{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	svc.SetNotifier(notifier)
+	svc.StartNotificationScheduler(service.SchedulerOptions{Digest: cfg.Digest})
+	svc.StartTrashPurger(service.DefaultTrashRetention)
 
-	defer server.StopServer(context.Background())
+	defer srv.Stop(context.Background())
 
 	// Create an instance of the app structure
-	app := NewApp()
+	app := NewApp("http://"+srv.Addr().String(), secret)
 
 	// Create application with options
-	err := wails.Run(&options.App{
+	err = wails.Run(&options.App{
 		Title:  "Todo App",
 		Width:  1024,
 		Height: 768,